@@ -0,0 +1,147 @@
+// Package apierror defines the JSON error envelope returned by every endpoint.
+//
+// A failed request is answered with
+//
+//	{"error": {"code": "validation_failed", "message": "...", "details": [...], "request_id": "..."}}
+//
+// where code is stable and machine-readable, message is for humans, and
+// details lists field-level problems when there are any.
+package apierror
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Code is a machine-readable error code.
+type Code string
+
+const (
+	CodeBadRequest       Code = "bad_request"
+	CodeValidationFailed Code = "validation_failed"
+	CodeNotFound         Code = "not_found"
+	CodeRouteNotFound    Code = "route_not_found"
+	CodeConflict         Code = "conflict"
+	CodeIdempotencyReuse Code = "idempotency_key_reused"
+	CodeUnauthorized     Code = "unauthorized"
+	CodeForbidden        Code = "forbidden"
+	CodePreconditionFail Code = "precondition_failed"
+	CodePreconditionReq  Code = "precondition_required"
+	CodeRateLimited      Code = "rate_limited"
+	CodePayloadTooLarge  Code = "payload_too_large"
+	CodeInternal         Code = "internal_error"
+)
+
+// RequestIDHeader carries the ID that ties an error response to server logs.
+const RequestIDHeader = "X-Request-ID"
+
+// FieldError describes a problem with one field of the request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is the single error type handlers return to clients.
+type Error struct {
+	Status    int          `json:"-"`
+	Code      Code         `json:"code"`
+	Message   string       `json:"message"`
+	Details   []FieldError `json:"details,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+
+	// Err is the underlying cause. It is logged, never sent to clients.
+	Err error `json:"-"`
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// BadRequest is for malformed requests: bad JSON, unparsable IDs or parameters.
+func BadRequest(message string) *Error {
+	return &Error{Status: http.StatusBadRequest, Code: CodeBadRequest, Message: message}
+}
+
+// Validation is for well-formed requests whose content is not acceptable.
+func Validation(message string, details ...FieldError) *Error {
+	return &Error{Status: http.StatusUnprocessableEntity, Code: CodeValidationFailed, Message: message, Details: details}
+}
+
+// NotFound is for a resource that does not exist.
+func NotFound(message string) *Error {
+	return &Error{Status: http.StatusNotFound, Code: CodeNotFound, Message: message}
+}
+
+// RouteNotFound is for a method and path that no handler serves.
+func RouteNotFound(message string) *Error {
+	return &Error{Status: http.StatusNotFound, Code: CodeRouteNotFound, Message: message}
+}
+
+// Conflict is for requests that clash with the current state of a resource.
+func Conflict(message string) *Error {
+	return &Error{Status: http.StatusConflict, Code: CodeConflict, Message: message}
+}
+
+// IdempotencyKeyReused is for an Idempotency-Key sent again with a different
+// request than the one it was first used for.
+func IdempotencyKeyReused(message string) *Error {
+	return &Error{Status: http.StatusUnprocessableEntity, Code: CodeIdempotencyReuse, Message: message}
+}
+
+// PreconditionFailed is for conditional requests whose If-Match no longer
+// matches, i.e. someone else changed the resource first.
+func PreconditionFailed(message string) *Error {
+	return &Error{Status: http.StatusPreconditionFailed, Code: CodePreconditionFail, Message: message}
+}
+
+// PreconditionRequired is for writes that must be conditional but were not.
+func PreconditionRequired(message string) *Error {
+	return &Error{Status: http.StatusPreconditionRequired, Code: CodePreconditionReq, Message: message}
+}
+
+// Unauthorized is for requests that do not say who is making them.
+func Unauthorized(message string) *Error {
+	return &Error{Status: http.StatusUnauthorized, Code: CodeUnauthorized, Message: message}
+}
+
+// Forbidden is for requests the server refuses to perform.
+func Forbidden(message string) *Error {
+	return &Error{Status: http.StatusForbidden, Code: CodeForbidden, Message: message}
+}
+
+// RateLimited is for clients that exceeded their request budget.
+func RateLimited(message string) *Error {
+	return &Error{Status: http.StatusTooManyRequests, Code: CodeRateLimited, Message: message}
+}
+
+// PayloadTooLarge is for request bodies over the size the server accepts.
+func PayloadTooLarge(message string) *Error {
+	return &Error{Status: http.StatusRequestEntityTooLarge, Code: CodePayloadTooLarge, Message: message}
+}
+
+// Internal hides err behind a generic message.
+func Internal(err error, message string) *Error {
+	return &Error{Status: http.StatusInternalServerError, Code: CodeInternal, Message: message, Err: err}
+}
+
+// Write sends e as a JSON error envelope. The request ID is taken from the
+// response headers, where the RequestID middleware puts it.
+func Write(w http.ResponseWriter, e *Error) {
+	if e.RequestID == "" {
+		e.RequestID = w.Header().Get(RequestIDHeader)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(e.Status)
+	json.NewEncoder(w).Encode(struct {
+		Error *Error `json:"error"`
+	}{e})
+}
@@ -1,13 +1,17 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
+	"simple-poll/middleware"
 	poll "simple-poll/poll"
+	"simple-poll/scheduler"
 
 	_ "github.com/go-sql-driver/mysql"
 )
@@ -32,6 +36,12 @@ func main() {
 		log.Fatalf("Could not ping DB: %v", err)
 	}
 
+	// Add the columns, indexes and tables fly.sql gained since the volume
+	// was created
+	if err := poll.Migrate(db); err != nil {
+		log.Fatalf("Could not migrate DB: %v", err)
+	}
+
 	// Use a ServeMux to handle all routes
 	mux := http.NewServeMux()
 
@@ -40,16 +50,80 @@ func main() {
 		fmt.Fprintln(w, `{"message": "Hello from Go backend!"}`)
 	})
 
-	// Attach poll routes at /api/polls
-	mux.Handle("/api/polls/", http.StripPrefix("/api/polls", poll.PollRouter(db)))
-	mux.Handle("/api/questions/", http.StripPrefix("/api/questions", poll.QuestionRouter(db)))
-	mux.Handle("/api/choices/", http.StripPrefix("/api/choices", poll.ChoiceRouter(db)))
+	// Permanently delete polls that have sat in the trash past retention
+	retention := poll.DefaultTrashRetention
+	if v := os.Getenv("TRASH_RETENTION"); v != "" {
+		retention, err = time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("Invalid TRASH_RETENTION: %v", err)
+		}
+	}
+	go poll.PurgeTrashEvery(context.Background(), db, retention, time.Hour)
+
+	// Open and close published polls on their start and end dates
+	interval := scheduler.DefaultInterval
+	if v := os.Getenv("SCHEDULER_INTERVAL"); v != "" {
+		interval, err = time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("Invalid SCHEDULER_INTERVAL: %v", err)
+		}
+	}
+	clock := scheduler.SystemClock{}
+	sched := scheduler.New(scheduler.DBStore{DB: db}, clock, interval)
+	sched.OnTransition(scheduler.SnapshotResults(db, clock))
+	sched.OnTransition(scheduler.LogTransitions)
+	go sched.Run(context.Background())
+
+	// Links in QR codes point at the front end's poll pages
+	voteBase := os.Getenv("PUBLIC_URL")
+	if voteBase == "" {
+		voteBase = poll.DefaultVoteBaseURL
+	}
+
+	// Attach the API once per version, plus the unversioned /api/... paths
+	// older clients use, which keep serving v1
+	searcher := poll.NewMySQLSearcher(db)
+	for _, v := range poll.Versions {
+		mountAPI(mux, db, searcher, voteBase, v.BasePath(), v)
+	}
+	mountAPI(mux, db, searcher, voteBase, "/api", poll.V1)
+
+	// Answer retried POSTs with the response of the first attempt
+	idempotency := middleware.DefaultIdempotencyConfig()
+	if ttl := os.Getenv("IDEMPOTENCY_TTL"); ttl != "" {
+		idempotency.TTL, err = time.ParseDuration(ttl)
+		if err != nil {
+			log.Fatalf("Invalid IDEMPOTENCY_TTL: %v", err)
+		}
+	}
+	retrySafe := middleware.Idempotency(mux, idempotency)
+
+	// Reject forged cookie-authenticated writes on every router
+	protected := middleware.CSRF(retrySafe, os.Getenv("COOKIE_SECURE") == "true")
+
+	// Rate limit per client before the request reaches any route
+	limited := middleware.RateLimit(protected, middleware.DefaultRateLimitConfig())
 
 	// Wrap the mux with our CORS middleware
-	handlerWithCORS := corsMiddleware(mux)
+	handlerWithCORS := corsMiddleware(limited)
+
+	// Tag every request so error responses can be matched to logs
+	handler := middleware.RequestID(handlerWithCORS)
 
 	log.Println("Backend running on port 3000")
-	log.Fatal(http.ListenAndServe(":3000", handlerWithCORS))
+	log.Fatal(http.ListenAndServe(":3000", handler))
+}
+
+// mountAPI attaches every router under base, reading and writing the JSON
+// representation of version v.
+func mountAPI(mux *http.ServeMux, db *sql.DB, searcher poll.Searcher, voteBase, base string, v *poll.APIVersion) {
+	mux.Handle(base+"/polls/", http.StripPrefix(base+"/polls", poll.WithVersion(v, poll.PollRouter(db, voteBase))))
+	mux.Handle(base+"/questions/", http.StripPrefix(base+"/questions", poll.WithVersion(v, poll.QuestionRouter(db))))
+	mux.Handle(base+"/choices/", http.StripPrefix(base+"/choices", poll.WithVersion(v, poll.ChoiceRouter(db))))
+	mux.Handle(base+"/templates/", http.StripPrefix(base+"/templates", poll.WithVersion(v, poll.TemplateRouter(db))))
+	mux.Handle(base+"/tags/", http.StripPrefix(base+"/tags", poll.WithVersion(v, poll.TagRouter(db))))
+	mux.Handle(base+"/search", poll.WithVersion(v, poll.SearchRouter(searcher)))
+	mux.Handle(base+"/openapi.json", poll.WithVersion(v, poll.OpenAPIHandler()))
 }
 
 // corsMiddleware sets CORS headers on every request
@@ -60,8 +134,9 @@ func corsMiddleware(next http.Handler) http.Handler {
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
 
 		// Allowed methods and headers
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-CSRF-Token, X-Request-ID, X-User-ID, If-Match, If-None-Match, Idempotency-Key")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag, Idempotent-Replayed, Retry-After, X-CSRF-Token, X-Request-ID, X-API-Version")
 
 		// If this is a preflight request, return 200 directly
 		if r.Method == http.MethodOptions {
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+
+	"simple-poll/apierror"
+)
+
+const (
+	// CSRFCookieName holds the double-submit token in the browser.
+	CSRFCookieName = "csrf_token"
+	// CSRFHeaderName is where clients echo the cookie back on unsafe requests.
+	CSRFHeaderName = "X-CSRF-Token"
+)
+
+// CSRF protects cookie-authenticated requests with the double-submit cookie
+// pattern: every browser gets a random token cookie, and POST, PUT, PATCH and
+// DELETE requests that carry cookies must repeat that token in the
+// X-CSRF-Token header. A cross-site form or fetch can send the cookie but
+// cannot read it, so it cannot produce the header.
+//
+// Requests with a bearer token are exempt, since browsers never attach those
+// automatically. Requests without any cookies are exempt too: with no ambient
+// credentials there is nothing to forge.
+func CSRF(next http.Handler, secureCookie bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := ""
+		if c, err := r.Cookie(CSRFCookieName); err == nil {
+			token = c.Value
+		}
+		hadCookies := len(r.Cookies()) > 0
+
+		if token == "" {
+			token = newCSRFToken()
+			http.SetCookie(w, &http.Cookie{
+				Name:     CSRFCookieName,
+				Value:    token,
+				Path:     "/",
+				SameSite: http.SameSiteLaxMode,
+				Secure:   secureCookie,
+			})
+		}
+		// Also hand the token out in a header so frontends served from another
+		// origin can pick it up without reading document.cookie.
+		w.Header().Set(CSRFHeaderName, token)
+
+		if isUnsafeMethod(r.Method) && hadCookies && bearerToken(r) == "" {
+			sent := r.Header.Get(CSRFHeaderName)
+			if sent == "" || subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+				apierror.Write(w, apierror.Forbidden("Missing or invalid CSRF token"))
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isUnsafeMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func newCSRFToken() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic("csrf: could not read random bytes: " + err.Error())
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
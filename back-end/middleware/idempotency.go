@@ -0,0 +1,313 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"simple-poll/apierror"
+)
+
+const (
+	// IdempotencyKeyHeader names a POST so that retries of it are answered
+	// from the first response instead of being executed again.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on responses replayed from the store.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLen  = 255
+	maxIdempotentBodySize = 1 << 20
+)
+
+// IdempotentResponse is what the store keeps for one Idempotency-Key.
+type IdempotentResponse struct {
+	// Fingerprint identifies the request the key was first used for.
+	Fingerprint string
+	// Done is false while the first request is still being handled.
+	Done bool
+
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// IdempotencyStore keeps the first response to each Idempotency-Key.
+// The in-memory implementation below is enough for a single backend; a shared
+// store (Redis, MySQL, ...) can be plugged in by implementing this interface.
+type IdempotencyStore interface {
+	// Reserve claims key for a request with the given fingerprint, for ttl.
+	// If the key is already taken it returns the existing entry and false.
+	Reserve(key, fingerprint string, ttl time.Duration) (*IdempotentResponse, bool, error)
+	// Save records the response of the request that reserved key.
+	Save(key string, resp *IdempotentResponse) error
+	// Release drops a reservation whose request failed, so it can be retried.
+	Release(key string) error
+}
+
+// IdempotencyConfig configures the Idempotency middleware.
+type IdempotencyConfig struct {
+	Store IdempotencyStore
+	// TTL is how long a key is remembered after its first use.
+	TTL time.Duration
+	// Scope returns who a key belongs to, so that two clients picking the
+	// same key never see each other's responses. Defaults to IdempotencyScope.
+	Scope func(r *http.Request) string
+}
+
+// DefaultIdempotencyConfig remembers keys for a day, which comfortably
+// outlasts any client retry loop.
+func DefaultIdempotencyConfig() IdempotencyConfig {
+	return IdempotencyConfig{
+		Store: NewMemoryIdempotencyStore(),
+		TTL:   24 * time.Hour,
+	}
+}
+
+// Idempotency makes POST requests carrying an Idempotency-Key header safe to
+// retry. The first request with a key is handled normally and its response
+// stored; retries with the same key and body get that response replayed,
+// marked with Idempotent-Replayed: true. Reusing a key with a different body
+// is refused with 422, and a retry arriving while the first request is still
+// running gets 409.
+//
+// Server errors are not stored, so a request that failed with a 5xx can be
+// retried with the same key.
+func Idempotency(next http.Handler, cfg IdempotencyConfig) http.Handler {
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryIdempotencyStore()
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = DefaultIdempotencyConfig().TTL
+	}
+	if cfg.Scope == nil {
+		cfg.Scope = IdempotencyScope
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if r.Method != http.MethodPost || key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			apierror.Write(w, apierror.BadRequest("Idempotency-Key must be at most 255 characters"))
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBodySize))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			apierror.Write(w, apierror.PayloadTooLarge(fmt.Sprintf("Request body must be at most %d bytes", tooLarge.Limit)))
+			return
+		}
+		if err != nil {
+			apierror.Write(w, apierror.BadRequest("Request body is unreadable"))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		storeKey := cfg.Scope(r) + " " + r.URL.Path + " " + key
+		fingerprint := requestFingerprint(r, body)
+
+		existing, reserved, err := cfg.Store.Reserve(storeKey, fingerprint, cfg.TTL)
+		if err != nil {
+			// Fail open, like the rate limiter: handle the request unprotected
+			// rather than refusing it.
+			log.Printf("Error reserving idempotency key: %v", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !reserved {
+			switch {
+			case existing.Fingerprint != fingerprint:
+				apierror.Write(w, apierror.IdempotencyKeyReused("Idempotency-Key was already used for a different request"))
+			case !existing.Done:
+				w.Header().Set("Retry-After", "1")
+				apierror.Write(w, apierror.Conflict("A request with this Idempotency-Key is still in progress"))
+			default:
+				replay(w, existing)
+			}
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w, before: w.Header().Clone()}
+		defer func() {
+			// Handlers that panic or fail with a 5xx leave nothing behind,
+			// so the client's retry runs them again.
+			if rec.status == 0 || rec.status >= 500 {
+				if err := cfg.Store.Release(storeKey); err != nil {
+					log.Printf("Error releasing idempotency key: %v", err)
+				}
+				return
+			}
+			resp := &IdempotentResponse{
+				Fingerprint: fingerprint,
+				Done:        true,
+				Status:      rec.status,
+				Header:      rec.header,
+				Body:        rec.body.Bytes(),
+			}
+			if err := cfg.Store.Save(storeKey, resp); err != nil {
+				log.Printf("Error saving idempotent response: %v", err)
+			}
+		}()
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			// Nothing written at all is an implicit 200 with an empty body.
+			rec.WriteHeader(http.StatusOK)
+		}
+	})
+}
+
+// IdempotencyScope ties keys to the bearer token a request presents, so a
+// client retrying from a new network still finds its first response, and to
+// the client IP for anonymous requests. The X-User-ID header is not used:
+// nothing authenticates it, so anyone claiming a user's ID could replay that
+// user's responses by guessing their keys.
+func IdempotencyScope(r *http.Request) string {
+	if token := bearerToken(r); token != "" {
+		return tokenKey(token)
+	}
+	return "ip:" + clientIP(r)
+}
+
+// requestFingerprint hashes everything that makes two POSTs the same request.
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.RequestURI()+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func replay(w http.ResponseWriter, resp *IdempotentResponse) {
+	for name, values := range resp.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(resp.Status)
+	w.Write(resp.Body)
+}
+
+// responseRecorder passes a response through while keeping a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	before http.Header // headers set by outer middleware, which are not replayed
+
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.status != 0 {
+		return
+	}
+	rec.status = status
+	rec.header = make(http.Header)
+	for name, values := range rec.Header() {
+		if prev, ok := rec.before[name]; !ok || !equalValues(prev, values) {
+			rec.header[name] = append([]string(nil), values...)
+		}
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.WriteHeader(http.StatusOK)
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+func equalValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// MemoryIdempotencyStore is an in-process IdempotencyStore.
+type MemoryIdempotencyStore struct {
+	mu        sync.Mutex
+	entries   map[string]*idempotencyEntry
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+type idempotencyEntry struct {
+	resp    IdempotentResponse
+	expires time.Time
+}
+
+// NewMemoryIdempotencyStore returns an empty MemoryIdempotencyStore.
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		entries: make(map[string]*idempotencyEntry),
+		now:     time.Now,
+	}
+}
+
+// Reserve implements IdempotencyStore.
+func (m *MemoryIdempotencyStore) Reserve(key, fingerprint string, ttl time.Duration) (*IdempotentResponse, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.sweep(now)
+
+	if e, ok := m.entries[key]; ok && now.Before(e.expires) {
+		resp := e.resp
+		return &resp, false, nil
+	}
+	m.entries[key] = &idempotencyEntry{
+		resp:    IdempotentResponse{Fingerprint: fingerprint},
+		expires: now.Add(ttl),
+	}
+	return nil, true, nil
+}
+
+// Save implements IdempotencyStore.
+func (m *MemoryIdempotencyStore) Save(key string, resp *IdempotentResponse) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if e, ok := m.entries[key]; ok {
+		e.resp = *resp
+	}
+	return nil
+}
+
+// Release implements IdempotencyStore.
+func (m *MemoryIdempotencyStore) Release(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.entries, key)
+	return nil
+}
+
+// sweep drops expired entries, at most once a minute.
+func (m *MemoryIdempotencyStore) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < time.Minute {
+		return
+	}
+	m.lastSweep = now
+	for key, e := range m.entries {
+		if !now.Before(e.expires) {
+			delete(m.entries, key)
+		}
+	}
+}
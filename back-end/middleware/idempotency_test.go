@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"simple-poll/apierror"
+)
+
+// countingHandler answers every request with the number of requests it has
+// handled so far.
+func countingHandler() http.Handler {
+	n := 0
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(n)
+	})
+}
+
+func idempotentPost(h http.Handler, ip, userID, key, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/api/polls/", strings.NewReader(body))
+	r.RemoteAddr = ip + ":1234"
+	r.Header.Set(IdempotencyKeyHeader, key)
+	if userID != "" {
+		r.Header.Set("X-User-ID", userID)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestIdempotencyReplaysRetries(t *testing.T) {
+	h := Idempotency(countingHandler(), DefaultIdempotencyConfig())
+
+	first := idempotentPost(h, "203.0.113.9", "", "k1", `{"title":"Lunch"}`)
+	retry := idempotentPost(h, "203.0.113.9", "", "k1", `{"title":"Lunch"}`)
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() {
+		t.Fatalf("retry: %d %q, want the first response %d %q", retry.Code, retry.Body, first.Code, first.Body)
+	}
+	if retry.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Error("retry is not marked as replayed")
+	}
+
+	if rec := idempotentPost(h, "203.0.113.9", "", "k1", `{"title":"Dinner"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("key reused for another body: status %d, want 422", rec.Code)
+	}
+}
+
+func TestIdempotencyScopeIgnoresClaimedUserID(t *testing.T) {
+	h := Idempotency(countingHandler(), DefaultIdempotencyConfig())
+
+	victim := idempotentPost(h, "203.0.113.9", "7", "k1", `{}`)
+	// Someone else claiming to be user 7 with the same key runs their own
+	// request rather than reading user 7's response.
+	other := idempotentPost(h, "198.51.100.7", "7", "k1", `{}`)
+	if other.Header().Get(IdempotentReplayedHeader) != "" || other.Body.String() == victim.Body.String() {
+		t.Errorf("another IP claiming user 7 got user 7's response %q replayed", other.Body)
+	}
+	// The same client is still answered from its first response, whatever
+	// user ID it claims.
+	if rec := idempotentPost(h, "203.0.113.9", "8", "k1", `{}`); rec.Body.String() != victim.Body.String() {
+		t.Errorf("retry from the same IP: %q, want %q", rec.Body, victim.Body)
+	}
+}
+
+func TestIdempotencyScopeFollowsTheBearerToken(t *testing.T) {
+	h := Idempotency(countingHandler(), DefaultIdempotencyConfig())
+	post := func(ip, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/polls/", strings.NewReader(`{}`))
+		r.RemoteAddr = ip + ":1234"
+		r.Header.Set(IdempotencyKeyHeader, "k1")
+		r.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec
+	}
+
+	first := post("203.0.113.9", "phone")
+	// The phone moved from Wi-Fi to cellular before retrying.
+	retry := post("198.51.100.7", "phone")
+	if retry.Header().Get(IdempotentReplayedHeader) != "true" || retry.Body.String() != first.Body.String() {
+		t.Errorf("retry from a new IP: %q, want the first response %q replayed", retry.Body, first.Body)
+	}
+	// Another token behind the same IP, e.g. a shared NAT, has keys of its own.
+	if other := post("203.0.113.9", "laptop"); other.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Errorf("another token got the phone's response %q replayed", other.Body)
+	}
+}
+
+func TestIdempotencyRefusesLargeBodies(t *testing.T) {
+	h := Idempotency(countingHandler(), DefaultIdempotencyConfig())
+
+	rec := idempotentPost(h, "203.0.113.9", "", "k1", strings.Repeat("a", maxIdempotentBodySize+1))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status %d, want 413", rec.Code)
+	}
+	var body struct{ Error apierror.Error }
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Error.Code != apierror.CodePayloadTooLarge {
+		t.Errorf("code %q, want %q", body.Error.Code, apierror.CodePayloadTooLarge)
+	}
+
+	if rec := idempotentPost(h, "203.0.113.9", "", "k2", strings.Repeat("a", maxIdempotentBodySize)); rec.Code != http.StatusCreated {
+		t.Errorf("body at the limit: status %d, want 201", rec.Code)
+	}
+}
@@ -0,0 +1,239 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"simple-poll/apierror"
+)
+
+// Class groups requests that share a rate limit budget.
+type Class string
+
+const (
+	ClassRead  Class = "read"
+	ClassWrite Class = "write"
+)
+
+// Rate describes a token bucket: Burst tokens at most, one token regained every Refill.
+type Rate struct {
+	Burst  int
+	Refill time.Duration
+}
+
+// Limiter decides whether a request may spend one token from the bucket of
+// each of its keys. The in-memory implementation below is enough for a single
+// backend; a shared store (Redis, MySQL, ...) can be plugged in by
+// implementing this interface.
+type Limiter interface {
+	// Allow spends one token from every key's bucket. When any of them is
+	// empty it spends none, so a request refused for one key does not eat
+	// into the budget of the others, and returns false and how long the
+	// caller should wait before retrying.
+	Allow(keys []string, rate Rate) (bool, time.Duration, error)
+}
+
+// RateLimitConfig configures the RateLimit middleware.
+type RateLimitConfig struct {
+	Limiter Limiter
+
+	Read  Rate
+	Write Rate
+
+	// Classify picks the budget for a request. Defaults to ClassifyRequest.
+	Classify func(r *http.Request) Class
+	// Keys returns every identity the request is charged against.
+	// Defaults to RequestKeys.
+	Keys func(r *http.Request) []string
+}
+
+// DefaultRateLimitConfig returns budgets suitable for a single backend instance.
+func DefaultRateLimitConfig() RateLimitConfig {
+	return RateLimitConfig{
+		Limiter: NewMemoryLimiter(),
+		Read:    Rate{Burst: 120, Refill: 500 * time.Millisecond},
+		Write:   Rate{Burst: 30, Refill: 2 * time.Second},
+	}
+}
+
+// RateLimit rejects requests with 429 Too Many Requests once any of their keys
+// has exhausted the budget for the request's class.
+func RateLimit(next http.Handler, cfg RateLimitConfig) http.Handler {
+	if cfg.Limiter == nil {
+		cfg.Limiter = NewMemoryLimiter()
+	}
+	if cfg.Classify == nil {
+		cfg.Classify = ClassifyRequest
+	}
+	if cfg.Keys == nil {
+		cfg.Keys = RequestKeys
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		class := cfg.Classify(r)
+		rate := cfg.rateFor(class)
+
+		var keys []string
+		for _, key := range cfg.Keys(r) {
+			keys = append(keys, string(class)+":"+key)
+		}
+		ok, wait, err := cfg.Limiter.Allow(keys, rate)
+		if err != nil {
+			// Fail open: a broken limiter store should not take the API down.
+			log.Printf("Error checking rate limit: %v", err)
+			ok = true
+		}
+
+		if !ok {
+			seconds := int(math.Ceil(wait.Seconds()))
+			if seconds < 1 {
+				seconds = 1
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			apierror.Write(w, apierror.RateLimited("Too many requests, retry after "+strconv.Itoa(seconds)+"s"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (cfg RateLimitConfig) rateFor(class Class) Rate {
+	if class == ClassWrite {
+		return cfg.Write
+	}
+	return cfg.Read
+}
+
+// ClassifyRequest treats state-changing methods as writes and everything else
+// as reads. There is no voting route yet; when one is added, ballots get a
+// class of their own keyed on that route.
+func ClassifyRequest(r *http.Request) Class {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return ClassRead
+	}
+	return ClassWrite
+}
+
+// RequestKeys charges a request against its client IP and, when present,
+// the bearer token it presents. The X-User-ID header is not a key: nothing
+// authenticates it, so charging it would let anyone drain another user's
+// budget. Users get a key of their own once requests are authenticated.
+func RequestKeys(r *http.Request) []string {
+	keys := []string{"ip:" + clientIP(r)}
+
+	if token := bearerToken(r); token != "" {
+		keys = append(keys, tokenKey(token))
+	}
+	return keys
+}
+
+// tokenKey hashes a bearer token so raw credentials never sit in the limiter
+// or idempotency stores.
+func tokenKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "token:" + hex.EncodeToString(sum[:16])
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
+
+// MemoryLimiter is an in-process token bucket Limiter.
+type MemoryLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Duration // time for an empty bucket to refill completely
+}
+
+// NewMemoryLimiter returns an empty MemoryLimiter.
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow implements Limiter.
+func (m *MemoryLimiter) Allow(keys []string, rate Rate) (bool, time.Duration, error) {
+	if rate.Burst <= 0 || rate.Refill <= 0 {
+		return true, 0, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.sweep(now)
+
+	// Refill every bucket first and only spend once all of them can pay.
+	buckets := make([]*bucket, len(keys))
+	var wait time.Duration
+	for i, key := range keys {
+		b, ok := m.buckets[key]
+		if !ok {
+			b = &bucket{tokens: float64(rate.Burst), last: now}
+			m.buckets[key] = b
+		}
+		b.full = time.Duration(rate.Burst) * rate.Refill
+
+		// Refill for the time elapsed since the last request.
+		elapsed := now.Sub(b.last)
+		b.tokens = math.Min(float64(rate.Burst), b.tokens+float64(elapsed)/float64(rate.Refill))
+		b.last = now
+
+		if missing := 1 - b.tokens; missing > 0 {
+			wait = max(wait, time.Duration(missing*float64(rate.Refill)))
+		}
+		buckets[i] = b
+	}
+	if wait > 0 {
+		return false, wait, nil
+	}
+	for _, b := range buckets {
+		b.tokens--
+	}
+	return true, 0, nil
+}
+
+// sweep drops buckets that have been idle long enough to be full again,
+// which keeps memory bounded without changing any limiting decision.
+func (m *MemoryLimiter) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < time.Minute {
+		return
+	}
+	m.lastSweep = now
+	for key, b := range m.buckets {
+		if now.Sub(b.last) >= b.full {
+			delete(m.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeNow is a clock for MemoryLimiter that only moves when told to.
+type fakeNow struct{ t time.Time }
+
+func (c *fakeNow) now() time.Time          { return c.t }
+func (c *fakeNow) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestLimiter() (*MemoryLimiter, *fakeNow) {
+	clock := &fakeNow{t: time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)}
+	l := NewMemoryLimiter()
+	l.now = clock.now
+	return l, clock
+}
+
+func allow(t *testing.T, l Limiter, rate Rate, keys ...string) (bool, time.Duration) {
+	t.Helper()
+	ok, wait, err := l.Allow(keys, rate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ok, wait
+}
+
+func TestMemoryLimiterRefills(t *testing.T) {
+	l, clock := newTestLimiter()
+	rate := Rate{Burst: 2, Refill: time.Second}
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := allow(t, l, rate, "ip:a"); !ok {
+			t.Fatalf("request %d refused within the burst", i+1)
+		}
+	}
+	if ok, wait := allow(t, l, rate, "ip:a"); ok || wait != time.Second {
+		t.Fatalf("third request: allowed %v, wait %v; want refused, wait 1s", ok, wait)
+	}
+
+	clock.advance(400 * time.Millisecond)
+	if ok, wait := allow(t, l, rate, "ip:a"); ok || wait != 600*time.Millisecond {
+		t.Fatalf("after 400ms: allowed %v, wait %v; want refused, wait 600ms", ok, wait)
+	}
+
+	clock.advance(600 * time.Millisecond)
+	if ok, _ := allow(t, l, rate, "ip:a"); !ok {
+		t.Fatal("refused after a token was regained")
+	}
+
+	// A long pause refills the bucket up to the burst, not beyond.
+	clock.advance(time.Hour)
+	for i := 0; i < 2; i++ {
+		if ok, _ := allow(t, l, rate, "ip:a"); !ok {
+			t.Fatalf("request %d refused after a refill", i+1)
+		}
+	}
+	if ok, _ := allow(t, l, rate, "ip:a"); ok {
+		t.Fatal("allowed beyond the burst after a refill")
+	}
+}
+
+func TestMemoryLimiterSpendsNothingWhenRefused(t *testing.T) {
+	l, _ := newTestLimiter()
+	rate := Rate{Burst: 2, Refill: time.Minute}
+
+	allow(t, l, rate, "token:t")
+	allow(t, l, rate, "token:t")
+	if ok, _ := allow(t, l, rate, "ip:a", "token:t"); ok {
+		t.Fatal("allowed with an empty token bucket")
+	}
+
+	// ip:a paid nothing for the refused request.
+	for i := 0; i < 2; i++ {
+		if ok, _ := allow(t, l, rate, "ip:a"); !ok {
+			t.Fatalf("ip:a request %d refused; the refused request was charged", i+1)
+		}
+	}
+}
+
+func TestRateLimitSetsRetryAfter(t *testing.T) {
+	l, clock := newTestLimiter()
+	cfg := RateLimitConfig{Limiter: l, Read: Rate{Burst: 1, Refill: 1500 * time.Millisecond}}
+	h := RateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), cfg)
+
+	get := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/polls/", nil))
+		return rec
+	}
+
+	if rec := get(); rec.Code != http.StatusOK {
+		t.Fatalf("first request: status %d", rec.Code)
+	}
+	rec := get()
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request: status %d, want 429", rec.Code)
+	}
+	// 1.5s rounds up to whole seconds.
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After = %q, want 2", got)
+	}
+
+	clock.advance(1200 * time.Millisecond)
+	if got := get().Header().Get("Retry-After"); got != "1" {
+		t.Errorf("Retry-After 1.2s later = %q, want 1", got)
+	}
+	clock.advance(300 * time.Millisecond)
+	if rec := get(); rec.Code != http.StatusOK {
+		t.Errorf("request after Retry-After: status %d", rec.Code)
+	}
+}
+
+func TestRateLimitBudgetsPerClass(t *testing.T) {
+	l, _ := newTestLimiter()
+	cfg := RateLimitConfig{
+		Limiter: l,
+		Read:    Rate{Burst: 3, Refill: time.Minute},
+		Write:   Rate{Burst: 2, Refill: time.Minute},
+	}
+	h := RateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), cfg)
+
+	served := func(method, path string) int {
+		n := 0
+		for i := 0; i < 5; i++ {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+			if rec.Code == http.StatusOK {
+				n++
+			}
+		}
+		return n
+	}
+
+	// Each class spends its own budget; exhausting one leaves the other.
+	if n := served(http.MethodPost, "/api/polls/"); n != 2 {
+		t.Errorf("writes served = %d, want 2", n)
+	}
+	if n := served(http.MethodGet, "/api/polls/"); n != 3 {
+		t.Errorf("reads served = %d, want 3", n)
+	}
+}
+
+func TestRateLimitIgnoresClaimedUserID(t *testing.T) {
+	l, _ := newTestLimiter()
+	cfg := RateLimitConfig{Limiter: l, Write: Rate{Burst: 1, Refill: time.Minute}}
+	h := RateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), cfg)
+
+	post := func(ip string) int {
+		r := httptest.NewRequest(http.MethodPost, "/api/polls/", nil)
+		r.RemoteAddr = ip + ":1234"
+		r.Header.Set("X-User-ID", "7")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec.Code
+	}
+
+	// Someone else claiming to be user 7 spends their own budget only.
+	post("203.0.113.9")
+	if code := post("203.0.113.9"); code != http.StatusTooManyRequests {
+		t.Fatalf("second request from the same IP: status %d, want 429", code)
+	}
+	if code := post("198.51.100.7"); code != http.StatusOK {
+		t.Errorf("request of user 7 from their own IP: status %d, want 200", code)
+	}
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"simple-poll/apierror"
+)
+
+// RequestID tags every request with an ID, reusing a well-formed
+// X-Request-ID sent by the client or a proxy. The ID is echoed in the response
+// headers so error envelopes and logs can refer to it.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(apierror.RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+			r.Header.Set(apierror.RequestIDHeader, id)
+		}
+		w.Header().Set(apierror.RequestIDHeader, id)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("requestid: could not read random bytes: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,35 @@
+package poll
+
+import (
+	"net/http"
+	"strconv"
+
+	"simple-poll/apierror"
+)
+
+// UserIDHeader names the user a request acts for. It stands in for real
+// authentication, which the API does not have yet.
+const UserIDHeader = "X-User-ID"
+
+// callerID returns the user a request acts for, for endpoints that create
+// rows owned by the caller.
+func callerID(r *http.Request) (int64, *apierror.Error) {
+	v := r.Header.Get(UserIDHeader)
+	if v == "" {
+		return 0, apierror.Unauthorized(UserIDHeader + " header is required")
+	}
+	id, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, apierror.BadRequest("Invalid " + UserIDHeader + " header")
+	}
+	return id, nil
+}
+
+// optionalCallerID is callerID for endpoints that anonymous requests may
+// use too; it returns 0 when no user is named.
+func optionalCallerID(r *http.Request) (int64, *apierror.Error) {
+	if r.Header.Get(UserIDHeader) == "" {
+		return 0, nil
+	}
+	return callerID(r)
+}
@@ -3,6 +3,7 @@ package poll
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 )
 
 // Choice represents a choice record in the DB.
@@ -10,86 +11,226 @@ type Choice struct {
 	ID         int64  `json:"id"`
 	QuestionID int64  `json:"question_id"`
 	Text       string `json:"choice_text"`
+	// Position orders the choice within its question, starting at 0. New
+	// choices are appended; only the reorder endpoint moves them.
+	Position int   `json:"position"`
+	Version  int64 `json:"version"`
 }
 
-// ListChoices fetches all choices (optionally for a specific question if needed).
-func ListChoices(db *sql.DB, questionID *int64) ([]Choice, error) {
-	var rows *sql.Rows
-	var err error
+// choiceSortColumns are the fields ListChoices can sort by.
+var choiceSortColumns = map[string]string{
+	"id":       "c.id",
+	"text":     "c.choice_text",
+	"position": "c.position",
+}
 
+// ListChoices fetches one page of choices (optionally for a specific question),
+// filtered by their poll according to opts, and the cursor of the next page.
+// The choices of one question come in ballot order unless opts asks otherwise.
+func ListChoices(db *sql.DB, questionID *int64, opts ListOptions) ([]Choice, string, error) {
 	if questionID != nil {
-		// If you want to filter by question_id
-		rows, err = db.Query("SELECT id, question_id, choice_text FROM choices WHERE question_id = ?", *questionID)
+		opts.normalize("position")
 	} else {
-		// Otherwise, get all choices
-		rows, err = db.Query("SELECT id, question_id, choice_text FROM choices")
+		opts.normalize("id")
+	}
+	if opts.Include != (Includes{}) {
+		return nil, "", fmt.Errorf("%w: choices have nothing to include", ErrInvalidListOptions)
+	}
+
+	var lq listQuery
+	if questionID != nil {
+		lq.add("c.question_id = ?", *questionID)
+	}
+	lq.addPollFilter(opts.Filter)
+	tail, err := lq.page(opts, choiceSortColumns, "c.id")
+	if err != nil {
+		return nil, "", err
 	}
+
+	query := `
+        SELECT c.id, c.question_id, c.choice_text, c.position, c.version
+        FROM choices c
+        JOIN questions q ON q.id = c.question_id
+        JOIN polls p ON p.id = q.poll_id` + lq.whereClause() + tail
+	rows, err := db.Query(query, lq.args...)
 	if err != nil {
-		return nil, fmt.Errorf("ListChoices: %v", err)
+		return nil, "", fmt.Errorf("ListChoices: %w", err)
 	}
 	defer rows.Close()
 
 	choices := []Choice{}
 	for rows.Next() {
 		var c Choice
-		if err := rows.Scan(&c.ID, &c.QuestionID, &c.Text); err != nil {
-			return nil, fmt.Errorf("ListChoices scan: %v", err)
+		if err := rows.Scan(&c.ID, &c.QuestionID, &c.Text, &c.Position, &c.Version); err != nil {
+			return nil, "", fmt.Errorf("ListChoices scan: %w", err)
 		}
 		choices = append(choices, c)
 	}
-	return choices, rows.Err()
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("ListChoices: %w", err)
+	}
+
+	if len(choices) <= opts.Limit {
+		return choices, "", nil
+	}
+	choices = choices[:opts.Limit]
+	last := choices[len(choices)-1]
+	c := pageCursor{Sort: opts.Sort, ID: last.ID}
+	switch strings.TrimPrefix(opts.Sort, "-") {
+	case "text":
+		c.Text = &last.Text
+	case "position":
+		n := int64(last.Position)
+		c.Num = &n
+	}
+	return choices, encodeCursor(c), nil
 }
 
 // GetChoice returns a single Choice by ID.
 func GetChoice(db *sql.DB, choiceID int64) (*Choice, error) {
 	var c Choice
-	err := db.QueryRow("SELECT id, question_id, choice_text FROM choices WHERE id = ?", choiceID).
-		Scan(&c.ID, &c.QuestionID, &c.Text)
+	err := db.QueryRow("SELECT id, question_id, choice_text, position, version FROM choices WHERE id = ?"+liveCondition("choices"), choiceID).
+		Scan(&c.ID, &c.QuestionID, &c.Text, &c.Position, &c.Version)
 	if err == sql.ErrNoRows {
 		// No result found
 		return nil, nil
 	}
 	if err != nil {
-		return nil, fmt.Errorf("GetChoice: %v", err)
+		return nil, fmt.Errorf("GetChoice: %w", err)
 	}
 	return &c, nil
 }
 
-// CreateChoice inserts a new choice into the DB.
-func CreateChoice(db *sql.DB, c *Choice) error {
+// CreateChoice inserts a new choice into the DB. author is recorded in the
+// poll's revision history; 0 means unknown.
+func CreateChoice(db *sql.DB, c *Choice, author int64) error {
+	return withTx(db, func(tx *sql.Tx) error {
+		if err := createChoice(tx, c); err != nil {
+			return err
+		}
+		pollID, err := pollOfQuestion(tx, c.QuestionID)
+		if err != nil {
+			return err
+		}
+		return recordRevision(tx, pollID, author, RevisionChoiceAdded)
+	})
+}
+
+func createChoice(db dbtx, c *Choice) error {
+	if err := requireDraftOfQuestion(db, c.QuestionID); err != nil {
+		return err
+	}
+	if err := insertChoice(db, c); err != nil {
+		return err
+	}
+	return touchPollOfQuestion(db, c.QuestionID)
+}
+
+// insertChoice appends a choice to its question without checking the poll's
+// state or bumping its version, for polls being created in the same
+// transaction.
+func insertChoice(db dbtx, c *Choice) error {
+	position, err := nextPosition(db, "choices", "question_id", c.QuestionID)
+	if err != nil {
+		return err
+	}
 	result, err := db.Exec(
-		"INSERT INTO choices (question_id, choice_text) VALUES (?, ?)",
-		c.QuestionID, c.Text,
+		"INSERT INTO choices (question_id, choice_text, position) VALUES (?, ?, ?)",
+		c.QuestionID, c.Text, position,
 	)
 	if err != nil {
-		return fmt.Errorf("CreateChoice: %v", err)
+		return fmt.Errorf("CreateChoice: %w", err)
 	}
 	// Retrieve the newly inserted ID
 	id, err := result.LastInsertId()
 	if err != nil {
-		return fmt.Errorf("CreateChoice LastInsertId: %v", err)
+		return fmt.Errorf("CreateChoice LastInsertId: %w", err)
 	}
 	c.ID = id
+	c.Position = position
+	c.Version = 1
 	return nil
 }
 
-// UpdateChoice updates the choice text for an existing record.
-func UpdateChoice(db *sql.DB, c *Choice) error {
-	_, err := db.Exec(
-		"UPDATE choices SET choice_text = ? WHERE id = ?",
-		c.Text, c.ID,
-	)
-	if err != nil {
-		return fmt.Errorf("UpdateChoice: %v", err)
-	}
-	return nil
+// UpdateChoice updates the choice text for an existing record, provided its
+// version still equals c.Version (0 skips the check).
+func UpdateChoice(db *sql.DB, c *Choice, author int64) error {
+	return withTx(db, func(tx *sql.Tx) error {
+		if err := requireDraftOfChoice(tx, c.ID); err != nil {
+			return err
+		}
+		result, err := tx.Exec(
+			"UPDATE choices SET choice_text = ?, version = version + 1 WHERE id = ? AND (? = 0 OR version = ?)"+liveCondition("choices"),
+			c.Text, c.ID, c.Version, c.Version,
+		)
+		if err != nil {
+			return fmt.Errorf("UpdateChoice: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return missingOrConflict(tx, "choices", c.ID)
+		}
+		if err := touchPollOfChoice(tx, c.ID); err != nil {
+			return err
+		}
+		pollID, err := pollOfChoice(tx, c.ID)
+		if err != nil {
+			return err
+		}
+		return recordRevision(tx, pollID, author, RevisionChoiceEdited)
+	})
 }
 
-// DeleteChoice deletes a choice by ID.
-func DeleteChoice(db *sql.DB, choiceID int64) error {
-	_, err := db.Exec("DELETE FROM choices WHERE id = ?", choiceID)
-	if err != nil {
-		return fmt.Errorf("DeleteChoice: %v", err)
-	}
-	return nil
+// DeleteChoice deletes a choice by ID, provided its version still equals
+// version (0 skips the check).
+func DeleteChoice(db *sql.DB, choiceID, version, author int64) error {
+	return withTx(db, func(tx *sql.Tx) error {
+		if err := requireDraftOfChoice(tx, choiceID); err != nil {
+			return err
+		}
+		// Touch the poll first; afterwards the choice is gone and cannot be joined.
+		if err := touchPollOfChoice(tx, choiceID); err != nil {
+			return err
+		}
+		pollID, err := pollOfChoice(tx, choiceID)
+		if err != nil {
+			return err
+		}
+		result, err := tx.Exec("DELETE FROM choices WHERE id = ? AND (? = 0 OR version = ?)"+liveCondition("choices"),
+			choiceID, version, version)
+		if err != nil {
+			return fmt.Errorf("DeleteChoice: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return missingOrConflict(tx, "choices", choiceID)
+		}
+		return recordRevision(tx, pollID, author, RevisionChoiceDeleted)
+	})
+}
+
+// ReorderChoices puts the choices of a question in the order of ids, which
+// must list every choice of the question exactly once, provided the version
+// of the poll owning the question still equals version (0 skips the check).
+// Choices are checked against the poll because the question's version does
+// not cover its choices. It returns ErrInvalidOrder or ErrVersionConflict
+// otherwise.
+func ReorderChoices(db *sql.DB, questionID int64, ids []int64, version, author int64) error {
+	return withTx(db, func(tx *sql.Tx) error {
+		if err := requireDraftOfQuestion(tx, questionID); err != nil {
+			return err
+		}
+		pollID, err := pollOfQuestion(tx, questionID)
+		if err != nil {
+			return err
+		}
+		if err := checkPollVersion(tx, pollID, version); err != nil {
+			return err
+		}
+		if err := reorder(tx, "choices", "questions", "question_id", questionID, ids); err != nil {
+			return err
+		}
+		if err := touchPoll(tx, pollID); err != nil {
+			return err
+		}
+		return recordRevision(tx, pollID, author, RevisionChoicesReordered)
+	})
 }
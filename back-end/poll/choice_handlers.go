@@ -2,11 +2,13 @@ package poll
 
 import (
 	"database/sql"
-	"encoding/json"
 	"log"
 	"net/http"
+	"reflect"
 	"strconv"
 	"strings"
+
+	"simple-poll/apierror"
 )
 
 // ChoiceRouter is the main entry point for /api/choices routes.
@@ -34,7 +36,7 @@ func ChoiceRouter(db *sql.DB) http.Handler {
 				getChoiceHandler(db, w, r, parts[0])
 				return
 			}
-			http.NotFound(w, r)
+			routeNotFound(w, r)
 
 		case http.MethodPost:
 			// POST /api/choices/ => create new choice
@@ -42,7 +44,7 @@ func ChoiceRouter(db *sql.DB) http.Handler {
 				createChoiceHandler(db, w, r)
 				return
 			}
-			http.NotFound(w, r)
+			routeNotFound(w, r)
 
 		case http.MethodPut:
 			// PUT /api/choices/123 => update
@@ -50,7 +52,7 @@ func ChoiceRouter(db *sql.DB) http.Handler {
 				updateChoiceHandler(db, w, r, parts[0])
 				return
 			}
-			http.NotFound(w, r)
+			routeNotFound(w, r)
 
 		case http.MethodDelete:
 			// DELETE /api/choices/123 => delete
@@ -58,18 +60,18 @@ func ChoiceRouter(db *sql.DB) http.Handler {
 				deleteChoiceHandler(db, w, r, parts[0])
 				return
 			}
-			http.NotFound(w, r)
+			routeNotFound(w, r)
 
 		default:
-			http.NotFound(w, r)
+			routeNotFound(w, r)
 		}
 	})
 
 	return mux
 }
 
-// listChoicesHandler handles listing all choices,
-// optionally you could filter by question_id if you want (using a query param).
+// listChoicesHandler handles listing choices one page at a time,
+// optionally filtered by question_id and by the poll filters (see parseListOptions).
 func listChoicesHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 	var questionID *int64
 
@@ -81,102 +83,202 @@ func listChoicesHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	choices, err := ListChoices(db, questionID)
+	opts, err := parseListOptions(queryValues)
+	if err != nil {
+		writeError(w, apierror.BadRequest(err.Error()))
+		return
+	}
+	fields, err := parseFields(r, reflect.TypeOf(Choice{}))
+	if err != nil {
+		writeError(w, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	choices, next, err := ListChoices(db, questionID, opts)
 	if err != nil {
 		log.Printf("Error listing choices: %v", err)
-		http.Error(w, "Failed to list choices", http.StatusInternalServerError)
+		writeError(w, storageError(err, "Failed to list choices"))
 		return
 	}
-	writeJSON(w, choices)
+	writePage(w, r, Page[Choice]{Items: choices, NextCursor: next}, fields)
 }
 
 // getChoiceHandler handles retrieving a single choice by ID.
 func getChoiceHandler(db *sql.DB, w http.ResponseWriter, r *http.Request, idParam string) {
 	id, err := strconv.ParseInt(idParam, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid choice ID", http.StatusBadRequest)
+		writeError(w, apierror.BadRequest("Invalid choice ID"))
 		return
 	}
 
 	choice, err := GetChoice(db, id)
 	if err != nil {
 		log.Printf("Error getting choice: %v", err)
-		http.Error(w, "Failed to get choice", http.StatusInternalServerError)
+		writeError(w, storageError(err, "Failed to get choice"))
 		return
 	}
 	if choice == nil {
-		http.NotFound(w, r)
+		writeError(w, apierror.NotFound("Choice not found"))
+		return
+	}
+	if notModified(w, r, choice.Version) {
 		return
 	}
-	writeJSON(w, choice)
+	writeJSON(w, r, choice)
 }
 
 // createChoiceHandler handles creating a new choice.
 func createChoiceHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 	var c Choice
-	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+	if err := decodeJSON(r, &c); err != nil {
 		log.Printf("Error decoding choice: %v", err)
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		writeError(w, apierror.BadRequest("Invalid request payload"))
 		return
 	}
 
-	// Here you may want to validate c.QuestionID, etc.
-	// For example:
-	// if c.QuestionID == 0 {
-	//     http.Error(w, "question_id is required", http.StatusBadRequest)
-	//     return
-	// }
+	if verr := validateChoice(db, &c, true, versionOf(r)); verr != nil {
+		writeError(w, verr)
+		return
+	}
 
-	if err := CreateChoice(db, &c); err != nil {
+	author, aerr := optionalCallerID(r)
+	if aerr != nil {
+		writeError(w, aerr)
+		return
+	}
+	if err := CreateChoice(db, &c, author); err != nil {
 		log.Printf("Error creating choice: %v", err)
-		http.Error(w, "Failed to create choice", http.StatusInternalServerError)
+		writeError(w, storageError(err, "Failed to create choice"))
 		return
 	}
 
-	writeJSON(w, c)
+	w.Header().Set("ETag", etag(r, c.Version))
+	writeJSON(w, r, c)
 }
 
 // updateChoiceHandler handles updating an existing choice's text.
+// It requires an If-Match header naming the choice's current ETag.
 func updateChoiceHandler(db *sql.DB, w http.ResponseWriter, r *http.Request, idParam string) {
 	id, err := strconv.ParseInt(idParam, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid choice ID", http.StatusBadRequest)
+		writeError(w, apierror.BadRequest("Invalid choice ID"))
+		return
+	}
+
+	version, verr := ifMatchVersion(r)
+	if verr != nil {
+		writeError(w, verr)
 		return
 	}
 
 	var c Choice
-	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+	if err := decodeJSON(r, &c); err != nil {
 		log.Printf("Error decoding choice: %v", err)
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		writeError(w, apierror.BadRequest("Invalid request payload"))
 		return
 	}
 
 	// We must ensure the ID from the URL path matches the ID in the payload
 	// or you can ignore payload ID and use only the URL's ID.
 	c.ID = id
+	c.Version = version
+
+	if verr := validateChoice(db, &c, false, versionOf(r)); verr != nil {
+		writeError(w, verr)
+		return
+	}
 
-	if err := UpdateChoice(db, &c); err != nil {
+	author, aerr := optionalCallerID(r)
+	if aerr != nil {
+		writeError(w, aerr)
+		return
+	}
+	if err := UpdateChoice(db, &c, author); err != nil {
 		log.Printf("Error updating choice: %v", err)
-		http.Error(w, "Failed to update choice", http.StatusInternalServerError)
+		writeError(w, storageError(err, "Failed to update choice"))
 		return
 	}
 
-	writeJSON(w, c)
+	// Re-read so the response carries the new version and untouched fields.
+	updated, err := GetChoice(db, id)
+	if err != nil || updated == nil {
+		log.Printf("Error getting updated choice: %v", err)
+		writeError(w, storageError(err, "Failed to get choice"))
+		return
+	}
+	w.Header().Set("ETag", etag(r, updated.Version))
+	writeJSON(w, r, updated)
 }
 
 // deleteChoiceHandler handles deleting a choice by ID.
+// It requires an If-Match header naming the choice's current ETag.
 func deleteChoiceHandler(db *sql.DB, w http.ResponseWriter, r *http.Request, idParam string) {
 	id, err := strconv.ParseInt(idParam, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid choice ID", http.StatusBadRequest)
+		writeError(w, apierror.BadRequest("Invalid choice ID"))
+		return
+	}
+
+	version, verr := ifMatchVersion(r)
+	if verr != nil {
+		writeError(w, verr)
 		return
 	}
 
-	if err := DeleteChoice(db, id); err != nil {
+	author, aerr := optionalCallerID(r)
+	if aerr != nil {
+		writeError(w, aerr)
+		return
+	}
+	if err := DeleteChoice(db, id, version, author); err != nil {
 		log.Printf("Error deleting choice: %v", err)
-		http.Error(w, "Failed to delete choice", http.StatusInternalServerError)
+		writeError(w, storageError(err, "Failed to delete choice"))
+		return
+	}
+
+	writeJSON(w, r, map[string]string{"message": "Choice deleted"})
+}
+
+// reorderChoicesHandler handles PUT /api/questions/123/choices/order with a
+// body like {"ids": [12, 10, 11]} listing every choice of the question.
+// If-Match must carry the ETag of the poll owning the question. It answers
+// with the choices in their new order.
+func reorderChoicesHandler(db *sql.DB, w http.ResponseWriter, r *http.Request, idParam string) {
+	questionID, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		writeError(w, apierror.BadRequest("Invalid question ID"))
+		return
+	}
+
+	var req OrderRequest
+	if err := decodeJSON(r, &req); err != nil {
+		log.Printf("Error decoding choice order: %v", err)
+		writeError(w, apierror.BadRequest("Invalid request payload"))
 		return
 	}
 
-	writeJSON(w, map[string]string{"message": "Choice deleted"})
+	version, verr := ifMatchVersion(r)
+	if verr != nil {
+		writeError(w, verr)
+		return
+	}
+
+	author, aerr := optionalCallerID(r)
+	if aerr != nil {
+		writeError(w, aerr)
+		return
+	}
+	if err := ReorderChoices(db, questionID, req.IDs, version, author); err != nil {
+		log.Printf("Error reordering choices: %v", err)
+		writeError(w, storageError(err, "Failed to reorder choices"))
+		return
+	}
+
+	choices, next, err := ListChoices(db, &questionID, ListOptions{Sort: "position", Limit: maxPageSize})
+	if err != nil {
+		log.Printf("Error listing choices: %v", err)
+		writeError(w, storageError(err, "Failed to list choices"))
+		return
+	}
+	writeJSON(w, r, Page[Choice]{Items: choices, NextCursor: next})
 }
@@ -0,0 +1,118 @@
+package poll
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// CloneRequest is the body of POST /polls/{id}/clone; every field is
+// optional. StartDate and ShiftDays both move the copy's dates, so at most
+// one of them may be given.
+type CloneRequest struct {
+	Title           string     `json:"title"`
+	StartDate       *time.Time `json:"start_date"`
+	ShiftDays       int        `json:"shift_days"`
+	DropDescription bool       `json:"drop_description"`
+}
+
+// CloneOptions controls how ClonePoll copies a poll.
+type CloneOptions struct {
+	// Owner becomes created_by of the copy.
+	Owner int64
+	// Title replaces the source's title when set.
+	Title string
+	// StartDate moves the copy to start then, keeping the source's duration.
+	StartDate *time.Time
+	// Shift moves both dates by this much; it is ignored when StartDate is set.
+	Shift time.Duration
+	// DropDescription leaves the copy's description empty.
+	DropDescription bool
+}
+
+// ClonePoll deep-copies a poll, with its questions and choices in ballot
+// order, into a new draft owned by opts.Owner, in a single transaction. Any
+// visible poll can be cloned whatever its state; the recurrence rule and the
+// series link are not copied. It returns ErrNotFound when the source is
+// missing or in the trash.
+func ClonePoll(db *sql.DB, sourceID int64, opts CloneOptions) (*Poll, error) {
+	var clone *Poll
+	err := withTx(db, func(tx *sql.Tx) error {
+		var source Poll
+		err := tx.QueryRow(`
+			SELECT id, title, description, start_date, end_date
+			FROM polls
+			WHERE id = ? AND deleted_at IS NULL`, sourceID,
+		).Scan(&source.ID, &source.Title, &source.Description, &source.StartDate, &source.EndDate)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("poll %w", ErrNotFound)
+		}
+		if err != nil {
+			return fmt.Errorf("ClonePoll: %w", err)
+		}
+
+		sources := []Poll{source}
+		if err := loadQuestions(tx, sources, true); err != nil {
+			return err
+		}
+
+		p := &Poll{
+			Title:       source.Title,
+			Description: source.Description,
+			CreatedBy:   opts.Owner,
+			StartDate:   source.StartDate,
+			EndDate:     source.EndDate,
+			Questions:   copyQuestions(sources[0].Questions),
+		}
+		if opts.Title != "" {
+			p.Title = opts.Title
+		}
+		if opts.DropDescription {
+			p.Description = ""
+		}
+		shift := opts.Shift
+		if opts.StartDate != nil {
+			if source.StartDate == nil {
+				p.StartDate, shift = opts.StartDate, 0
+			} else {
+				shift = opts.StartDate.Sub(*source.StartDate)
+			}
+		}
+		p.StartDate = shiftDate(p.StartDate, shift)
+		p.EndDate = shiftDate(p.EndDate, shift)
+
+		if err := createPollTree(tx, p); err != nil {
+			return err
+		}
+		if err := recordRevision(tx, p.ID, opts.Owner, RevisionCreated); err != nil {
+			return err
+		}
+		clone = p
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return clone, nil
+}
+
+func shiftDate(t *time.Time, d time.Duration) *time.Time {
+	if t == nil || d == 0 {
+		return t
+	}
+	shifted := t.Add(d)
+	return &shifted
+}
+
+// copyQuestions returns questions and their choices stripped of IDs, ready
+// to be created under another poll in the same order.
+func copyQuestions(questions []Question) []Question {
+	out := make([]Question, len(questions))
+	for i, q := range questions {
+		out[i] = Question{Text: q.Text, Choices: make([]Choice, len(q.Choices))}
+		for j, c := range q.Choices {
+			out[i].Choices[j] = Choice{Text: c.Text}
+		}
+	}
+	return out
+}
@@ -0,0 +1,100 @@
+package poll
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// dbtx is satisfied by both *sql.DB and *sql.Tx, so the same data functions
+// can run on their own or as part of a larger transaction.
+type dbtx interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// withTx runs fn inside a transaction, committing if it succeeds and rolling
+// back otherwise.
+func withTx(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	return nil
+}
+
+// ErrVersionConflict is returned when a row changed since the version the
+// caller based its update or delete on.
+var ErrVersionConflict = errors.New("version conflict")
+
+// missingOrConflict explains why a versioned update or delete touched no rows.
+func missingOrConflict(db dbtx, table string, id int64) error {
+	ok, err := rowExists(db, table, id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("no rows changed; %s %w", strings.TrimSuffix(table, "s"), ErrNotFound)
+	}
+	return ErrVersionConflict
+}
+
+// checkPollVersion locks a visible poll and refuses the change unless its
+// version still equals version (0 skips the check). Changes to the poll's
+// children that have no version of their own are checked against it.
+func checkPollVersion(tx dbtx, pollID, version int64) error {
+	_, current, err := lockPollState(tx, pollID)
+	if err != nil {
+		return err
+	}
+	if version != 0 && version != current {
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+// touchPoll bumps the version of a poll so its ETag changes whenever any part
+// of the poll tree does.
+func touchPoll(db dbtx, pollID int64) error {
+	_, err := db.Exec("UPDATE polls SET version = version + 1 WHERE id = ?", pollID)
+	if err != nil {
+		return fmt.Errorf("touchPoll: %w", err)
+	}
+	return nil
+}
+
+// touchPollOfQuestion bumps the version of the poll owning a question.
+func touchPollOfQuestion(db dbtx, questionID int64) error {
+	_, err := db.Exec(`
+		UPDATE polls p
+		JOIN questions q ON q.poll_id = p.id
+		SET p.version = p.version + 1
+		WHERE q.id = ?`, questionID)
+	if err != nil {
+		return fmt.Errorf("touchPollOfQuestion: %w", err)
+	}
+	return nil
+}
+
+// touchPollOfChoice bumps the version of the poll owning a choice.
+func touchPollOfChoice(db dbtx, choiceID int64) error {
+	_, err := db.Exec(`
+		UPDATE polls p
+		JOIN questions q ON q.poll_id = p.id
+		JOIN choices c ON c.question_id = q.id
+		SET p.version = p.version + 1
+		WHERE c.id = ?`, choiceID)
+	if err != nil {
+		return fmt.Errorf("touchPollOfChoice: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package poll
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"simple-poll/apierror"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// ErrNotFound is returned by data functions when the target row does not exist.
+var ErrNotFound = errors.New("not found")
+
+// MySQL error numbers that describe a client mistake rather than an outage.
+const (
+	mysqlDuplicateEntry    = 1062
+	mysqlRowIsReferenced   = 1451
+	mysqlNoReferencedRow   = 1452
+	mysqlNoReferencedRowV1 = 1216
+)
+
+// storageError maps an error from the data functions to the API error it
+// should produce. Anything unrecognized becomes a 500 with message.
+func storageError(err error, message string) *apierror.Error {
+	var apiErr *apierror.Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	if errors.Is(err, ErrNotFound) {
+		return apierror.NotFound(err.Error())
+	}
+	if errors.Is(err, ErrVersionConflict) {
+		return apierror.PreconditionFailed("The resource was changed by someone else; fetch it again and retry")
+	}
+	if errors.Is(err, ErrInvalidListOptions) {
+		return apierror.BadRequest(err.Error())
+	}
+	if errors.Is(err, ErrInvalidState) {
+		return apierror.Conflict(err.Error())
+	}
+	if errors.Is(err, ErrSlugTaken) {
+		return apierror.Conflict(err.Error())
+	}
+	if errors.Is(err, ErrInvalidOrder) {
+		return apierror.Validation("Request validation failed", apierror.FieldError{Field: "ids", Message: err.Error()})
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case mysqlDuplicateEntry:
+			return apierror.Conflict("A record with the same unique value already exists")
+		case mysqlRowIsReferenced:
+			return apierror.Conflict("The record is still referenced by other records")
+		case mysqlNoReferencedRow, mysqlNoReferencedRowV1:
+			return apierror.Validation("A referenced parent record does not exist")
+		}
+	}
+	return apierror.Internal(err, message)
+}
+
+// writeError sends e as a JSON error envelope, logging the cause of 500s.
+func writeError(w http.ResponseWriter, e *apierror.Error) {
+	if e.Status >= http.StatusInternalServerError && e.Err != nil {
+		log.Printf("Internal error (request %s): %v", w.Header().Get(apierror.RequestIDHeader), e.Err)
+	}
+	apierror.Write(w, e)
+}
+
+// routeNotFound answers requests that match no route of a router.
+func routeNotFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, apierror.RouteNotFound("No route for "+r.Method+" "+r.RequestURI))
+}
@@ -0,0 +1,64 @@
+package poll
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"simple-poll/apierror"
+)
+
+// etag builds the strong ETag of a resource at the given row version. The API
+// version is part of the tag because v1 and v2 bodies differ byte for byte.
+func etag(r *http.Request, version int64) string {
+	return `"` + versionOf(r).Name + "." + strconv.FormatInt(version, 10) + `"`
+}
+
+// notModified sets the ETag header and reports whether the client's
+// If-None-Match already names it, in which case a 304 has been written.
+func notModified(w http.ResponseWriter, r *http.Request, version int64) bool {
+	tag := etag(r, version)
+	w.Header().Set("ETag", tag)
+
+	inm := r.Header.Get("If-None-Match")
+	if inm == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(inm, ",") {
+		candidate = strings.TrimSpace(candidate)
+		// If-None-Match uses the weak comparison, so W/ prefixes are ignored.
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == tag {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatchVersion returns the row version a PUT, PATCH or DELETE is based on,
+// taken from its If-Match header. "*" yields 0, meaning any version. A
+// missing header is refused with 428 so that no write silently overwrites
+// someone else's change.
+func ifMatchVersion(r *http.Request) (int64, *apierror.Error) {
+	im := strings.TrimSpace(r.Header.Get("If-Match"))
+	if im == "" {
+		return 0, apierror.PreconditionRequired("If-Match header is required; send the ETag from a previous GET")
+	}
+
+	prefix := `"` + versionOf(r).Name + "."
+	for _, candidate := range strings.Split(im, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return 0, nil
+		}
+		// If-Match uses the strong comparison, so weak tags never match.
+		if !strings.HasPrefix(candidate, prefix) || !strings.HasSuffix(candidate, `"`) {
+			continue
+		}
+		v, err := strconv.ParseInt(candidate[len(prefix):len(candidate)-1], 10, 64)
+		if err == nil && v > 0 {
+			return v, nil
+		}
+	}
+	return 0, apierror.PreconditionFailed("If-Match does not match the current ETag")
+}
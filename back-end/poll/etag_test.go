@@ -0,0 +1,68 @@
+package poll
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// ifMatchExempt lists the PUT, PATCH and DELETE routes that may be sent
+// without If-Match.
+var ifMatchExempt = map[string]bool{
+	// Templates cannot be edited, so they carry no version to match.
+	"deleteTemplate": true,
+}
+
+// Every PUT, PATCH and DELETE changes a versioned resource, so a client that
+// does not say which version it saw is refused before anything is written.
+func TestWritesRequireIfMatch(t *testing.T) {
+	v := Versions[0]
+	for _, rt := range Routes {
+		if rt.Method != http.MethodPut && rt.Method != http.MethodPatch && rt.Method != http.MethodDelete {
+			continue
+		}
+		if ifMatchExempt[rt.OperationID] {
+			continue
+		}
+		f := &fakeDB{}
+		r := routeRequest(t, rt, v)
+		r.Header.Del("If-Match")
+		rec := httptest.NewRecorder()
+		testAPI(openFakeDB(f), v).ServeHTTP(rec, r)
+		if rec.Code != http.StatusPreconditionRequired {
+			t.Errorf("%s %s without If-Match: status %d, want 428", rt.Method, rt.Path, rec.Code)
+		}
+		if f.count() != 0 {
+			t.Errorf("%s %s without If-Match ran %d statements, want none", rt.Method, rt.Path, f.count())
+		}
+	}
+}
+
+// Reordering checks If-Match against the version of the poll, which the
+// fake database reports as 1.
+func TestReorderChecksPollVersion(t *testing.T) {
+	v := Versions[0]
+	for _, id := range []string{"reorderQuestions", "reorderChoices"} {
+		var rt Route
+		for _, r := range Routes {
+			if r.OperationID == id {
+				rt = r
+			}
+		}
+		for _, tt := range []struct {
+			ifMatch string
+			want    int
+		}{
+			{`"` + v.Name + `.1"`, http.StatusOK},
+			{`"` + v.Name + `.2"`, http.StatusPreconditionFailed},
+		} {
+			r := routeRequest(t, rt, v)
+			r.Header.Set("If-Match", tt.ifMatch)
+			rec := httptest.NewRecorder()
+			testAPI(openFakeDB(&fakeDB{}), v).ServeHTTP(rec, r)
+			if rec.Code != tt.want {
+				t.Errorf("%s with If-Match %s: status %d, want %d; body %s", id, tt.ifMatch, rec.Code, tt.want, rec.Body)
+			}
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package poll
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fakeTime is the value of every date column of a fakeDB.
+var fakeTime = time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+
+// fakeDB answers every statement without a database, so tests can run the
+// handlers through the real data functions. A SELECT returns a single row
+// whose values are made up from the names of the selected columns; every
+// other statement affects one row with ID 1.
+type fakeDB struct {
+	mu sync.Mutex
+	// columns overrides the made-up value of a column, by name without any
+	// table prefix, e.g. "state".
+	columns map[string]driver.Value
+	// rows is the number of rows every SELECT returns; zero means one. The
+	// id column counts up from 1 across them.
+	rows int
+	// latency is slept on every statement, standing in for the round trip
+	// to MySQL.
+	latency time.Duration
+	// queries counts the statements run, writes included.
+	queries int
+}
+
+// openFakeDB returns a *sql.DB backed by f.
+func openFakeDB(f *fakeDB) *sql.DB {
+	return sql.OpenDB(f)
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return fakeConn{f}, nil }
+func (f *fakeDB) Open(string) (driver.Conn, error)             { return fakeConn{f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return f }
+
+func (f *fakeDB) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.queries
+}
+
+// roundTrip counts a statement and waits out the latency.
+func (f *fakeDB) roundTrip() {
+	f.mu.Lock()
+	f.queries++
+	latency := f.latency
+	f.mu.Unlock()
+	time.Sleep(latency)
+}
+
+// value makes up the value of a selected column from its expression.
+func (f *fakeDB) value(expr string) driver.Value {
+	name := expr
+	if i := strings.LastIndex(name, " as "); i >= 0 {
+		name = name[i+len(" as "):]
+	}
+	if i := strings.LastIndex(name, "."); i >= 0 && !strings.Contains(name, "(") {
+		name = name[i+1:]
+	}
+	name = strings.TrimSpace(name)
+
+	f.mu.Lock()
+	v, ok := f.columns[name]
+	f.mu.Unlock()
+	if ok {
+		return v
+	}
+
+	switch {
+	case strings.HasPrefix(expr, "count("):
+		return int64(2)
+	case name == "1":
+		return int64(1)
+	case strings.HasSuffix(name, "state"):
+		return string(StateDraft)
+	case name == "snapshot":
+		snapshot, _ := json.Marshal(Poll{ID: 1, Title: "Team lunch", State: StateDraft, Version: 1, Tags: []string{}})
+		return snapshot
+	case name == "definition":
+		definition, _ := json.Marshal(templateBody{Title: "Team lunch", DurationDays: 7})
+		return definition
+	case name == "recurrence":
+		return "FREQ=WEEKLY"
+	case name == "kind":
+		return string(TransitionOpened)
+	case name == "action":
+		return RevisionCreated
+	case name == "slug":
+		return "team-lunch"
+	case name == "tags":
+		return []byte(`["lunch"]`)
+	case strings.HasSuffix(name, "_at") || strings.HasSuffix(name, "date") || name == "due" ||
+		strings.Contains(expr, "created_at"):
+		return fakeTime
+	case strings.HasSuffix(name, "id") || strings.HasSuffix(name, "_by") || strings.Contains(name, "version") ||
+		strings.Contains(name, "position") || name == "uses" || name == "votes":
+		return int64(1)
+	case strings.Contains(expr, "match("):
+		return float64(1)
+	}
+	return "lunch"
+}
+
+type fakeConn struct{ db *fakeDB }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return fakeStmt{db: c.db, query: query}, nil
+}
+func (c fakeConn) Close() error              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.roundTrip()
+	return fakeResult{}, nil
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.db.roundTrip()
+	s.db.mu.Lock()
+	n := max(s.db.rows, 1)
+	s.db.mu.Unlock()
+
+	exprs := selectList(s.query)
+	rows := &fakeRows{columns: exprs, rows: make([][]driver.Value, n)}
+	for i := range rows.rows {
+		for _, expr := range exprs {
+			v := s.db.value(expr)
+			if expr == "id" {
+				v = int64(i + 1)
+			}
+			rows.rows[i] = append(rows.rows[i], v)
+		}
+	}
+	return rows, nil
+}
+
+// fakeResult reports one row affected, inserted with ID 1.
+type fakeResult struct{}
+
+func (fakeResult) LastInsertId() (int64, error) { return 1, nil }
+func (fakeResult) RowsAffected() (int64, error) { return 1, nil }
+
+// selectList returns the lower-cased expressions selected by the first
+// SELECT of query.
+func selectList(query string) []string {
+	q := strings.Join(strings.Fields(strings.ToLower(query)), " ")
+	start := strings.Index(q, "select ")
+	if start < 0 {
+		return nil
+	}
+	q = strings.TrimPrefix(q[start+len("select "):], "distinct ")
+
+	var exprs []string
+	depth, last := 0, 0
+	for i := 0; i < len(q); i++ {
+		switch q[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				exprs = append(exprs, strings.TrimSpace(q[last:i]))
+				last = i + 1
+			}
+		case ' ':
+			if depth == 0 && strings.HasPrefix(q[i:], " from ") {
+				return append(exprs, strings.TrimSpace(q[last:i]))
+			}
+		}
+	}
+	return append(exprs, strings.TrimSpace(q[last:]))
+}
+
+// fakeRows holds the rows of a SELECT.
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
@@ -0,0 +1,91 @@
+package poll
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// parseFields reads a sparse fieldset such as ?fields=id,title from a list
+// request. Names are those of the request's API version and must be JSON
+// fields of item; nil means every field.
+func parseFields(r *http.Request, item reflect.Type) ([]string, error) {
+	raw := r.URL.Query().Get("fields")
+	if raw == "" {
+		return nil, nil
+	}
+
+	v := versionOf(r)
+	var fields []string
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		goName := name
+		if original, ok := v.reverse[item][name]; ok {
+			goName = original
+		} else if _, renamed := v.renames[item][name]; renamed {
+			// The field exists, but this version calls it something else.
+			return nil, fmt.Errorf("unknown field %q", name)
+		}
+		if _, ok := jsonFieldType(item, goName); !ok {
+			return nil, fmt.Errorf("unknown field %q", name)
+		}
+		fields = append(fields, name)
+	}
+	return fields, nil
+}
+
+// writePage writes a list page, keeping only the given fields of each item
+// when fields is not nil. Embedded relations named in keep survive either way,
+// so ?include= never needs repeating in ?fields=.
+func writePage(w http.ResponseWriter, r *http.Request, page interface{}, fields []string, keep ...string) {
+	if fields == nil {
+		writeJSON(w, r, page)
+		return
+	}
+
+	out, err := encodeJSON(r, page)
+	if err == nil {
+		out, err = selectFields(out, append(fields, keep...))
+	}
+	if err != nil {
+		writeError(w, storageError(err, "Failed to encode response"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(out, '\n'))
+}
+
+// selectFields drops every key not in fields from the items of an encoded
+// page. Numbers keep their exact text.
+func selectFields(page []byte, fields []string) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(page))
+	dec.UseNumber()
+	var doc map[string]interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		wanted[f] = true
+	}
+	items, _ := doc["items"].([]interface{})
+	for _, item := range items {
+		obj, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for k := range obj {
+			if !wanted[k] {
+				delete(obj, k)
+			}
+		}
+	}
+	return json.Marshal(doc)
+}
@@ -0,0 +1,181 @@
+package poll
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PollState is where a poll is in its lifecycle. A poll is built as a draft,
+// published to open it for voting, closed when voting is over and finally
+// archived, after which it is read-only.
+type PollState string
+
+const (
+	StateDraft     PollState = "draft"
+	StatePublished PollState = "published"
+	StateClosed    PollState = "closed"
+	StateArchived  PollState = "archived"
+)
+
+// pollTransitions lists the states each state may move to.
+var pollTransitions = map[PollState][]PollState{
+	StateDraft:     {StatePublished},
+	StatePublished: {StateClosed},
+	StateClosed:    {StateArchived},
+}
+
+// validPollState reports whether s names a lifecycle state.
+func validPollState(s PollState) bool {
+	switch s {
+	case StateDraft, StatePublished, StateClosed, StateArchived:
+		return true
+	}
+	return false
+}
+
+// ErrInvalidState is returned when an operation is not allowed in the poll's
+// current lifecycle state; handlers report it as a conflict.
+var ErrInvalidState = errors.New("invalid poll state")
+
+// AcceptsVotes reports whether ballots may be cast on the poll at now: it
+// must be published and inside its start and end dates. There is no voting
+// path yet: whatever records ballots must check it, under the poll row lock,
+// before writing a vote.
+func (p *Poll) AcceptsVotes(now time.Time) bool {
+	if p.State != StatePublished {
+		return false
+	}
+	if p.StartDate != nil && now.Before(*p.StartDate) {
+		return false
+	}
+	if p.EndDate != nil && !now.Before(*p.EndDate) {
+		return false
+	}
+	return true
+}
+
+// TransitionPoll moves a poll to state to, provided the move is allowed and
+// its version still equals version (0 skips the check). Publishing requires
+// at least one question, and at least two choices for every question.
+func TransitionPoll(db *sql.DB, pollID int64, to PollState, version, author int64) error {
+	return withTx(db, func(tx *sql.Tx) error {
+		from, current, err := lockPollState(tx, pollID)
+		if err != nil {
+			return err
+		}
+		if version != 0 && version != current {
+			return ErrVersionConflict
+		}
+
+		allowed := false
+		for _, next := range pollTransitions[from] {
+			allowed = allowed || next == to
+		}
+		if !allowed {
+			return fmt.Errorf("%w: a %s poll cannot become %s", ErrInvalidState, from, to)
+		}
+		if to == StatePublished {
+			if err := checkPublishable(tx, pollID); err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.Exec("UPDATE polls SET state = ?, version = version + 1 WHERE id = ?", to, pollID)
+		if err != nil {
+			return fmt.Errorf("TransitionPoll: %w", err)
+		}
+		return recordRevision(tx, pollID, author, string(to))
+	})
+}
+
+// lockPollState reads the state and version of a visible poll and locks its
+// row until the transaction ends, so the state cannot change underneath.
+func lockPollState(tx dbtx, pollID int64) (PollState, int64, error) {
+	var state PollState
+	var version int64
+	err := tx.QueryRow(
+		"SELECT state, version FROM polls WHERE id = ? AND deleted_at IS NULL FOR UPDATE", pollID,
+	).Scan(&state, &version)
+	if err == sql.ErrNoRows {
+		return "", 0, fmt.Errorf("poll %w", ErrNotFound)
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("lockPollState: %w", err)
+	}
+	return state, version, nil
+}
+
+// checkPublishable refuses to publish a poll nobody could vote on.
+func checkPublishable(tx dbtx, pollID int64) error {
+	rows, err := tx.Query(`
+		SELECT q.id, COUNT(c.id)
+		FROM questions q
+		LEFT JOIN choices c ON c.question_id = q.id
+		WHERE q.poll_id = ?
+		GROUP BY q.id`, pollID)
+	if err != nil {
+		return fmt.Errorf("checkPublishable: %w", err)
+	}
+	defer rows.Close()
+
+	questions := 0
+	for rows.Next() {
+		var questionID int64
+		var choices int
+		if err := rows.Scan(&questionID, &choices); err != nil {
+			return fmt.Errorf("checkPublishable scan: %w", err)
+		}
+		if choices < 2 {
+			return fmt.Errorf("%w: question %d needs at least two choices before publishing", ErrInvalidState, questionID)
+		}
+		questions++
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("checkPublishable: %w", err)
+	}
+	if questions == 0 {
+		return fmt.Errorf("%w: a poll needs at least one question before publishing", ErrInvalidState)
+	}
+	return nil
+}
+
+// requireDraft refuses structural changes to a poll that is no longer a
+// draft, locking the poll row so it cannot be published meanwhile. A missing
+// poll is left for the caller's own statement to report.
+func requireDraft(tx dbtx, pollID int64) error {
+	return requireDraftWhere(tx, "SELECT state FROM polls WHERE id = ? FOR UPDATE", pollID)
+}
+
+// requireDraftOfQuestion is requireDraft for the poll owning a question.
+func requireDraftOfQuestion(tx dbtx, questionID int64) error {
+	return requireDraftWhere(tx, `
+		SELECT p.state FROM polls p
+		JOIN questions q ON q.poll_id = p.id
+		WHERE q.id = ? FOR UPDATE`, questionID)
+}
+
+// requireDraftOfChoice is requireDraft for the poll owning a choice.
+func requireDraftOfChoice(tx dbtx, choiceID int64) error {
+	return requireDraftWhere(tx, `
+		SELECT p.state FROM polls p
+		JOIN questions q ON q.poll_id = p.id
+		JOIN choices c ON c.question_id = q.id
+		WHERE c.id = ? FOR UPDATE`, choiceID)
+}
+
+func requireDraftWhere(tx dbtx, query string, id int64) error {
+	var state PollState
+	err := tx.QueryRow(query, id).Scan(&state)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("requireDraft: %w", err)
+	}
+	if state != StateDraft {
+		return fmt.Errorf("%w: the poll is %s; questions and choices can only change while it is a draft", ErrInvalidState, state)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package poll
+
+import (
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAcceptsVotes(t *testing.T) {
+	start := fakeTime
+	end := fakeTime.AddDate(0, 0, 7)
+
+	tests := []struct {
+		name       string
+		state      PollState
+		start, end *time.Time
+		now        time.Time
+		want       bool
+	}{
+		{"published without dates", StatePublished, nil, nil, fakeTime, true},
+		{"inside the dates", StatePublished, &start, &end, fakeTime.Add(time.Hour), true},
+		{"at the start date", StatePublished, &start, &end, start, true},
+		{"before the start date", StatePublished, &start, &end, start.Add(-time.Second), false},
+		{"at the end date", StatePublished, &start, &end, end, false},
+		{"after the end date", StatePublished, &start, &end, end.Add(time.Hour), false},
+		{"open-ended", StatePublished, &start, nil, end.AddDate(1, 0, 0), true},
+		{"no start date", StatePublished, nil, &end, start.AddDate(-1, 0, 0), true},
+		{"draft", StateDraft, nil, nil, fakeTime, false},
+		{"closed", StateClosed, &start, &end, fakeTime.Add(time.Hour), false},
+		{"archived", StateArchived, nil, nil, fakeTime, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Poll{State: tt.state, StartDate: tt.start, EndDate: tt.end}
+			if got := p.AcceptsVotes(tt.now); got != tt.want {
+				t.Errorf("AcceptsVotes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransitionPoll(t *testing.T) {
+	states := []PollState{StateDraft, StatePublished, StateClosed, StateArchived}
+	allowed := map[[2]PollState]bool{
+		{StateDraft, StatePublished}:  true,
+		{StatePublished, StateClosed}: true,
+		{StateClosed, StateArchived}:  true,
+	}
+
+	for _, from := range states {
+		for _, to := range states {
+			db := openFakeDB(&fakeDB{columns: map[string]driver.Value{"state": string(from)}})
+			err := TransitionPoll(db, 1, to, 0, 1)
+			switch {
+			case allowed[[2]PollState{from, to}] && err != nil:
+				t.Errorf("%s -> %s: %v", from, to, err)
+			case !allowed[[2]PollState{from, to}] && !errors.Is(err, ErrInvalidState):
+				t.Errorf("%s -> %s: err = %v, want ErrInvalidState", from, to, err)
+			}
+		}
+	}
+
+	if next := pollTransitions[StateArchived]; len(next) != 0 {
+		t.Errorf("archived polls may become %v; archived is final", next)
+	}
+}
+
+func TestTransitionPollChecksVersion(t *testing.T) {
+	db := openFakeDB(&fakeDB{}) // a draft at version 1
+	if err := TransitionPoll(db, 1, StatePublished, 2, 1); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("err = %v, want ErrVersionConflict", err)
+	}
+}
@@ -0,0 +1,326 @@
+package poll
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+// Page is the response body of every list endpoint. NextCursor is empty on
+// the last page.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// PollFilter narrows a listing by properties of the owning poll. Questions and
+// choices are filtered through their poll.
+type PollFilter struct {
+	CreatedBy *int64
+	// Status is "active", "upcoming" or "closed"; empty means any. It looks
+	// at the dates only; State is the lifecycle state.
+	Status        string
+	State         PollState
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	StartsAfter   *time.Time
+	StartsBefore  *time.Time
+	EndsAfter     *time.Time
+	EndsBefore    *time.Time
+	// SeriesID selects the occurrences of a recurring poll.
+	SeriesID *int64
+	// Tags selects polls carrying every one of these tags.
+	Tags []string
+	// Trashed selects polls in the trash instead of hiding them.
+	Trashed bool
+}
+
+// Includes names the related rows a list embeds in each item.
+type Includes struct {
+	Questions bool
+	// Choices embeds the choices of each question, and implies Questions
+	// for poll listings.
+	Choices bool
+}
+
+// ListOptions controls filtering, sorting and keyset pagination of lists.
+type ListOptions struct {
+	Filter PollFilter
+	// Sort is a sort key, prefixed with "-" for descending order.
+	Sort    string
+	Limit   int
+	Cursor  string
+	Include Includes
+}
+
+// pageCursor is the opaque position after the last row of a page.
+type pageCursor struct {
+	Sort string     `json:"s"`
+	ID   int64      `json:"id"`
+	Time *time.Time `json:"t,omitempty"`
+	Text *string    `json:"x,omitempty"`
+	Num  *int64     `json:"n,omitempty"`
+	// Null is set when the last row's sort value is NULL.
+	Null bool `json:"z,omitempty"`
+}
+
+// ErrInvalidListOptions is returned by the List functions when the sort field
+// or cursor cannot be used; handlers report it as a bad request.
+var ErrInvalidListOptions = errors.New("invalid list options")
+
+var errInvalidCursor = fmt.Errorf("%w: invalid cursor", ErrInvalidListOptions)
+
+func encodeCursor(c pageCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (*pageCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	var c pageCursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, errInvalidCursor
+	}
+	return &c, nil
+}
+
+// listQuery accumulates WHERE conditions and their arguments.
+type listQuery struct {
+	where []string
+	args  []interface{}
+}
+
+func (q *listQuery) add(cond string, args ...interface{}) {
+	q.where = append(q.where, cond)
+	q.args = append(q.args, args...)
+}
+
+func (q *listQuery) whereClause() string {
+	if len(q.where) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(q.where, " AND ")
+}
+
+// addPollFilter applies f to the polls table aliased as p.
+func (q *listQuery) addPollFilter(f PollFilter) {
+	if f.Trashed {
+		q.add("p.deleted_at IS NOT NULL")
+	} else {
+		q.add("p.deleted_at IS NULL")
+	}
+	if f.CreatedBy != nil {
+		q.add("p.created_by = ?", *f.CreatedBy)
+	}
+	if f.State != "" {
+		q.add("p.state = ?", f.State)
+	}
+	if f.SeriesID != nil {
+		q.add("p.series_id = ?", *f.SeriesID)
+	}
+	for _, tag := range f.Tags {
+		q.add(`EXISTS (
+			SELECT 1 FROM poll_tags pt JOIN tags t ON t.id = pt.tag_id
+			WHERE pt.poll_id = p.id AND t.name = ?)`, tag)
+	}
+	switch f.Status {
+	case "active":
+		q.add("((p.start_date IS NULL OR p.start_date <= NOW()) AND (p.end_date IS NULL OR p.end_date > NOW()))")
+	case "upcoming":
+		q.add("p.start_date > NOW()")
+	case "closed":
+		q.add("p.end_date <= NOW()")
+	}
+	if f.CreatedAfter != nil {
+		q.add("p.created_at >= ?", *f.CreatedAfter)
+	}
+	if f.CreatedBefore != nil {
+		q.add("p.created_at < ?", *f.CreatedBefore)
+	}
+	if f.StartsAfter != nil {
+		q.add("p.start_date >= ?", *f.StartsAfter)
+	}
+	if f.StartsBefore != nil {
+		q.add("p.start_date < ?", *f.StartsBefore)
+	}
+	if f.EndsAfter != nil {
+		q.add("p.end_date >= ?", *f.EndsAfter)
+	}
+	if f.EndsBefore != nil {
+		q.add("p.end_date < ?", *f.EndsBefore)
+	}
+}
+
+// page adds the keyset condition for opts.Cursor and returns the ORDER BY and
+// LIMIT clauses. columns maps public sort keys to SQL expressions, and idExpr
+// is the unique column used to break ties. One row more than the limit is
+// requested so the caller can tell whether a next page exists.
+func (q *listQuery) page(opts ListOptions, columns map[string]string, idExpr string) (string, error) {
+	key, desc := strings.TrimPrefix(opts.Sort, "-"), strings.HasPrefix(opts.Sort, "-")
+	expr, ok := columns[key]
+	if !ok {
+		return "", fmt.Errorf("%w: unknown sort field %q", ErrInvalidListOptions, key)
+	}
+
+	cmp, dir := ">", "ASC"
+	if desc {
+		cmp, dir = "<", "DESC"
+	}
+
+	if opts.Cursor != "" {
+		c, err := decodeCursor(opts.Cursor)
+		if err != nil {
+			return "", err
+		}
+		if c.Sort != opts.Sort {
+			return "", errInvalidCursor
+		}
+		// MySQL sorts NULL below every value: NULLs lead an ascending listing
+		// and trail a descending one.
+		switch {
+		case expr == idExpr:
+			q.add(fmt.Sprintf("%s %s ?", idExpr, cmp), c.ID)
+		case c.Null && desc:
+			q.add(fmt.Sprintf("(%s IS NULL AND %s < ?)", expr, idExpr), c.ID)
+		case c.Null:
+			q.add(fmt.Sprintf("((%s IS NULL AND %s > ?) OR %s IS NOT NULL)", expr, idExpr, expr), c.ID)
+		case c.Time != nil && desc:
+			q.add(fmt.Sprintf("(%s < ? OR (%s = ? AND %s < ?) OR %s IS NULL)", expr, expr, idExpr, expr),
+				*c.Time, *c.Time, c.ID)
+		case c.Time != nil:
+			q.add(fmt.Sprintf("(%s > ? OR (%s = ? AND %s > ?))", expr, expr, idExpr),
+				*c.Time, *c.Time, c.ID)
+		case c.Text != nil:
+			q.add(fmt.Sprintf("(%s %s ? OR (%s = ? AND %s %s ?))", expr, cmp, expr, idExpr, cmp),
+				*c.Text, *c.Text, c.ID)
+		case c.Num != nil:
+			q.add(fmt.Sprintf("(%s %s ? OR (%s = ? AND %s %s ?))", expr, cmp, expr, idExpr, cmp),
+				*c.Num, *c.Num, c.ID)
+		default:
+			return "", errInvalidCursor
+		}
+	}
+
+	order := fmt.Sprintf(" ORDER BY %s %s", expr, dir)
+	if expr != idExpr {
+		order += fmt.Sprintf(", %s %s", idExpr, dir)
+	}
+	return order + fmt.Sprintf(" LIMIT %d", opts.Limit+1), nil
+}
+
+// normalize fills in the default sort and clamps the page size.
+func (opts *ListOptions) normalize(defaultSort string) {
+	if opts.Sort == "" {
+		opts.Sort = defaultSort
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = defaultPageSize
+	}
+	if opts.Limit > maxPageSize {
+		opts.Limit = maxPageSize
+	}
+}
+
+// parseListOptions reads limit, cursor, sort, include and the poll filters
+// from the query string of a list request.
+func parseListOptions(values url.Values) (ListOptions, error) {
+	opts := ListOptions{
+		Sort:   values.Get("sort"),
+		Cursor: values.Get("cursor"),
+	}
+
+	if v := values.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return opts, fmt.Errorf("invalid limit %q", v)
+		}
+		opts.Limit = n
+	}
+
+	if v := values.Get("created_by"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return opts, fmt.Errorf("invalid created_by %q", v)
+		}
+		opts.Filter.CreatedBy = &id
+	}
+
+	if v := values.Get("series_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return opts, fmt.Errorf("invalid series_id %q", v)
+		}
+		opts.Filter.SeriesID = &id
+	}
+
+	// ?tag=a&tag=b lists polls carrying both tags.
+	for _, v := range values["tag"] {
+		tag := normalizeTag(v)
+		if !validTag(tag) {
+			return opts, fmt.Errorf("invalid tag %q", v)
+		}
+		opts.Filter.Tags = append(opts.Filter.Tags, tag)
+	}
+
+	if v := values.Get("include"); v != "" {
+		for _, name := range strings.Split(v, ",") {
+			switch strings.TrimSpace(name) {
+			case "questions":
+				opts.Include.Questions = true
+			case "choices":
+				opts.Include.Choices = true
+			default:
+				return opts, fmt.Errorf("invalid include %q, expected questions or choices", name)
+			}
+		}
+	}
+
+	switch v := values.Get("status"); v {
+	case "", "active", "upcoming", "closed":
+		opts.Filter.Status = v
+	default:
+		return opts, fmt.Errorf("invalid status %q", v)
+	}
+
+	if v := PollState(values.Get("state")); v != "" {
+		if !validPollState(v) {
+			return opts, fmt.Errorf("invalid state %q", v)
+		}
+		opts.Filter.State = v
+	}
+
+	dates := map[string]**time.Time{
+		"created_after":  &opts.Filter.CreatedAfter,
+		"created_before": &opts.Filter.CreatedBefore,
+		"starts_after":   &opts.Filter.StartsAfter,
+		"starts_before":  &opts.Filter.StartsBefore,
+		"ends_after":     &opts.Filter.EndsAfter,
+		"ends_before":    &opts.Filter.EndsBefore,
+	}
+	for name, dst := range dates {
+		v := values.Get(name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return opts, fmt.Errorf("invalid %s %q, expected RFC 3339", name, v)
+		}
+		*dst = &t
+	}
+
+	return opts, nil
+}
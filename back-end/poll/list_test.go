@@ -0,0 +1,149 @@
+package poll
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+// listRow is a poll as the keyset predicate sees it.
+type listRow struct {
+	id   int64
+	date *time.Time
+}
+
+// after evaluates the keyset condition page builds for a cursor on a
+// nullable date, the way MySQL would: any comparison with NULL is false.
+func (r listRow) after(c *pageCursor, desc bool) bool {
+	switch {
+	case c.Null && desc:
+		return r.date == nil && r.id < c.ID
+	case c.Null:
+		return (r.date == nil && r.id > c.ID) || r.date != nil
+	case desc:
+		return r.date == nil || r.date.Before(*c.Time) || (r.date.Equal(*c.Time) && r.id < c.ID)
+	}
+	return r.date != nil && (r.date.After(*c.Time) || (r.date.Equal(*c.Time) && r.id > c.ID))
+}
+
+func TestPageKeysetOnNullableDates(t *testing.T) {
+	opts := ListOptions{Limit: 10}
+	day := fakeTime
+
+	tests := []struct {
+		name   string
+		sort   string
+		cursor pageCursor
+		where  string
+	}{
+		{
+			name:   "ascending from a date",
+			sort:   "start_date",
+			cursor: pageCursor{Time: &day, ID: 3},
+			where:  " WHERE (p.start_date > ? OR (p.start_date = ? AND p.id > ?))",
+		},
+		{
+			name:   "ascending from NULL",
+			sort:   "start_date",
+			cursor: pageCursor{Null: true, ID: 3},
+			where:  " WHERE ((p.start_date IS NULL AND p.id > ?) OR p.start_date IS NOT NULL)",
+		},
+		{
+			name:   "descending from a date",
+			sort:   "-start_date",
+			cursor: pageCursor{Time: &day, ID: 3},
+			where:  " WHERE (p.start_date < ? OR (p.start_date = ? AND p.id < ?) OR p.start_date IS NULL)",
+		},
+		{
+			name:   "descending from NULL",
+			sort:   "-start_date",
+			cursor: pageCursor{Null: true, ID: 3},
+			where:  " WHERE (p.start_date IS NULL AND p.id < ?)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts.Sort = tt.sort
+			tt.cursor.Sort = tt.sort
+			opts.Cursor = encodeCursor(tt.cursor)
+
+			var lq listQuery
+			tail, err := lq.page(opts, pollSortColumns, "p.id")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := lq.whereClause(); got != tt.where {
+				t.Errorf("where = %q, want %q", got, tt.where)
+			}
+			dir := "ASC"
+			if tt.sort[0] == '-' {
+				dir = "DESC"
+			}
+			if want := " ORDER BY p.start_date " + dir + ", p.id " + dir + " LIMIT 11"; tail != want {
+				t.Errorf("tail = %q, want %q", tail, want)
+			}
+		})
+	}
+}
+
+// Walking a listing page by page with the cursors of pollCursor visits every
+// poll once, in order, whether its date is set or not.
+func TestPollCursorWalksNullDates(t *testing.T) {
+	day := func(n int) *time.Time { d := fakeTime.AddDate(0, 0, n); return &d }
+	rows := []listRow{
+		{1, day(2)}, {2, nil}, {3, day(1)}, {4, nil}, {5, day(2)}, {6, day(3)}, {7, nil},
+	}
+
+	for _, sortKey := range []string{"start_date", "-start_date"} {
+		desc := sortKey[0] == '-'
+		// MySQL's order: NULL below every date, ties broken by id.
+		less := func(a, b listRow) bool {
+			switch {
+			case a.date == nil && b.date == nil, a.date != nil && b.date != nil && a.date.Equal(*b.date):
+				return a.id < b.id
+			case a.date == nil:
+				return true
+			case b.date == nil:
+				return false
+			}
+			return a.date.Before(*b.date)
+		}
+		want := append([]listRow(nil), rows...)
+		sort.Slice(want, func(i, j int) bool {
+			if desc {
+				return less(want[j], want[i])
+			}
+			return less(want[i], want[j])
+		})
+
+		var got []listRow
+		var cursor *pageCursor
+		for len(got) < len(rows) {
+			var next *listRow
+			for _, r := range want {
+				if cursor == nil || r.after(cursor, desc) {
+					next = &r
+					break
+				}
+			}
+			if next == nil {
+				break
+			}
+			got = append(got, *next)
+			c, err := decodeCursor(pollCursor(Poll{ID: next.id, StartDate: next.date}, sortKey))
+			if err != nil {
+				t.Fatal(err)
+			}
+			cursor = c
+		}
+
+		if len(got) != len(want) {
+			t.Fatalf("%s: walked %d polls, want %d", sortKey, len(got), len(want))
+		}
+		for i := range want {
+			if got[i].id != want[i].id {
+				t.Errorf("%s: poll %d is #%d, want #%d", sortKey, i+1, got[i].id, want[i].id)
+			}
+		}
+	}
+}
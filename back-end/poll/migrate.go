@@ -0,0 +1,261 @@
+package poll
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// The MySQL image runs database/fly.sql only when it initializes an empty
+// volume, so a database created before a column, index or table was added to
+// fly.sql never gets it from there. schemaSteps adds each of them to such a
+// database; every definition is the one in fly.sql, and a test keeps the two
+// in step. New schema changes go into fly.sql and here together.
+var schemaSteps = []schemaStep{
+	// Polls
+	{table: "polls", column: "slug", stmts: []string{
+		"ALTER TABLE polls ADD COLUMN slug VARCHAR(64) NULL AFTER id",
+	}},
+	{table: "polls", index: "unique_poll_slug", fill: fillSlugs, stmts: []string{
+		"ALTER TABLE polls MODIFY COLUMN slug VARCHAR(64) NOT NULL",
+		"ALTER TABLE polls ADD UNIQUE KEY unique_poll_slug (slug)",
+	}},
+	{table: "polls", column: "state", stmts: []string{
+		"ALTER TABLE polls ADD COLUMN state ENUM('draft', 'published', 'closed', 'archived') NOT NULL DEFAULT 'draft'",
+	}},
+	{table: "polls", column: "version", stmts: []string{
+		"ALTER TABLE polls ADD COLUMN version BIGINT NOT NULL DEFAULT 1",
+	}},
+	{table: "polls", column: "deleted_at", stmts: []string{
+		"ALTER TABLE polls ADD COLUMN deleted_at DATETIME NULL",
+	}},
+	{table: "polls", column: "recurrence", stmts: []string{
+		"ALTER TABLE polls ADD COLUMN recurrence VARCHAR(255) NULL",
+	}},
+	{table: "polls", column: "last_occurrence_at", stmts: []string{
+		"ALTER TABLE polls ADD COLUMN last_occurrence_at DATETIME NULL",
+	}},
+	{table: "polls", column: "series_id", stmts: []string{
+		"ALTER TABLE polls ADD COLUMN series_id BIGINT NULL, ADD FOREIGN KEY (series_id) REFERENCES polls(id) ON DELETE SET NULL",
+	}},
+	{table: "polls", index: "idx_polls_created_at", stmts: []string{
+		"ALTER TABLE polls ADD INDEX idx_polls_created_at (created_at, id)",
+	}},
+	{table: "polls", index: "idx_polls_start_date", stmts: []string{
+		"ALTER TABLE polls ADD INDEX idx_polls_start_date (start_date, id)",
+	}},
+	{table: "polls", index: "idx_polls_end_date", stmts: []string{
+		"ALTER TABLE polls ADD INDEX idx_polls_end_date (end_date, id)",
+	}},
+	{table: "polls", index: "idx_polls_deleted_at", stmts: []string{
+		"ALTER TABLE polls ADD INDEX idx_polls_deleted_at (deleted_at, id)",
+	}},
+	{table: "polls", index: "idx_polls_series", stmts: []string{
+		"ALTER TABLE polls ADD INDEX idx_polls_series (series_id, start_date, id)",
+	}},
+	{table: "polls", index: "ft_polls_title_description", stmts: []string{
+		"ALTER TABLE polls ADD FULLTEXT INDEX ft_polls_title_description (title, description)",
+	}},
+
+	// Questions
+	{table: "questions", column: "position", stmts: []string{
+		"ALTER TABLE questions ADD COLUMN position INT NOT NULL DEFAULT 0",
+	}},
+	{table: "questions", column: "version", stmts: []string{
+		"ALTER TABLE questions ADD COLUMN version BIGINT NOT NULL DEFAULT 1",
+	}},
+	{table: "questions", index: "idx_questions_position", stmts: []string{
+		"ALTER TABLE questions ADD INDEX idx_questions_position (poll_id, position, id)",
+	}},
+	{table: "questions", index: "ft_questions_text", stmts: []string{
+		"ALTER TABLE questions ADD FULLTEXT INDEX ft_questions_text (question_text)",
+	}},
+
+	// Choices
+	{table: "choices", column: "position", stmts: []string{
+		"ALTER TABLE choices ADD COLUMN position INT NOT NULL DEFAULT 0",
+	}},
+	{table: "choices", column: "version", stmts: []string{
+		"ALTER TABLE choices ADD COLUMN version BIGINT NOT NULL DEFAULT 1",
+	}},
+	{table: "choices", index: "idx_choices_position", stmts: []string{
+		"ALTER TABLE choices ADD INDEX idx_choices_position (question_id, position, id)",
+	}},
+	{table: "choices", index: "ft_choices_text", stmts: []string{
+		"ALTER TABLE choices ADD FULLTEXT INDEX ft_choices_text (choice_text)",
+	}},
+
+	// Tables added since, in the order of fly.sql
+	{table: "poll_transitions", stmts: []string{`CREATE TABLE IF NOT EXISTS poll_transitions (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		poll_id BIGINT NOT NULL,
+		kind ENUM('opened', 'closed') NOT NULL,
+		due_at DATETIME NOT NULL,
+		recorded_at DATETIME NOT NULL,
+		FOREIGN KEY (poll_id) REFERENCES polls(id) ON DELETE CASCADE,
+		UNIQUE KEY unique_transition (poll_id, kind)
+	)`}},
+	{table: "result_snapshots", stmts: []string{`CREATE TABLE IF NOT EXISTS result_snapshots (
+		poll_id BIGINT NOT NULL,
+		question_id BIGINT NOT NULL,
+		choice_id BIGINT NOT NULL,
+		votes INT NOT NULL,
+		taken_at DATETIME NOT NULL,
+		PRIMARY KEY (poll_id, choice_id),
+		FOREIGN KEY (poll_id) REFERENCES polls(id) ON DELETE CASCADE,
+		FOREIGN KEY (question_id) REFERENCES questions(id) ON DELETE CASCADE,
+		FOREIGN KEY (choice_id) REFERENCES choices(id) ON DELETE CASCADE
+	)`}},
+	{table: "organizations", stmts: []string{`CREATE TABLE IF NOT EXISTS organizations (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`}},
+	{table: "organization_members", stmts: []string{`CREATE TABLE IF NOT EXISTS organization_members (
+		organization_id BIGINT NOT NULL,
+		user_id BIGINT NOT NULL,
+		PRIMARY KEY (organization_id, user_id),
+		FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+		INDEX idx_organization_members_user (user_id)
+	)`}},
+	{table: "poll_templates", stmts: []string{`CREATE TABLE IF NOT EXISTS poll_templates (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		organization_id BIGINT NOT NULL,
+		created_by BIGINT NULL,
+		name VARCHAR(255) NOT NULL,
+		description TEXT,
+		definition JSON NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE,
+		FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE SET NULL,
+		INDEX idx_poll_templates_organization (organization_id, created_at, id)
+	)`}},
+	{table: "poll_revisions", stmts: []string{`CREATE TABLE IF NOT EXISTS poll_revisions (
+		poll_id BIGINT NOT NULL,
+		version BIGINT NOT NULL,
+		action VARCHAR(32) NOT NULL,
+		author_id BIGINT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		snapshot JSON NOT NULL,
+		PRIMARY KEY (poll_id, version),
+		FOREIGN KEY (poll_id) REFERENCES polls(id) ON DELETE CASCADE
+	)`}},
+	{table: "tags", stmts: []string{`CREATE TABLE IF NOT EXISTS tags (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		name VARCHAR(50) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE KEY unique_tag_name (name)
+	)`}},
+	{table: "poll_tags", stmts: []string{`CREATE TABLE IF NOT EXISTS poll_tags (
+		poll_id BIGINT NOT NULL,
+		tag_id BIGINT NOT NULL,
+		PRIMARY KEY (poll_id, tag_id),
+		FOREIGN KEY (poll_id) REFERENCES polls(id) ON DELETE CASCADE,
+		FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE,
+		INDEX idx_poll_tags_tag (tag_id, poll_id)
+	)`}},
+}
+
+// schemaStep adds one column or index to table, or creates the table. A step
+// naming a column or an index is skipped when the table already has it; the
+// others are CREATE TABLE IF NOT EXISTS and always run.
+type schemaStep struct {
+	table  string
+	column string
+	index  string
+	// fill runs before stmts, e.g. to set a column about to become NOT NULL.
+	fill  func(db *sql.DB) error
+	stmts []string
+}
+
+// Migrate brings the schema of db up to date with database/fly.sql. It is
+// safe to run on every start, and on a database fly.sql just created.
+func Migrate(db *sql.DB) error {
+	for _, s := range schemaSteps {
+		done, err := s.done(db)
+		if err != nil {
+			return fmt.Errorf("Migrate: %w", err)
+		}
+		if done {
+			continue
+		}
+		if s.fill != nil {
+			if err := s.fill(db); err != nil {
+				return fmt.Errorf("Migrate: %w", err)
+			}
+		}
+		for _, stmt := range s.stmts {
+			if _, err := db.Exec(stmt); err != nil {
+				return fmt.Errorf("Migrate: %s: %w", s.name(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// done reports whether the column or index the step adds already exists.
+func (s schemaStep) done(db *sql.DB) (bool, error) {
+	var n int
+	var err error
+	switch {
+	case s.column != "":
+		err = db.QueryRow(`
+			SELECT COUNT(*) FROM information_schema.COLUMNS
+			WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?`,
+			s.table, s.column).Scan(&n)
+	case s.index != "":
+		err = db.QueryRow(`
+			SELECT COUNT(*) FROM information_schema.STATISTICS
+			WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND INDEX_NAME = ?`,
+			s.table, s.index).Scan(&n)
+	default:
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", s.name(), err)
+	}
+	return n > 0, nil
+}
+
+// name describes the step in errors.
+func (s schemaStep) name() string {
+	switch {
+	case s.column != "":
+		return s.table + "." + s.column
+	case s.index != "":
+		return s.table + " index " + s.index
+	}
+	return "table " + s.table
+}
+
+// fillSlugs gives every poll created before slugs existed a generated one.
+func fillSlugs(db *sql.DB) error {
+	rows, err := db.Query("SELECT id FROM polls WHERE slug IS NULL")
+	if err != nil {
+		return fmt.Errorf("fillSlugs: %w", err)
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("fillSlugs: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("fillSlugs: %w", err)
+	}
+
+	for _, id := range ids {
+		slug, err := newSlug()
+		if err != nil {
+			return err
+		}
+		if _, err := db.Exec("UPDATE polls SET slug = ? WHERE id = ?", slug, id); err != nil {
+			return fmt.Errorf("fillSlugs: %w", err)
+		}
+	}
+	return nil
+}
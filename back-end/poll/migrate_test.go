@@ -0,0 +1,187 @@
+package poll
+
+import (
+	"database/sql/driver"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// baselineSchema is every definition of the tables in the first fly.sql,
+// which the MySQL image created on volumes that are still in use. Anything
+// fly.sql declares beyond it needs a schemaStep.
+var baselineSchema = map[string][]string{
+	"users": {
+		"id BIGINT AUTO_INCREMENT PRIMARY KEY",
+		"username VARCHAR(255) NOT NULL",
+		"email VARCHAR(255) NOT NULL UNIQUE",
+		"password_hash VARCHAR(255) NOT NULL",
+		"created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP",
+	},
+	"polls": {
+		"id BIGINT AUTO_INCREMENT PRIMARY KEY",
+		"title VARCHAR(255) NOT NULL",
+		"description TEXT",
+		"created_by BIGINT NOT NULL",
+		"start_date DATETIME NULL",
+		"end_date DATETIME NULL",
+		"created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP",
+		"FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE CASCADE",
+	},
+	"questions": {
+		"id BIGINT AUTO_INCREMENT PRIMARY KEY",
+		"poll_id BIGINT NOT NULL",
+		"question_text TEXT NOT NULL",
+		"FOREIGN KEY (poll_id) REFERENCES polls(id) ON DELETE CASCADE",
+	},
+	"choices": {
+		"id BIGINT AUTO_INCREMENT PRIMARY KEY",
+		"question_id BIGINT NOT NULL",
+		"choice_text TEXT NOT NULL",
+		"FOREIGN KEY (question_id) REFERENCES questions(id) ON DELETE CASCADE",
+	},
+	"votes": {
+		"id BIGINT AUTO_INCREMENT PRIMARY KEY",
+		"token_id BIGINT NOT NULL",
+		"question_id BIGINT NOT NULL",
+		"choice_id BIGINT NOT NULL",
+		"voted_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP",
+		"FOREIGN KEY (token_id) REFERENCES voting_tokens(id) ON DELETE CASCADE",
+		"FOREIGN KEY (question_id) REFERENCES questions(id) ON DELETE CASCADE",
+		"FOREIGN KEY (choice_id) REFERENCES choices(id) ON DELETE CASCADE",
+		"UNIQUE KEY unique_vote_per_token (token_id, question_id)",
+	},
+	"voting_tokens": {
+		"id BIGINT AUTO_INCREMENT PRIMARY KEY",
+		"token_value VARCHAR(255) NOT NULL UNIQUE",
+		"created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP",
+	},
+}
+
+var (
+	sqlComment  = regexp.MustCompile(`--[^\n]*`)
+	createTable = regexp.MustCompile(`(?s)^CREATE TABLE IF NOT EXISTS (\w+) \((.*)\)$`)
+	constraint  = regexp.MustCompile(`^(PRIMARY|FOREIGN|UNIQUE|INDEX|FULLTEXT|KEY)\b`)
+)
+
+// squash collapses the whitespace of a statement, so definitions compare
+// equal however they are indented.
+func squash(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	return strings.NewReplacer("( ", "(", " )", ")").Replace(s)
+}
+
+// schemaTables parses the CREATE TABLE statements of database/fly.sql into
+// their definitions, and the statements themselves, by table.
+func schemaTables(t *testing.T) (defs map[string][]string, stmts map[string]string) {
+	b, err := os.ReadFile("../../database/fly.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defs, stmts = make(map[string][]string), make(map[string]string)
+	for _, stmt := range strings.Split(sqlComment.ReplaceAllString(string(b), ""), ";") {
+		stmt = squash(stmt)
+		m := createTable.FindStringSubmatch(stmt)
+		if m == nil {
+			continue
+		}
+		stmts[m[1]] = stmt
+		depth, last := 0, 0
+		body := m[2]
+		for i := 0; i <= len(body); i++ {
+			if i < len(body) {
+				switch body[i] {
+				case '(':
+					depth++
+				case ')':
+					depth--
+				}
+				if body[i] != ',' || depth > 0 {
+					continue
+				}
+			}
+			defs[m[1]] = append(defs[m[1]], strings.TrimSpace(body[last:i]))
+			last = i + 1
+		}
+	}
+	return defs, stmts
+}
+
+// Every column, index and table fly.sql declares beyond the first schema is
+// added by a schemaStep, with the same definition.
+func TestSchemaStepsCoverFlySQL(t *testing.T) {
+	defs, stmts := schemaTables(t)
+	var steps []string
+	for _, s := range schemaSteps {
+		for _, stmt := range s.stmts {
+			steps = append(steps, squash(stmt))
+		}
+	}
+	covered := func(want ...string) bool {
+		for _, stmt := range steps {
+			for _, w := range want {
+				if stmt == w || strings.Contains(stmt, " "+w+",") || strings.HasSuffix(stmt, " "+w) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	for table, tableDefs := range defs {
+		baseline, ok := baselineSchema[table]
+		if !ok {
+			if !covered(stmts[table]) {
+				t.Errorf("table %s is not created by a schema step, or differs from fly.sql", table)
+			}
+			continue
+		}
+		old := make(map[string]bool)
+		for _, d := range baseline {
+			old[d] = true
+		}
+		for _, d := range tableDefs {
+			switch {
+			case old[d]:
+			case constraint.MatchString(d):
+				if !covered("ADD " + d) {
+					t.Errorf("%s: %q is not added by a schema step", table, d)
+				}
+			default:
+				if !covered("ADD COLUMN "+d, "MODIFY COLUMN "+d) {
+					t.Errorf("%s: column %q is not added by a schema step", table, d)
+				}
+			}
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	// An up-to-date database: only the checks run, and the tables are
+	// created if missing.
+	f := &fakeDB{}
+	if err := Migrate(openFakeDB(f)); err != nil {
+		t.Fatal(err)
+	}
+	checks, tables := 0, 0
+	for _, s := range schemaSteps {
+		if s.column != "" || s.index != "" {
+			checks++
+		} else {
+			tables++
+		}
+	}
+	if got := f.count(); got != checks+tables {
+		t.Errorf("up to date: %d statements, want %d checks and %d CREATE TABLE", got, checks, tables)
+	}
+
+	// A database from the first fly.sql: every step runs.
+	f = &fakeDB{columns: map[string]driver.Value{"count(*)": int64(0)}}
+	if err := Migrate(openFakeDB(f)); err != nil {
+		t.Fatal(err)
+	}
+	if f.count() <= checks+tables {
+		t.Errorf("first schema: %d statements, want every step to run", f.count())
+	}
+}
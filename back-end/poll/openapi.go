@@ -0,0 +1,327 @@
+package poll
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"simple-poll/apierror"
+)
+
+// OpenAPIHandler serves the OpenAPI 3 document of the request's API version.
+// Example usage:
+//
+//	mux.Handle("/api/v1/openapi.json", WithVersion(V1, OpenAPIHandler()))
+func OpenAPIHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			routeNotFound(w, r)
+			return
+		}
+		writeJSON(w, r, OpenAPISpec(versionOf(r)))
+	})
+}
+
+// OpenAPISpec returns the OpenAPI 3 document describing Routes as served by
+// version v. Schemas are derived from the Go types the handlers encode and
+// decode, so a field added to Poll shows up in the spec without further changes.
+func OpenAPISpec(v *APIVersion) map[string]interface{} {
+	v.specOnce.Do(func() {
+		v.spec = buildSpec(Routes, v)
+	})
+	return v.spec
+}
+
+func buildSpec(routes []Route, v *APIVersion) map[string]interface{} {
+	g := &schemaGen{version: v, components: make(map[string]interface{})}
+	errorSchema := g.schema(reflect.TypeOf(errorEnvelope{}))
+
+	paths := make(map[string]map[string]interface{})
+	for _, rt := range routes {
+		op := map[string]interface{}{
+			"summary":     rt.Summary,
+			"operationId": rt.OperationID,
+			"tags":        []string{rt.Tag},
+		}
+
+		var params []interface{}
+		for _, name := range pathParams(rt.Path) {
+			params = append(params, map[string]interface{}{
+				"name":     name,
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]interface{}{"type": "string"},
+			})
+		}
+		for _, qp := range rt.Query {
+			params = append(params, map[string]interface{}{
+				"name":        qp.Name,
+				"in":          "query",
+				"required":    qp.Required,
+				"description": qp.Description,
+				"schema":      map[string]interface{}{"type": qp.Type},
+			})
+		}
+		headers := append(append([]HeaderParam{}, rt.Headers...), middlewareHeaders(rt.Method)...)
+		for _, h := range headers {
+			params = append(params, map[string]interface{}{
+				"name":        h.Name,
+				"in":          "header",
+				"required":    h.Required,
+				"description": h.Description,
+				"schema":      map[string]interface{}{"type": "string"},
+			})
+		}
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+
+		if rt.Request != nil {
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": g.schema(reflect.TypeOf(rt.Request)),
+					},
+				},
+			}
+		}
+
+		status := rt.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		success := map[string]interface{}{"description": http.StatusText(status)}
+		if rt.Response != nil {
+			success["content"] = map[string]interface{}{
+				"application/json": map[string]interface{}{
+					"schema": g.schema(reflect.TypeOf(rt.Response)),
+				},
+			}
+		}
+		if rt.Produces != "" {
+			success["content"] = map[string]interface{}{
+				rt.Produces: map[string]interface{}{
+					"schema": map[string]interface{}{"type": "string", "format": "binary"},
+				},
+			}
+		}
+		errorResponse := func(description string) map[string]interface{} {
+			return map[string]interface{}{
+				"description": description,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": errorSchema},
+				},
+			}
+		}
+		responses := map[string]interface{}{
+			strconv.Itoa(status): success,
+			"default":            errorResponse("Error"),
+		}
+		for _, code := range responseStatuses(rt, headers) {
+			switch code {
+			case http.StatusNotModified:
+				responses[strconv.Itoa(code)] = map[string]interface{}{"description": http.StatusText(code)}
+			case http.StatusTooManyRequests:
+				limited := errorResponse(http.StatusText(code))
+				limited["headers"] = map[string]interface{}{
+					"Retry-After": map[string]interface{}{
+						"description": "Seconds to wait before retrying",
+						"schema":      map[string]interface{}{"type": "integer"},
+					},
+				}
+				responses[strconv.Itoa(code)] = limited
+			default:
+				responses[strconv.Itoa(code)] = errorResponse(http.StatusText(code))
+			}
+		}
+		op["responses"] = responses
+
+		if paths[rt.Path] == nil {
+			paths[rt.Path] = make(map[string]interface{})
+		}
+		paths[rt.Path][strings.ToLower(rt.Method)] = op
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Simple Poll API",
+			"version": v.Name,
+		},
+		"servers":    []interface{}{map[string]interface{}{"url": v.BasePath()}},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": g.components},
+	}
+}
+
+// responseStatuses lists the statuses besides success that the spec names for
+// rt: those of its headers and Errors, 422 for an invalid request body, and
+// the 429 of the rate limiter in front of every router.
+func responseStatuses(rt Route, headers []HeaderParam) []int {
+	seen := map[int]bool{http.StatusTooManyRequests: true}
+	if rt.Request != nil {
+		seen[http.StatusUnprocessableEntity] = true
+	}
+	for _, h := range headers {
+		for _, code := range h.Statuses {
+			seen[code] = true
+		}
+	}
+	for _, code := range rt.Errors {
+		seen[code] = true
+	}
+	codes := make([]int, 0, len(seen))
+	for code := range seen {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	return codes
+}
+
+// errorEnvelope documents the body written by apierror.Write.
+type errorEnvelope struct {
+	Error apierror.Error `json:"error"`
+}
+
+// messageResponse documents the {"message": "..."} bodies of DELETE handlers.
+type messageResponse struct {
+	Message string `json:"message"`
+}
+
+var pathParamPattern = regexp.MustCompile(`\{([^}]+)\}`)
+
+func pathParams(path string) []string {
+	var names []string
+	for _, m := range pathParamPattern.FindAllStringSubmatch(path, -1) {
+		names = append(names, m[1])
+	}
+	return names
+}
+
+// schemaGen turns Go types into JSON schemas, collecting named structs under
+// components/schemas and referring to them with $ref.
+type schemaGen struct {
+	version    *APIVersion
+	components map[string]interface{}
+}
+
+var (
+	timeType        = reflect.TypeOf(time.Time{})
+	rawMessageType  = reflect.TypeOf(json.RawMessage{})
+	genericArgsName = regexp.MustCompile(`[^A-Za-z0-9]+`)
+)
+
+func (g *schemaGen) schema(t reflect.Type) map[string]interface{} {
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == rawMessageType:
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		s := g.schema(t.Elem())
+		if _, isRef := s["$ref"]; isRef {
+			return map[string]interface{}{"allOf": []interface{}{s}, "nullable": true}
+		}
+		s["nullable"] = true
+		return s
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Uint, reflect.Uint32:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice:
+		// A nil slice encodes as null.
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem()), "nullable": true}
+	case reflect.Array:
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Interface:
+		return map[string]interface{}{}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name := schemaName(t)
+		if _, done := g.components[name]; !done {
+			// Reserve the name first so recursive types terminate.
+			g.components[name] = nil
+			g.components[name] = g.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]interface{}{}
+}
+
+func (g *schemaGen) structSchema(t reflect.Type) map[string]interface{} {
+	props := make(map[string]interface{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			// Embedded structs contribute their fields directly.
+			if embedded, ok := g.structSchema(derefType(f.Type))["properties"].(map[string]interface{}); ok {
+				for k, v := range embedded {
+					props[k] = v
+				}
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		name = g.version.fieldName(t, name)
+		s := g.schema(f.Type)
+		if strings.Contains(opts, "string") {
+			s = map[string]interface{}{"type": "string"}
+		}
+		props[name] = s
+	}
+	return map[string]interface{}{"type": "object", "properties": props}
+}
+
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// schemaName gives generic instantiations such as Page[simple-poll/poll.Poll]
+// a readable name like PagePoll.
+func schemaName(t reflect.Type) string {
+	name := t.Name()
+	open := strings.Index(name, "[")
+	if open < 0 {
+		return name
+	}
+	var b strings.Builder
+	b.WriteString(name[:open])
+	for _, arg := range strings.Split(name[open+1:len(name)-1], ",") {
+		if dot := strings.LastIndex(arg, "."); dot >= 0 {
+			arg = arg[dot+1:]
+		}
+		b.WriteString(genericArgsName.ReplaceAllString(arg, ""))
+	}
+	return b.String()
+}
@@ -0,0 +1,88 @@
+package poll
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidOrder is returned by the Reorder functions when the IDs do not
+// list every child of the parent exactly once.
+var ErrInvalidOrder = errors.New("invalid order")
+
+// OrderRequest is the body of the reorder endpoints: the IDs of all children
+// of a parent, in their new order.
+type OrderRequest struct {
+	IDs []int64 `json:"ids"`
+}
+
+// nextPosition returns the position that appends a new row after the
+// existing children of parentID. table and parentColumn must be trusted
+// identifiers, never user input.
+func nextPosition(db dbtx, table, parentColumn string, parentID int64) (int, error) {
+	var next int
+	err := db.QueryRow(
+		"SELECT COALESCE(MAX(position), -1) + 1 FROM "+table+" WHERE "+parentColumn+" = ?", parentID,
+	).Scan(&next)
+	if err != nil {
+		return 0, fmt.Errorf("nextPosition %s: %w", table, err)
+	}
+	return next, nil
+}
+
+// reorder rewrites the positions of the children of parentID so they follow
+// ids. Rows whose position changes get a new version, since position is part
+// of their representation. It must run inside a transaction.
+func reorder(tx dbtx, table, parentTable, parentColumn string, parentID int64, ids []int64) error {
+	ok, err := rowExists(tx, parentTable, parentID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%s %w", strings.TrimSuffix(parentTable, "s"), ErrNotFound)
+	}
+
+	// Lock the children so none is added or removed while we rewrite them.
+	rows, err := tx.Query("SELECT id FROM "+table+" WHERE "+parentColumn+" = ? FOR UPDATE", parentID)
+	if err != nil {
+		return fmt.Errorf("reorder %s: %w", table, err)
+	}
+	children := make(map[int64]bool)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("reorder %s scan: %w", table, err)
+		}
+		children[id] = false
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("reorder %s: %w", table, err)
+	}
+
+	if len(ids) != len(children) {
+		return fmt.Errorf("%w: expected %d IDs, got %d", ErrInvalidOrder, len(children), len(ids))
+	}
+	for _, id := range ids {
+		seen, ok := children[id]
+		if !ok {
+			return fmt.Errorf("%w: %d is not a child of %s %d", ErrInvalidOrder, id, strings.TrimSuffix(parentTable, "s"), parentID)
+		}
+		if seen {
+			return fmt.Errorf("%w: %d is listed more than once", ErrInvalidOrder, id)
+		}
+		children[id] = true
+	}
+
+	for position, id := range ids {
+		_, err := tx.Exec(
+			"UPDATE "+table+" SET position = ?, version = version + 1 WHERE id = ? AND position <> ?",
+			position, id, position,
+		)
+		if err != nil {
+			return fmt.Errorf("reorder %s: %w", table, err)
+		}
+	}
+	return nil
+}
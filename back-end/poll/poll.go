@@ -2,7 +2,10 @@ package poll
 
 import (
 	"database/sql"
-	"errors"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -14,97 +17,173 @@ type Poll struct {
 	StartDate   *time.Time `json:"start_date"`
 	EndDate     *time.Time `json:"end_date"`
 	CreatedAt   time.Time  `json:"created_at"`
-	Questions   []Question `json:"questions"`
+	// Slug addresses the poll in URLs as well as its ID. It is generated
+	// unless a vanity slug is given on creation, and can be changed later.
+	Slug string `json:"slug"`
+	// State is set to draft on creation and only changes through
+	// TransitionPoll.
+	State PollState `json:"state"`
+	// Version increases whenever the poll or any of its questions and choices
+	// change. It backs the poll's ETag.
+	Version int64 `json:"version"`
+	// DeletedAt is set while the poll is in the trash.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// Recurrence, when set, makes the poll the template of a series: the
+	// scheduler copies it into a new poll on every occurrence of the rule
+	// after the poll's own start date. Times are UTC unless the rule has a
+	// TZID (see ParseRecurrence).
+	Recurrence *string `json:"recurrence,omitempty"`
+	// SeriesID links an occurrence to the recurring poll it was copied from.
+	SeriesID *int64 `json:"series_id,omitempty"`
+	// Tags are listed alphabetically. They are ignored in request bodies and
+	// only change through PUT and DELETE /polls/{id}/tags/{tag}.
+	Tags      []string   `json:"tags"`
+	Questions []Question `json:"questions"`
 }
 
 func GetPoll(db *sql.DB, pollID int64) (*Poll, error) {
+	// The tree is loaded in three queries however many questions there are:
+	// the poll with its tags, all of its questions, and all of its choices
+	// at once.
 	pollQuery := `
-		SELECT id, title, description, created_by, start_date, end_date, created_at
+		SELECT id, slug, title, description, created_by, start_date, end_date, created_at, state, version,
+		       recurrence, series_id,
+		       (SELECT JSON_ARRAYAGG(t.name)
+		        FROM poll_tags pt JOIN tags t ON t.id = pt.tag_id
+		        WHERE pt.poll_id = polls.id) AS tags
 		FROM polls
-		WHERE id = ?
+		WHERE id = ? AND deleted_at IS NULL
 	`
 	row := db.QueryRow(pollQuery, pollID)
 
 	var p Poll
+	var tags []byte
 	if err := row.Scan(
 		&p.ID,
+		&p.Slug,
 		&p.Title,
 		&p.Description,
 		&p.CreatedBy,
 		&p.StartDate,
 		&p.EndDate,
 		&p.CreatedAt,
+		&p.State,
+		&p.Version,
+		&p.Recurrence,
+		&p.SeriesID,
+		&tags,
 	); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
 		return nil, err
 	}
+	// JSON_ARRAYAGG is NULL without tags and keeps no order.
+	p.Tags = []string{}
+	if tags != nil {
+		if err := json.Unmarshal(tags, &p.Tags); err != nil {
+			return nil, fmt.Errorf("GetPoll tags: %w", err)
+		}
+		sort.Strings(p.Tags)
+	}
 
-	qQuery := `
-		SELECT id, poll_id, question_text
-		FROM questions
-		WHERE poll_id = ?
-	`
-	qRows, err := db.Query(qQuery, p.ID)
-	if err != nil {
+	polls := []Poll{p}
+	if err := loadQuestions(db, polls, true); err != nil {
 		return nil, err
 	}
-	defer qRows.Close()
+	return &polls[0], nil
+}
 
-	var questions []Question
-	for qRows.Next() {
-		var q Question
-		if err := qRows.Scan(&q.ID, &q.PollID, &q.Text); err != nil {
-			return nil, err
+// CreatePoll inserts a new poll into the database. author is the user making
+// the change, recorded in the poll's revision history; 0 means unknown.
+func CreatePoll(db *sql.DB, poll *Poll, author int64) error {
+	return withTx(db, func(tx *sql.Tx) error {
+		if err := createPoll(tx, poll); err != nil {
+			return err
 		}
+		return recordRevision(tx, poll.ID, author, RevisionCreated)
+	})
+}
 
-		cQuery := `
-			SELECT id, question_id, choice_text
-			FROM choices
-			WHERE question_id = ?
-		`
-		cRows, err := db.Query(cQuery, q.ID)
-		if err != nil {
-			return nil, err
+// CreatePollTree inserts a poll together with its nested questions and
+// choices in a single transaction, filling in every generated ID.
+func CreatePollTree(db *sql.DB, poll *Poll, author int64) error {
+	return withTx(db, func(tx *sql.Tx) error {
+		if err := createPollTree(tx, poll); err != nil {
+			return err
 		}
+		return recordRevision(tx, poll.ID, author, RevisionCreated)
+	})
+}
 
-		var choices []Choice
-		for cRows.Next() {
-			var c Choice
-			if err := cRows.Scan(&c.ID, &c.QuestionID, &c.Text); err != nil {
-				cRows.Close()
-				return nil, err
+// createPollTree inserts a poll with its nested questions and choices. The
+// whole tree is one change, so the poll stays at version 1.
+func createPollTree(tx dbtx, poll *Poll) error {
+	if err := createPoll(tx, poll); err != nil {
+		return err
+	}
+	for i := range poll.Questions {
+		q := &poll.Questions[i]
+		q.PollID = poll.ID
+		if err := insertQuestion(tx, q); err != nil {
+			return err
+		}
+		for j := range q.Choices {
+			c := &q.Choices[j]
+			c.QuestionID = q.ID
+			if err := insertChoice(tx, c); err != nil {
+				return err
 			}
-			choices = append(choices, c)
 		}
-		cRows.Close()
-
-		q.Choices = choices
-		questions = append(questions, q)
 	}
-	p.Questions = questions
-
-	return &p, nil
+	return nil
 }
 
-// CreatePoll inserts a new poll into the database.
-func CreatePoll(db *sql.DB, poll *Poll) error {
+// createPoll inserts a poll with poll.Slug as its vanity slug, or with a
+// generated slug when it is empty. A generated slug that collides is
+// replaced by another; a vanity slug that does returns ErrSlugTaken.
+func createPoll(db dbtx, poll *Poll) error {
 	// Insert statement returning the last inserted ID
 	query := `
-        INSERT INTO polls (title, description, created_by, start_date, end_date)
-        VALUES (?, ?, ?, ?, ?)
+        INSERT INTO polls (slug, title, description, created_by, start_date, end_date, state, recurrence, series_id)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
     `
-	result, err := db.Exec(query,
-		poll.Title,
-		poll.Description,
-		poll.CreatedBy,
-		poll.StartDate,
-		poll.EndDate,
-	)
-	if err != nil {
-		return err
+	var result sql.Result
+	for attempt := 1; ; attempt++ {
+		slug := poll.Slug
+		if slug == "" {
+			var err error
+			if slug, err = newSlug(); err != nil {
+				return err
+			}
+		}
+		var err error
+		result, err = db.Exec(query,
+			slug,
+			poll.Title,
+			poll.Description,
+			poll.CreatedBy,
+			poll.StartDate,
+			poll.EndDate,
+			StateDraft,
+			poll.Recurrence,
+			poll.SeriesID,
+		)
+		if isDuplicateEntry(err) {
+			if poll.Slug != "" {
+				return slugTaken(db, poll.Slug)
+			}
+			if attempt < maxSlugAttempts {
+				continue
+			}
+		}
+		if err != nil {
+			return err
+		}
+		poll.Slug = slug
+		break
 	}
+	poll.Tags = []string{}
 
 	// Retrieve the auto-incremented ID
 	newID, err := result.LastInsertId()
@@ -112,50 +191,160 @@ func CreatePoll(db *sql.DB, poll *Poll) error {
 		return err
 	}
 	poll.ID = newID
+	poll.State = StateDraft
+	poll.Version = 1
 	return nil
 }
 
-// ListPolls retrieves all polls (for example).
-func ListPolls(db *sql.DB) ([]Poll, error) {
+// UpdatePoll updates the title, description, dates and recurrence of an
+// existing poll, and its slug when poll.Slug is set.
+// It only succeeds while the stored version still equals poll.Version, and
+// returns ErrVersionConflict otherwise; a Version of 0 skips the check.
+// Archived polls are read-only.
+func UpdatePoll(db *sql.DB, poll *Poll, author int64) error {
+	return withTx(db, func(tx *sql.Tx) error {
+		state, _, err := lockPollState(tx, poll.ID)
+		if err != nil {
+			return err
+		}
+		if state == StateArchived {
+			return fmt.Errorf("%w: archived polls cannot be edited", ErrInvalidState)
+		}
+
+		query := `
+            UPDATE polls
+            SET slug = COALESCE(NULLIF(?, ''), slug), title = ?, description = ?,
+                start_date = ?, end_date = ?, recurrence = ?, version = version + 1
+            WHERE id = ? AND (? = 0 OR version = ?) AND deleted_at IS NULL
+        `
+		result, err := tx.Exec(query,
+			poll.Slug,
+			poll.Title,
+			poll.Description,
+			poll.StartDate,
+			poll.EndDate,
+			poll.Recurrence,
+			poll.ID,
+			poll.Version, poll.Version,
+		)
+		if isDuplicateEntry(err) {
+			return slugTaken(tx, poll.Slug)
+		}
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return missingOrConflict(tx, "polls", poll.ID)
+		}
+		return recordRevision(tx, poll.ID, author, RevisionUpdated)
+	})
+}
+
+// pollSortColumns are the fields ListPolls can sort by. The dates are sorted
+// on the bare columns so their (column, id) indexes serve the ORDER BY; NULL
+// dates come first in ascending order and last in descending order.
+var pollSortColumns = map[string]string{
+	"id":         "p.id",
+	"title":      "p.title",
+	"created_at": "p.created_at",
+	"start_date": "p.start_date",
+	"end_date":   "p.end_date",
+	"deleted_at": "p.deleted_at",
+}
+
+// ListPolls retrieves one page of polls matching opts, newest first by
+// default, and the cursor of the next page. Trashed polls are left out unless
+// opts.Filter.Trashed asks for them instead. Questions, and their choices, are
+// only filled in when opts.Include asks for them.
+func ListPolls(db *sql.DB, opts ListOptions) ([]Poll, string, error) {
+	opts.normalize("-created_at")
+
+	var lq listQuery
+	lq.addPollFilter(opts.Filter)
+	tail, err := lq.page(opts, pollSortColumns, "p.id")
+	if err != nil {
+		return nil, "", err
+	}
+
 	query := `
-        SELECT id, title, description, created_by, start_date, end_date, created_at
-        FROM polls
-        ORDER BY created_at DESC
-    `
-	rows, err := db.Query(query)
+        SELECT p.id, p.slug, p.title, p.description, p.created_by, p.start_date, p.end_date, p.created_at,
+               p.state, p.version, p.deleted_at, p.recurrence, p.series_id
+        FROM polls p` + lq.whereClause() + tail
+	rows, err := db.Query(query, lq.args...)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	defer rows.Close()
 
-	var polls []Poll
+	polls := []Poll{}
 	for rows.Next() {
 		var p Poll
 		err := rows.Scan(
-			&p.ID, &p.Title, &p.Description, &p.CreatedBy,
-			&p.StartDate, &p.EndDate, &p.CreatedAt,
+			&p.ID, &p.Slug, &p.Title, &p.Description, &p.CreatedBy,
+			&p.StartDate, &p.EndDate, &p.CreatedAt, &p.State, &p.Version, &p.DeletedAt,
+			&p.Recurrence, &p.SeriesID,
 		)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 		polls = append(polls, p)
 	}
-	return polls, nil
-}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
 
-// DeletePoll removes a poll by ID.
-func DeletePoll(db *sql.DB, pollID int64) error {
-	query := `
-        DELETE FROM polls
-        WHERE id = ?
-    `
-	result, err := db.Exec(query, pollID)
-	if err != nil {
-		return err
+	next := ""
+	if len(polls) > opts.Limit {
+		polls = polls[:opts.Limit]
+		next = pollCursor(polls[len(polls)-1], opts.Sort)
 	}
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		return errors.New("no rows deleted; poll not found")
+	if err := loadTags(db, polls); err != nil {
+		return nil, "", err
 	}
-	return nil
+	if opts.Include.Questions || opts.Include.Choices {
+		if err := loadQuestions(db, polls, opts.Include.Choices); err != nil {
+			return nil, "", err
+		}
+	}
+	return polls, next, nil
+}
+
+// pollCursor encodes the position of p in a listing sorted by sort.
+func pollCursor(p Poll, sort string) string {
+	c := pageCursor{Sort: sort, ID: p.ID}
+	switch strings.TrimPrefix(sort, "-") {
+	case "title":
+		c.Text = &p.Title
+	case "created_at":
+		c.Time = &p.CreatedAt
+	case "start_date":
+		c.Time, c.Null = p.StartDate, p.StartDate == nil
+	case "end_date":
+		c.Time, c.Null = p.EndDate, p.EndDate == nil
+	case "deleted_at":
+		c.Time, c.Null = p.DeletedAt, p.DeletedAt == nil
+	}
+	return encodeCursor(c)
+}
+
+// DeletePoll moves a poll to the trash, provided its version still equals
+// version (0 skips the check). Its questions, choices and votes are kept until
+// PurgeTrash removes them, and RestorePoll brings everything back.
+func DeletePoll(db *sql.DB, pollID, version, author int64) error {
+	return withTx(db, func(tx *sql.Tx) error {
+		query := `
+            UPDATE polls
+            SET deleted_at = NOW(), version = version + 1
+            WHERE id = ? AND (? = 0 OR version = ?) AND deleted_at IS NULL
+        `
+		result, err := tx.Exec(query, pollID, version, version)
+		if err != nil {
+			return err
+		}
+		rowsAffected, _ := result.RowsAffected()
+		if rowsAffected == 0 {
+			return missingOrConflict(tx, "polls", pollID)
+		}
+		return recordRevision(tx, pollID, author, RevisionTrashed)
+	})
 }
@@ -2,21 +2,37 @@ package poll
 
 import (
 	"database/sql"
-	"encoding/json"
+	"io"
 	"log"
 	"net/http"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
+
+	"simple-poll/apierror"
 )
 
-// PollRouter is the main entry point for /api/polls routes.
-func PollRouter(db *sql.DB) http.Handler {
+// PollRouter is the main entry point for /api/polls routes. voteBase is the
+// front end's base URL, which QR codes link to.
+func PollRouter(db *sql.DB, voteBase string) http.Handler {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		path := strings.TrimPrefix(r.URL.Path, "/") // might be "" or "123"
 		parts := strings.Split(path, "/")
+		// /api/polls/team-retro addresses the same poll as its ID; handlers
+		// below only ever see IDs.
+		if parts[0] != "" && parts[0] != "trash" && !isNumericID(parts[0]) {
+			id, err := PollIDBySlug(db, strings.ToLower(parts[0]))
+			if err != nil {
+				writeError(w, storageError(err, "Failed to get poll"))
+				return
+			}
+			parts[0] = strconv.FormatInt(id, 10)
+		}
+		// "trash" is a collection of its own, not a poll ID
+		isPoll := len(parts) == 1 && parts[0] != "" && parts[0] != "trash"
 
 		// Example logic:
 		if r.Method == http.MethodGet {
@@ -25,62 +41,127 @@ func PollRouter(db *sql.DB) http.Handler {
 				// GET /api/polls/ => list all polls
 				if parts[0] == "" {
 					listPollsHandler(db, w, r)
+				} else if parts[0] == "trash" {
+					// GET /api/polls/trash => list deleted polls
+					listTrashHandler(db, w, r)
 				} else {
 					// GET /api/polls/123 => get that poll
 					getPollHandler(db, w, r, parts[0])
 				}
+			case 2:
+				if parts[1] == "occurrences" {
+					// GET /api/polls/123/occurrences => list the series' polls
+					listOccurrencesHandler(db, w, r, parts[0])
+				} else if parts[1] == "revisions" {
+					// GET /api/polls/123/revisions => list the poll's history
+					listRevisionsHandler(db, w, r, parts[0])
+				} else if format := qrFormats[parts[1]]; format != "" {
+					// GET /api/polls/123/qr.png or /qr.svg => QR code of the voting link
+					qrHandler(db, w, r, parts[0], format, voteBase)
+				} else {
+					routeNotFound(w, r)
+				}
+			case 3:
+				if parts[1] == "revisions" {
+					// GET /api/polls/123/revisions/7 => the poll as of version 7
+					getRevisionHandler(db, w, r, parts[0], parts[2])
+				} else {
+					routeNotFound(w, r)
+				}
 			default:
-				http.NotFound(w, r)
+				routeNotFound(w, r)
 			}
 		} else if r.Method == http.MethodPost && len(parts) == 1 && parts[0] == "" {
 			// POST /api/polls/
 			createPollHandler(db, w, r)
-		} else if r.Method == http.MethodDelete && len(parts) == 1 {
+		} else if r.Method == http.MethodPost && len(parts) == 2 && parts[1] == "clone" {
+			// POST /api/polls/123/clone => copy the poll into a new draft
+			clonePollHandler(db, w, r, parts[0])
+		} else if r.Method == http.MethodPost && len(parts) == 2 && parts[1] == "restore" {
+			// POST /api/polls/123/restore => take the poll out of the trash
+			restorePollHandler(db, w, r, parts[0])
+		} else if r.Method == http.MethodPost && len(parts) == 2 && transitionTargets[parts[1]] != "" {
+			// POST /api/polls/123/publish, /close or /archive => change state
+			transitionPollHandler(db, w, r, parts[0], transitionTargets[parts[1]])
+		} else if (r.Method == http.MethodPut || r.Method == http.MethodDelete) && len(parts) == 3 && parts[1] == "tags" {
+			// PUT /api/polls/123/tags/all-hands => tag the poll
+			// DELETE /api/polls/123/tags/all-hands => untag it
+			tagPollHandler(db, w, r, parts[0], parts[2], r.Method == http.MethodPut)
+		} else if r.Method == http.MethodPut && len(parts) == 3 && parts[1] == "questions" && parts[2] == "order" {
+			// PUT /api/polls/123/questions/order => reorder the poll's questions
+			reorderQuestionsHandler(db, w, r, parts[0])
+		} else if (r.Method == http.MethodPut || r.Method == http.MethodPatch) && isPoll {
+			// PUT /api/polls/123 => replace editable fields
+			// PATCH /api/polls/123 => change only the fields sent
+			updatePollHandler(db, w, r, parts[0], r.Method == http.MethodPatch)
+		} else if r.Method == http.MethodDelete && isPoll {
 			// DELETE /api/polls/123
 			deletePollHandler(db, w, r, parts[0])
 		} else {
-			http.NotFound(w, r)
+			routeNotFound(w, r)
 		}
 	})
 
 	return mux
 }
 
+// listPollsHandler handles listing polls one page at a time,
+// e.g. /api/polls/?status=active&sort=-start_date&limit=20&cursor=...
+// ?include=questions,choices embeds each poll's tree and ?fields=id,title
+// trims every item to the fields named.
 func listPollsHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
-	polls, err := ListPolls(db)
+	opts, err := parseListOptions(r.URL.Query())
+	if err != nil {
+		writeError(w, apierror.BadRequest(err.Error()))
+		return
+	}
+	fields, err := parseFields(r, reflect.TypeOf(Poll{}))
+	if err != nil {
+		writeError(w, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	polls, next, err := ListPolls(db, opts)
 	if err != nil {
 		log.Printf("Error listing polls: %v", err)
-		http.Error(w, "Failed to list polls", http.StatusInternalServerError)
+		writeError(w, storageError(err, "Failed to list polls"))
 		return
 	}
-	writeJSON(w, polls)
+	var keep []string
+	if opts.Include.Questions || opts.Include.Choices {
+		keep = append(keep, "questions")
+	}
+	writePage(w, r, Page[Poll]{Items: polls, NextCursor: next}, fields, keep...)
 }
 
 func getPollHandler(db *sql.DB, w http.ResponseWriter, r *http.Request, idParam string) {
 	id, err := strconv.ParseInt(idParam, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid poll ID", http.StatusBadRequest)
+		writeError(w, apierror.BadRequest("Invalid poll ID"))
 		return
 	}
 
 	poll, err := GetPoll(db, id)
 	if err != nil {
 		log.Printf("Error getting poll: %v", err)
-		http.Error(w, "Failed to get poll", http.StatusInternalServerError)
+		writeError(w, storageError(err, "Failed to get poll"))
 		return
 	}
 	if poll == nil {
-		http.NotFound(w, r)
+		writeError(w, apierror.NotFound("Poll not found"))
+		return
+	}
+	if notModified(w, r, poll.Version) {
 		return
 	}
-	writeJSON(w, poll)
+	writeJSON(w, r, poll)
 }
 
 func createPollHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 	var p Poll
-	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+	if err := decodeJSON(r, &p); err != nil {
 		log.Printf("Error decoding poll: %v", err)
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		writeError(w, apierror.BadRequest("Invalid request payload"))
 		return
 	}
 
@@ -89,40 +170,419 @@ func createPollHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 	if p.StartDate == nil {
 		p.StartDate = &now
 	}
-	future := now.Add(24 * time.Hour)
+	future := p.StartDate.Add(24 * time.Hour)
 	if p.EndDate == nil {
 		p.EndDate = &future
 	}
 
-	err := CreatePoll(db, &p)
+	// Only the scheduler creates occurrences of a series.
+	p.SeriesID = nil
+
+	if verr := validateNewPoll(db, &p, versionOf(r)); verr != nil {
+		writeError(w, verr)
+		return
+	}
+
+	author, aerr := optionalCallerID(r)
+	if aerr != nil {
+		writeError(w, aerr)
+		return
+	}
+
+	// Nested questions[].choices[] are created along with the poll.
+	err := CreatePollTree(db, &p, author)
 	if err != nil {
 		log.Printf("Error creating poll: %v", err)
-		http.Error(w, "Failed to create poll", http.StatusInternalServerError)
+		writeError(w, storageError(err, "Failed to create poll"))
+		return
+	}
+
+	// Answer with the stored row, not the payload: created_at and the
+	// generated IDs come from the database, and fields the client may not set
+	// are not echoed back.
+	created, err := GetPoll(db, p.ID)
+	if err != nil || created == nil {
+		log.Printf("Error getting created poll: %v", err)
+		writeError(w, storageError(err, "Failed to get poll"))
+		return
+	}
+	w.Header().Set("ETag", etag(r, created.Version))
+	writeJSON(w, r, created)
+}
+
+// updatePollHandler handles PUT and PATCH. With partial set, the payload is
+// decoded on top of the stored poll so absent fields keep their values.
+// Both require an If-Match header naming the poll's current ETag.
+func updatePollHandler(db *sql.DB, w http.ResponseWriter, r *http.Request, idParam string, partial bool) {
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		writeError(w, apierror.BadRequest("Invalid poll ID"))
+		return
+	}
+
+	version, verr := ifMatchVersion(r)
+	if verr != nil {
+		writeError(w, verr)
+		return
+	}
+
+	existing, err := GetPoll(db, id)
+	if err != nil {
+		log.Printf("Error getting poll: %v", err)
+		writeError(w, storageError(err, "Failed to update poll"))
+		return
+	}
+	if existing == nil {
+		writeError(w, apierror.NotFound("Poll not found"))
+		return
+	}
+
+	var p Poll
+	if partial {
+		p = *existing
+	}
+	if err := decodeJSON(r, &p); err != nil {
+		log.Printf("Error decoding poll: %v", err)
+		writeError(w, apierror.BadRequest("Invalid request payload"))
+		return
+	}
+	// The URL decides which poll is updated, never the payload, and
+	// If-Match decides which version it is based on.
+	p.ID = id
+	p.Version = version
+	if partial && version == 0 {
+		// A PATCH is merged onto what we just read, so it must not land on
+		// top of a change made in between, even with If-Match: *.
+		p.Version = existing.Version
+	}
+
+	if verr := validatePollUpdate(&p); verr != nil {
+		writeError(w, verr)
 		return
 	}
 
-	writeJSON(w, p)
+	author, aerr := optionalCallerID(r)
+	if aerr != nil {
+		writeError(w, aerr)
+		return
+	}
+	if err := UpdatePoll(db, &p, author); err != nil {
+		log.Printf("Error updating poll: %v", err)
+		writeError(w, storageError(err, "Failed to update poll"))
+		return
+	}
+
+	updated, err := GetPoll(db, id)
+	if err != nil || updated == nil {
+		log.Printf("Error getting updated poll: %v", err)
+		writeError(w, storageError(err, "Failed to get poll"))
+		return
+	}
+	w.Header().Set("ETag", etag(r, updated.Version))
+	writeJSON(w, r, updated)
 }
 
+// deletePollHandler handles DELETE, which requires an If-Match header. The
+// poll goes to the trash, from where it can be restored until it is purged.
 func deletePollHandler(db *sql.DB, w http.ResponseWriter, r *http.Request, idParam string) {
 	id, err := strconv.ParseInt(idParam, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid poll ID", http.StatusBadRequest)
+		writeError(w, apierror.BadRequest("Invalid poll ID"))
 		return
 	}
 
-	err = DeletePoll(db, id)
+	version, verr := ifMatchVersion(r)
+	if verr != nil {
+		writeError(w, verr)
+		return
+	}
+
+	author, aerr := optionalCallerID(r)
+	if aerr != nil {
+		writeError(w, aerr)
+		return
+	}
+	err = DeletePoll(db, id, version, author)
 	if err != nil {
 		log.Printf("Error deleting poll: %v", err)
-		http.Error(w, "Failed to delete poll", http.StatusInternalServerError)
+		writeError(w, storageError(err, "Failed to delete poll"))
 		return
 	}
 
 	// Return a simple success message
-	writeJSON(w, map[string]string{"message": "Poll deleted"})
+	writeJSON(w, r, map[string]string{"message": "Poll moved to trash"})
+}
+
+// listTrashHandler lists deleted polls one page at a time, most recently
+// deleted first, with the same parameters as listPollsHandler.
+func listTrashHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	opts, err := parseListOptions(r.URL.Query())
+	if err != nil {
+		writeError(w, apierror.BadRequest(err.Error()))
+		return
+	}
+	fields, err := parseFields(r, reflect.TypeOf(Poll{}))
+	if err != nil {
+		writeError(w, apierror.BadRequest(err.Error()))
+		return
+	}
+	opts.Filter.Trashed = true
+	if opts.Sort == "" {
+		opts.Sort = "-deleted_at"
+	}
+
+	polls, next, err := ListPolls(db, opts)
+	if err != nil {
+		log.Printf("Error listing trash: %v", err)
+		writeError(w, storageError(err, "Failed to list trash"))
+		return
+	}
+	var keep []string
+	if opts.Include.Questions || opts.Include.Choices {
+		keep = append(keep, "questions")
+	}
+	writePage(w, r, Page[Poll]{Items: polls, NextCursor: next}, fields, keep...)
+}
+
+// listOccurrencesHandler lists the polls the scheduler created from a
+// recurring poll, latest first, with the same parameters as listPollsHandler.
+// Listing them with ?include=questions,choices lines up the runs of a series
+// for comparison.
+func listOccurrencesHandler(db *sql.DB, w http.ResponseWriter, r *http.Request, idParam string) {
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		writeError(w, apierror.BadRequest("Invalid poll ID"))
+		return
+	}
+	opts, err := parseListOptions(r.URL.Query())
+	if err != nil {
+		writeError(w, apierror.BadRequest(err.Error()))
+		return
+	}
+	fields, err := parseFields(r, reflect.TypeOf(Poll{}))
+	if err != nil {
+		writeError(w, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	ok, err := rowExists(db, "polls", id)
+	if err != nil {
+		log.Printf("Error getting poll: %v", err)
+		writeError(w, storageError(err, "Failed to list occurrences"))
+		return
+	}
+	if !ok {
+		writeError(w, apierror.NotFound("Poll not found"))
+		return
+	}
+
+	opts.Filter.SeriesID = &id
+	if opts.Sort == "" {
+		opts.Sort = "-start_date"
+	}
+	polls, next, err := ListPolls(db, opts)
+	if err != nil {
+		log.Printf("Error listing occurrences: %v", err)
+		writeError(w, storageError(err, "Failed to list occurrences"))
+		return
+	}
+	var keep []string
+	if opts.Include.Questions || opts.Include.Choices {
+		keep = append(keep, "questions")
+	}
+	writePage(w, r, Page[Poll]{Items: polls, NextCursor: next}, fields, keep...)
+}
+
+// clonePollHandler copies a poll, with its questions and choices, into a new
+// draft owned by the caller named in the X-User-ID header. The body is
+// optional; see CloneRequest.
+func clonePollHandler(db *sql.DB, w http.ResponseWriter, r *http.Request, idParam string) {
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		writeError(w, apierror.BadRequest("Invalid poll ID"))
+		return
+	}
+	owner, aerr := callerID(r)
+	if aerr != nil {
+		writeError(w, aerr)
+		return
+	}
+
+	var req CloneRequest
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r, &req); err != nil && err != io.EOF {
+			log.Printf("Error decoding clone request: %v", err)
+			writeError(w, apierror.BadRequest("Invalid request payload"))
+			return
+		}
+	}
+	if verr := validateClone(db, owner, &req); verr != nil {
+		writeError(w, verr)
+		return
+	}
+
+	clone, err := ClonePoll(db, id, CloneOptions{
+		Owner:           owner,
+		Title:           strings.TrimSpace(req.Title),
+		StartDate:       req.StartDate,
+		Shift:           time.Duration(req.ShiftDays) * 24 * time.Hour,
+		DropDescription: req.DropDescription,
+	})
+	if err != nil {
+		log.Printf("Error cloning poll: %v", err)
+		writeError(w, storageError(err, "Failed to clone poll"))
+		return
+	}
+
+	w.Header().Set("ETag", etag(r, clone.Version))
+	writeJSON(w, r, clone)
+}
+
+// listRevisionsHandler lists the changes made to a poll and its questions
+// and choices one page at a time, newest first, e.g.
+// /api/polls/123/revisions?limit=20&cursor=...
+func listRevisionsHandler(db *sql.DB, w http.ResponseWriter, r *http.Request, idParam string) {
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		writeError(w, apierror.BadRequest("Invalid poll ID"))
+		return
+	}
+	opts, err := parseListOptions(r.URL.Query())
+	if err != nil {
+		writeError(w, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	ok, err := rowExists(db, "polls", id)
+	if err != nil {
+		log.Printf("Error getting poll: %v", err)
+		writeError(w, storageError(err, "Failed to list revisions"))
+		return
+	}
+	if !ok {
+		writeError(w, apierror.NotFound("Poll not found"))
+		return
+	}
+
+	revisions, next, err := ListRevisions(db, id, opts)
+	if err != nil {
+		log.Printf("Error listing revisions: %v", err)
+		writeError(w, storageError(err, "Failed to list revisions"))
+		return
+	}
+	writeJSON(w, r, Page[Revision]{Items: revisions, NextCursor: next})
+}
+
+// getRevisionHandler returns a poll, with its questions and choices, as it
+// was right after the change that took it to the given version.
+func getRevisionHandler(db *sql.DB, w http.ResponseWriter, r *http.Request, idParam, versionParam string) {
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		writeError(w, apierror.BadRequest("Invalid poll ID"))
+		return
+	}
+	version, err := strconv.ParseInt(versionParam, 10, 64)
+	if err != nil {
+		writeError(w, apierror.BadRequest("Invalid revision"))
+		return
+	}
+
+	ok, err := rowExists(db, "polls", id)
+	if err != nil {
+		log.Printf("Error getting poll: %v", err)
+		writeError(w, storageError(err, "Failed to get revision"))
+		return
+	}
+	if !ok {
+		writeError(w, apierror.NotFound("Poll not found"))
+		return
+	}
+
+	rev, err := GetRevision(db, id, version)
+	if err != nil {
+		log.Printf("Error getting revision: %v", err)
+		writeError(w, storageError(err, "Failed to get revision"))
+		return
+	}
+	if rev == nil {
+		writeError(w, apierror.NotFound("Revision not found"))
+		return
+	}
+	writeJSON(w, r, rev)
 }
 
-func writeJSON(w http.ResponseWriter, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(data)
+// restorePollHandler takes a poll out of the trash and returns it.
+func restorePollHandler(db *sql.DB, w http.ResponseWriter, r *http.Request, idParam string) {
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		writeError(w, apierror.BadRequest("Invalid poll ID"))
+		return
+	}
+
+	author, aerr := optionalCallerID(r)
+	if aerr != nil {
+		writeError(w, aerr)
+		return
+	}
+	if err := RestorePoll(db, id, author); err != nil {
+		log.Printf("Error restoring poll: %v", err)
+		writeError(w, storageError(err, "Failed to restore poll"))
+		return
+	}
+
+	restored, err := GetPoll(db, id)
+	if err != nil || restored == nil {
+		log.Printf("Error getting restored poll: %v", err)
+		writeError(w, storageError(err, "Failed to get poll"))
+		return
+	}
+	w.Header().Set("ETag", etag(r, restored.Version))
+	writeJSON(w, r, restored)
+}
+
+// transitionTargets maps the action segment of a lifecycle endpoint to the
+// state it moves the poll to.
+var transitionTargets = map[string]PollState{
+	"publish": StatePublished,
+	"close":   StateClosed,
+	"archive": StateArchived,
+}
+
+// transitionPollHandler moves a poll to another lifecycle state and returns
+// it. An If-Match header is honoured when sent but not required.
+func transitionPollHandler(db *sql.DB, w http.ResponseWriter, r *http.Request, idParam string, to PollState) {
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		writeError(w, apierror.BadRequest("Invalid poll ID"))
+		return
+	}
+
+	var version int64
+	if r.Header.Get("If-Match") != "" {
+		var verr *apierror.Error
+		if version, verr = ifMatchVersion(r); verr != nil {
+			writeError(w, verr)
+			return
+		}
+	}
+
+	author, aerr := optionalCallerID(r)
+	if aerr != nil {
+		writeError(w, aerr)
+		return
+	}
+	if err := TransitionPoll(db, id, to, version, author); err != nil {
+		log.Printf("Error changing poll state: %v", err)
+		writeError(w, storageError(err, "Failed to change poll state"))
+		return
+	}
+
+	updated, err := GetPoll(db, id)
+	if err != nil || updated == nil {
+		log.Printf("Error getting updated poll: %v", err)
+		writeError(w, storageError(err, "Failed to get poll"))
+		return
+	}
+	w.Header().Set("ETag", etag(r, updated.Version))
+	writeJSON(w, r, updated)
 }
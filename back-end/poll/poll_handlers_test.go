@@ -0,0 +1,38 @@
+package poll
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Creating a poll answers with the stored row, not with the payload.
+func TestCreatePollAnswersWithTheStoredPoll(t *testing.T) {
+	v := Versions[0]
+	body := `{"title": "Team lunch", "created_by": 1,
+		"created_at": "2001-01-01T00:00:00Z", "deleted_at": "2001-01-02T00:00:00Z"}`
+	r := httptest.NewRequest(http.MethodPost, v.BasePath()+"/polls/", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	testAPI(openFakeDB(&fakeDB{}), v).ServeHTTP(rec, r)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d; body %s", rec.Code, rec.Body)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if want := fakeTime.Format(time.RFC3339); got["created_at"] != want {
+		t.Errorf("created_at = %v, want the stored %s", got["created_at"], want)
+	}
+	if d, ok := got["deleted_at"]; ok {
+		t.Errorf("deleted_at = %v echoed on a live poll", d)
+	}
+	if got["tags"] == nil {
+		t.Error("tags missing from the created poll")
+	}
+}
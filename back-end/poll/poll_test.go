@@ -0,0 +1,114 @@
+package poll
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// GetPoll loads the whole tree in a fixed number of queries, however many
+// questions and choices it has.
+func TestGetPollQueryCount(t *testing.T) {
+	for _, n := range []int{1, 10, 200} {
+		t.Run(fmt.Sprintf("%d questions", n), func(t *testing.T) {
+			f := &fakeDB{rows: n}
+			p, err := GetPoll(openFakeDB(f), 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(p.Questions) != n {
+				t.Fatalf("loaded %d questions, want %d", len(p.Questions), n)
+			}
+			if got := f.count(); got > 3 {
+				t.Errorf("GetPoll ran %d queries, want at most 3", got)
+			}
+		})
+	}
+}
+
+func TestGetPollSortsTags(t *testing.T) {
+	for _, tt := range []struct {
+		tags driver.Value
+		want string
+	}{
+		{nil, "[]"},
+		{[]byte(`["q3 planning", "all-hands", "lunch"]`), "[all-hands lunch q3 planning]"},
+	} {
+		db := openFakeDB(&fakeDB{columns: map[string]driver.Value{"tags": tt.tags}})
+		p, err := GetPoll(db, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fmt.Sprint(p.Tags); got != tt.want || p.Tags == nil {
+			t.Errorf("tags %s: got %s (nil %v), want %s", tt.tags, got, p.Tags == nil, tt.want)
+		}
+	}
+}
+
+// loadTreePerQuestion is how GetPoll used to load a tree: one choices query
+// per question, QUESTIONS + 1 round trips in all.
+func loadTreePerQuestion(db *sql.DB, p *Poll) error {
+	qRows, err := db.Query(`
+		SELECT id, poll_id, question_text, position, version
+		FROM questions
+		WHERE poll_id = ?
+		ORDER BY position, id`, p.ID)
+	if err != nil {
+		return err
+	}
+	defer qRows.Close()
+
+	for qRows.Next() {
+		var q Question
+		if err := qRows.Scan(&q.ID, &q.PollID, &q.Text, &q.Position, &q.Version); err != nil {
+			return err
+		}
+		cRows, err := db.Query(`
+			SELECT id, question_id, choice_text, position, version
+			FROM choices
+			WHERE question_id = ?
+			ORDER BY position, id`, q.ID)
+		if err != nil {
+			return err
+		}
+		for cRows.Next() {
+			var c Choice
+			if err := cRows.Scan(&c.ID, &c.QuestionID, &c.Text, &c.Position, &c.Version); err != nil {
+				cRows.Close()
+				return err
+			}
+			q.Choices = append(q.Choices, c)
+		}
+		cRows.Close()
+		p.Questions = append(p.Questions, q)
+	}
+	return qRows.Err()
+}
+
+// BenchmarkGetPoll loads the questions and choices of a 50-question poll
+// over a connection with 100µs of latency, one choices query per question
+// against a single batched query.
+func BenchmarkGetPoll(b *testing.B) {
+	const questions = 50
+	for _, bb := range []struct {
+		name string
+		load func(*sql.DB, *Poll) error
+	}{
+		{"per-question", loadTreePerQuestion},
+		{"batched", func(db *sql.DB, p *Poll) error { return loadQuestions(db, []Poll{*p}, true) }},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			f := &fakeDB{rows: questions, latency: 100 * time.Microsecond}
+			db := openFakeDB(f)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := bb.load(db, &Poll{ID: 1}); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(f.count())/float64(b.N), "queries/op")
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package poll
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"simple-poll/apierror"
+	"simple-poll/qr"
+)
+
+// DefaultVoteBaseURL is where the front end serves poll pages when
+// PUBLIC_URL is not set: the React dev server.
+const DefaultVoteBaseURL = "http://localhost:3001"
+
+// Limits and defaults of the QR code query parameters.
+const (
+	defaultQRSize  = 512
+	minQRSize      = 64
+	maxQRSize      = 4096
+	defaultQRLevel = "M"
+)
+
+// qrFormats maps the last path segment of a QR code route to the image
+// format served.
+var qrFormats = map[string]string{
+	"qr.png": "png",
+	"qr.svg": "svg",
+}
+
+// votingToken matches the tokens accepted in QR code links: URL-safe,
+// so they survive the trip through a phone's camera app unchanged.
+var votingToken = regexp.MustCompile(`^[A-Za-z0-9_-]{1,128}$`)
+
+// VoteURL returns the link to a poll's voting page in the front end served
+// at base, carrying a pre-issued voting token when one is given. Polls are
+// linked by slug, so the link does not reveal how many polls exist.
+func VoteURL(base, slug, token string) string {
+	link := strings.TrimRight(base, "/") + "/poll/" + url.PathEscape(slug)
+	if token != "" {
+		link += "?token=" + url.QueryEscape(token)
+	}
+	return link
+}
+
+// qrHandler serves a QR code of the poll's voting link, e.g.
+// /api/polls/123/qr.png?size=800&ecc=H&token=..., for projecting at
+// meetings. size is the image width in pixels and ecc the error correction
+// level, L, M, Q or H; a higher level still scans when partly covered, at
+// the cost of a denser code.
+func qrHandler(db *sql.DB, w http.ResponseWriter, r *http.Request, idParam, format, voteBase string) {
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		writeError(w, apierror.BadRequest("Invalid poll ID"))
+		return
+	}
+
+	q := r.URL.Query()
+	size := defaultQRSize
+	if v := q.Get("size"); v != "" {
+		size, err = strconv.Atoi(v)
+		if err != nil || size < minQRSize || size > maxQRSize {
+			writeError(w, apierror.BadRequest("size must be a whole number of pixels from "+
+				strconv.Itoa(minQRSize)+" to "+strconv.Itoa(maxQRSize)))
+			return
+		}
+	}
+	levelParam := q.Get("ecc")
+	if levelParam == "" {
+		levelParam = defaultQRLevel
+	}
+	level, err := qr.ParseLevel(levelParam)
+	if err != nil {
+		writeError(w, apierror.BadRequest("ecc must be L, M, Q or H"))
+		return
+	}
+	token := q.Get("token")
+	if token != "" && !votingToken.MatchString(token) {
+		writeError(w, apierror.BadRequest("token must be 1 to 128 letters, digits, '-' or '_'"))
+		return
+	}
+
+	poll, err := GetPoll(db, id)
+	if err != nil {
+		log.Printf("Error getting poll: %v", err)
+		writeError(w, storageError(err, "Failed to get poll"))
+		return
+	}
+	if poll == nil {
+		writeError(w, apierror.NotFound("Poll not found"))
+		return
+	}
+	// The link only changes with the slug, which bumps the version.
+	if notModified(w, r, poll.Version) {
+		return
+	}
+
+	code, err := qr.Encode([]byte(VoteURL(voteBase, poll.Slug, token)), level)
+	if err != nil {
+		writeError(w, apierror.Internal(err, "Failed to encode QR code"))
+		return
+	}
+
+	if format == "svg" {
+		w.Header().Set("Content-Type", "image/svg+xml")
+		w.Write(code.SVG(size))
+		return
+	}
+	body, err := code.PNG(size)
+	if err != nil {
+		writeError(w, apierror.Internal(err, "Failed to render QR code"))
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(body)
+}
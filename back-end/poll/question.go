@@ -3,94 +3,237 @@ package poll
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 )
 
 // Question represents a question record in the DB.
 type Question struct {
-	ID      int64    `json:"id"`
-	PollID  int64    `json:"poll_id"`
-	Text    string   `json:"text"`
-	Choices []Choice `json:"choices"`
+	ID     int64  `json:"id"`
+	PollID int64  `json:"poll_id"`
+	Text   string `json:"text"`
+	// Position orders the question within its poll, starting at 0. New
+	// questions are appended; only the reorder endpoint moves them.
+	Position int      `json:"position"`
+	Version  int64    `json:"version"`
+	Choices  []Choice `json:"choices"`
 }
 
-// ListQuestions fetches all questions (optionally for a specific poll if needed).
-func ListQuestions(db *sql.DB, pollID *int64) ([]Question, error) {
-	var rows *sql.Rows
-	var err error
+// questionSortColumns are the fields ListQuestions can sort by.
+var questionSortColumns = map[string]string{
+	"id":       "q.id",
+	"text":     "q.question_text",
+	"position": "q.position",
+}
 
+// ListQuestions fetches one page of questions (optionally for a specific poll),
+// filtered by their poll according to opts, and the cursor of the next page.
+// The questions of one poll come in ballot order unless opts asks otherwise.
+// Choices are only filled in when opts.Include asks for them.
+func ListQuestions(db *sql.DB, pollID *int64, opts ListOptions) ([]Question, string, error) {
 	if pollID != nil {
-		// If you want to filter by poll_id
-		rows, err = db.Query("SELECT id, poll_id, question_text FROM questions WHERE poll_id = ?", *pollID)
+		opts.normalize("position")
 	} else {
-		// Otherwise, get all questions
-		rows, err = db.Query("SELECT id, poll_id, question_text FROM questions")
+		opts.normalize("id")
+	}
+	if opts.Include.Questions {
+		return nil, "", fmt.Errorf("%w: questions cannot be included in questions", ErrInvalidListOptions)
+	}
+
+	var lq listQuery
+	if pollID != nil {
+		lq.add("q.poll_id = ?", *pollID)
+	}
+	lq.addPollFilter(opts.Filter)
+	tail, err := lq.page(opts, questionSortColumns, "q.id")
+	if err != nil {
+		return nil, "", err
 	}
+
+	query := `
+        SELECT q.id, q.poll_id, q.question_text, q.position, q.version
+        FROM questions q
+        JOIN polls p ON p.id = q.poll_id` + lq.whereClause() + tail
+	rows, err := db.Query(query, lq.args...)
 	if err != nil {
-		return nil, fmt.Errorf("ListQuestions: %v", err)
+		return nil, "", fmt.Errorf("ListQuestions: %w", err)
 	}
 	defer rows.Close()
 
 	questions := []Question{}
 	for rows.Next() {
 		var q Question
-		if err := rows.Scan(&q.ID, &q.PollID, &q.Text); err != nil {
-			return nil, fmt.Errorf("ListQuestions scan: %v", err)
+		if err := rows.Scan(&q.ID, &q.PollID, &q.Text, &q.Position, &q.Version); err != nil {
+			return nil, "", fmt.Errorf("ListQuestions scan: %w", err)
 		}
 		questions = append(questions, q)
 	}
-	return questions, rows.Err()
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("ListQuestions: %w", err)
+	}
+
+	next := ""
+	if len(questions) > opts.Limit {
+		questions = questions[:opts.Limit]
+		last := questions[len(questions)-1]
+		c := pageCursor{Sort: opts.Sort, ID: last.ID}
+		switch strings.TrimPrefix(opts.Sort, "-") {
+		case "text":
+			c.Text = &last.Text
+		case "position":
+			n := int64(last.Position)
+			c.Num = &n
+		}
+		next = encodeCursor(c)
+	}
+	if opts.Include.Choices && len(questions) > 0 {
+		ids := make([]int64, len(questions))
+		ptrs := make([]*Question, len(questions))
+		for i := range questions {
+			ids[i] = questions[i].ID
+			ptrs[i] = &questions[i]
+		}
+		if err := loadChoices(db, ptrs, "c.question_id", ids); err != nil {
+			return nil, "", err
+		}
+	}
+	return questions, next, nil
 }
 
 // GetQuestion returns a single Question by ID.
 func GetQuestion(db *sql.DB, questionID int64) (*Question, error) {
 	var q Question
-	err := db.QueryRow("SELECT id, poll_id, question_text FROM questions WHERE id = ?", questionID).
-		Scan(&q.ID, &q.PollID, &q.Text)
+	err := db.QueryRow("SELECT id, poll_id, question_text, position, version FROM questions WHERE id = ?"+liveCondition("questions"), questionID).
+		Scan(&q.ID, &q.PollID, &q.Text, &q.Position, &q.Version)
 	if err == sql.ErrNoRows {
 		// No result found
 		return nil, nil
 	}
 	if err != nil {
-		return nil, fmt.Errorf("GetQuestion: %v", err)
+		return nil, fmt.Errorf("GetQuestion: %w", err)
 	}
 	return &q, nil
 }
 
-// CreateQuestion inserts a new question into the DB.
-func CreateQuestion(db *sql.DB, q *Question) error {
+// CreateQuestion inserts a new question into the DB. author is recorded in
+// the poll's revision history; 0 means unknown.
+func CreateQuestion(db *sql.DB, q *Question, author int64) error {
+	return withTx(db, func(tx *sql.Tx) error {
+		if err := createQuestion(tx, q); err != nil {
+			return err
+		}
+		return recordRevision(tx, q.PollID, author, RevisionQuestionAdded)
+	})
+}
+
+func createQuestion(db dbtx, q *Question) error {
+	if err := requireDraft(db, q.PollID); err != nil {
+		return err
+	}
+	if err := insertQuestion(db, q); err != nil {
+		return err
+	}
+	return touchPoll(db, q.PollID)
+}
+
+// insertQuestion appends a question to its poll without checking the poll's
+// state or bumping its version, for polls being created in the same
+// transaction.
+func insertQuestion(db dbtx, q *Question) error {
+	position, err := nextPosition(db, "questions", "poll_id", q.PollID)
+	if err != nil {
+		return err
+	}
 	result, err := db.Exec(
-		"INSERT INTO questions (poll_id, question_text) VALUES (?, ?)",
-		q.PollID, q.Text,
+		"INSERT INTO questions (poll_id, question_text, position) VALUES (?, ?, ?)",
+		q.PollID, q.Text, position,
 	)
 	if err != nil {
-		return fmt.Errorf("CreateQuestion: %v", err)
+		return fmt.Errorf("CreateQuestion: %w", err)
 	}
 	// Retrieve the newly inserted ID
 	id, err := result.LastInsertId()
 	if err != nil {
-		return fmt.Errorf("CreateQuestion LastInsertId: %v", err)
+		return fmt.Errorf("CreateQuestion LastInsertId: %w", err)
 	}
 	q.ID = id
+	q.Position = position
+	q.Version = 1
 	return nil
 }
 
-// UpdateQuestion updates the question text for an existing record.
-func UpdateQuestion(db *sql.DB, q *Question) error {
-	_, err := db.Exec(
-		"UPDATE questions SET question_text = ? WHERE id = ?",
-		q.Text, q.ID,
-	)
-	if err != nil {
-		return fmt.Errorf("UpdateQuestion: %v", err)
-	}
-	return nil
+// UpdateQuestion updates the question text for an existing record, provided its
+// version still equals q.Version (0 skips the check).
+func UpdateQuestion(db *sql.DB, q *Question, author int64) error {
+	return withTx(db, func(tx *sql.Tx) error {
+		if err := requireDraftOfQuestion(tx, q.ID); err != nil {
+			return err
+		}
+		result, err := tx.Exec(
+			"UPDATE questions SET question_text = ?, version = version + 1 WHERE id = ? AND (? = 0 OR version = ?)"+liveCondition("questions"),
+			q.Text, q.ID, q.Version, q.Version,
+		)
+		if err != nil {
+			return fmt.Errorf("UpdateQuestion: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return missingOrConflict(tx, "questions", q.ID)
+		}
+		if err := touchPollOfQuestion(tx, q.ID); err != nil {
+			return err
+		}
+		pollID, err := pollOfQuestion(tx, q.ID)
+		if err != nil {
+			return err
+		}
+		return recordRevision(tx, pollID, author, RevisionQuestionEdited)
+	})
 }
 
-// DeleteQuestion deletes a question by ID.
-func DeleteQuestion(db *sql.DB, questionID int64) error {
-	_, err := db.Exec("DELETE FROM questions WHERE id = ?", questionID)
-	if err != nil {
-		return fmt.Errorf("DeleteQuestion: %v", err)
-	}
-	return nil
+// DeleteQuestion deletes a question by ID, provided its version still equals
+// version (0 skips the check).
+func DeleteQuestion(db *sql.DB, questionID, version, author int64) error {
+	return withTx(db, func(tx *sql.Tx) error {
+		if err := requireDraftOfQuestion(tx, questionID); err != nil {
+			return err
+		}
+		// Touch the poll first; afterwards the question is gone and cannot be joined.
+		if err := touchPollOfQuestion(tx, questionID); err != nil {
+			return err
+		}
+		pollID, err := pollOfQuestion(tx, questionID)
+		if err != nil {
+			return err
+		}
+		result, err := tx.Exec("DELETE FROM questions WHERE id = ? AND (? = 0 OR version = ?)"+liveCondition("questions"),
+			questionID, version, version)
+		if err != nil {
+			return fmt.Errorf("DeleteQuestion: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return missingOrConflict(tx, "questions", questionID)
+		}
+		return recordRevision(tx, pollID, author, RevisionQuestionDeleted)
+	})
+}
+
+// ReorderQuestions puts the questions of a poll in the order of ids, which
+// must list every question of the poll exactly once, provided the poll's
+// version still equals version (0 skips the check). It returns
+// ErrInvalidOrder or ErrVersionConflict otherwise.
+func ReorderQuestions(db *sql.DB, pollID int64, ids []int64, version, author int64) error {
+	return withTx(db, func(tx *sql.Tx) error {
+		if err := requireDraft(tx, pollID); err != nil {
+			return err
+		}
+		if err := checkPollVersion(tx, pollID, version); err != nil {
+			return err
+		}
+		if err := reorder(tx, "questions", "polls", "poll_id", pollID, ids); err != nil {
+			return err
+		}
+		if err := touchPoll(tx, pollID); err != nil {
+			return err
+		}
+		return recordRevision(tx, pollID, author, RevisionQuestionsReordered)
+	})
 }
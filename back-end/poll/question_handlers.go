@@ -2,11 +2,13 @@ package poll
 
 import (
 	"database/sql"
-	"encoding/json"
 	"log"
 	"net/http"
+	"reflect"
 	"strconv"
 	"strings"
+
+	"simple-poll/apierror"
 )
 
 // QuestionRouter is the main entry point for /api/questions routes.
@@ -34,7 +36,7 @@ func QuestionRouter(db *sql.DB) http.Handler {
 				getQuestionHandler(db, w, r, parts[0])
 				return
 			}
-			http.NotFound(w, r)
+			routeNotFound(w, r)
 
 		case http.MethodPost:
 			// POST /api/questions/ => create new question
@@ -42,7 +44,7 @@ func QuestionRouter(db *sql.DB) http.Handler {
 				createQuestionHandler(db, w, r)
 				return
 			}
-			http.NotFound(w, r)
+			routeNotFound(w, r)
 
 		case http.MethodPut:
 			// PUT /api/questions/123 => update
@@ -50,7 +52,12 @@ func QuestionRouter(db *sql.DB) http.Handler {
 				updateQuestionHandler(db, w, r, parts[0])
 				return
 			}
-			http.NotFound(w, r)
+			// PUT /api/questions/123/choices/order => reorder the question's choices
+			if len(parts) == 3 && parts[1] == "choices" && parts[2] == "order" {
+				reorderChoicesHandler(db, w, r, parts[0])
+				return
+			}
+			routeNotFound(w, r)
 
 		case http.MethodDelete:
 			// DELETE /api/questions/123 => delete
@@ -58,18 +65,18 @@ func QuestionRouter(db *sql.DB) http.Handler {
 				deleteQuestionHandler(db, w, r, parts[0])
 				return
 			}
-			http.NotFound(w, r)
+			routeNotFound(w, r)
 
 		default:
-			http.NotFound(w, r)
+			routeNotFound(w, r)
 		}
 	})
 
 	return mux
 }
 
-// listQuestionsHandler handles listing all questions,
-// optionally you could filter by poll_id if you want (using a query param).
+// listQuestionsHandler handles listing questions one page at a time,
+// optionally filtered by poll_id and by the poll filters (see parseListOptions).
 func listQuestionsHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 	var pollID *int64
 
@@ -81,102 +88,206 @@ func listQuestionsHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	questions, err := ListQuestions(db, pollID)
+	opts, err := parseListOptions(queryValues)
+	if err != nil {
+		writeError(w, apierror.BadRequest(err.Error()))
+		return
+	}
+	fields, err := parseFields(r, reflect.TypeOf(Question{}))
+	if err != nil {
+		writeError(w, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	questions, next, err := ListQuestions(db, pollID, opts)
 	if err != nil {
 		log.Printf("Error listing questions: %v", err)
-		http.Error(w, "Failed to list questions", http.StatusInternalServerError)
+		writeError(w, storageError(err, "Failed to list questions"))
 		return
 	}
-	writeJSON(w, questions)
+	var keep []string
+	if opts.Include.Choices {
+		keep = append(keep, "choices")
+	}
+	writePage(w, r, Page[Question]{Items: questions, NextCursor: next}, fields, keep...)
 }
 
 // getQuestionHandler handles retrieving a single question by ID.
 func getQuestionHandler(db *sql.DB, w http.ResponseWriter, r *http.Request, idParam string) {
 	id, err := strconv.ParseInt(idParam, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid question ID", http.StatusBadRequest)
+		writeError(w, apierror.BadRequest("Invalid question ID"))
 		return
 	}
 
 	question, err := GetQuestion(db, id)
 	if err != nil {
 		log.Printf("Error getting question: %v", err)
-		http.Error(w, "Failed to get question", http.StatusInternalServerError)
+		writeError(w, storageError(err, "Failed to get question"))
 		return
 	}
 	if question == nil {
-		http.NotFound(w, r)
+		writeError(w, apierror.NotFound("Question not found"))
 		return
 	}
-	writeJSON(w, question)
+	if notModified(w, r, question.Version) {
+		return
+	}
+	writeJSON(w, r, question)
 }
 
 // createQuestionHandler handles creating a new question.
 func createQuestionHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 	var q Question
-	if err := json.NewDecoder(r.Body).Decode(&q); err != nil {
+	if err := decodeJSON(r, &q); err != nil {
 		log.Printf("Error decoding question: %v", err)
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		writeError(w, apierror.BadRequest("Invalid request payload"))
 		return
 	}
 
-	// Here you may want to validate PollID, etc.
-	// For example:
-	// if q.PollID == 0 {
-	//     http.Error(w, "poll_id is required", http.StatusBadRequest)
-	//     return
-	// }
+	if verr := validateQuestion(db, &q, true); verr != nil {
+		writeError(w, verr)
+		return
+	}
 
-	if err := CreateQuestion(db, &q); err != nil {
+	author, aerr := optionalCallerID(r)
+	if aerr != nil {
+		writeError(w, aerr)
+		return
+	}
+	if err := CreateQuestion(db, &q, author); err != nil {
 		log.Printf("Error creating question: %v", err)
-		http.Error(w, "Failed to create question", http.StatusInternalServerError)
+		writeError(w, storageError(err, "Failed to create question"))
 		return
 	}
 
-	writeJSON(w, q)
+	w.Header().Set("ETag", etag(r, q.Version))
+	writeJSON(w, r, q)
 }
 
 // updateQuestionHandler handles updating an existing question's text.
+// It requires an If-Match header naming the question's current ETag.
 func updateQuestionHandler(db *sql.DB, w http.ResponseWriter, r *http.Request, idParam string) {
 	id, err := strconv.ParseInt(idParam, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid question ID", http.StatusBadRequest)
+		writeError(w, apierror.BadRequest("Invalid question ID"))
+		return
+	}
+
+	version, verr := ifMatchVersion(r)
+	if verr != nil {
+		writeError(w, verr)
 		return
 	}
 
 	var q Question
-	if err := json.NewDecoder(r.Body).Decode(&q); err != nil {
+	if err := decodeJSON(r, &q); err != nil {
 		log.Printf("Error decoding question: %v", err)
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		writeError(w, apierror.BadRequest("Invalid request payload"))
 		return
 	}
 
 	// We must ensure the ID from the URL path matches the ID in the payload
 	// or you can ignore payload ID and use only the URL's ID.
 	q.ID = id
+	q.Version = version
 
-	if err := UpdateQuestion(db, &q); err != nil {
+	if verr := validateQuestion(db, &q, false); verr != nil {
+		writeError(w, verr)
+		return
+	}
+
+	author, aerr := optionalCallerID(r)
+	if aerr != nil {
+		writeError(w, aerr)
+		return
+	}
+	if err := UpdateQuestion(db, &q, author); err != nil {
 		log.Printf("Error updating question: %v", err)
-		http.Error(w, "Failed to update question", http.StatusInternalServerError)
+		writeError(w, storageError(err, "Failed to update question"))
 		return
 	}
 
-	writeJSON(w, q)
+	// Re-read so the response carries the new version and untouched fields.
+	updated, err := GetQuestion(db, id)
+	if err != nil || updated == nil {
+		log.Printf("Error getting updated question: %v", err)
+		writeError(w, storageError(err, "Failed to get question"))
+		return
+	}
+	w.Header().Set("ETag", etag(r, updated.Version))
+	writeJSON(w, r, updated)
 }
 
 // deleteQuestionHandler handles deleting a question by ID.
+// It requires an If-Match header naming the question's current ETag.
 func deleteQuestionHandler(db *sql.DB, w http.ResponseWriter, r *http.Request, idParam string) {
 	id, err := strconv.ParseInt(idParam, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid question ID", http.StatusBadRequest)
+		writeError(w, apierror.BadRequest("Invalid question ID"))
 		return
 	}
 
-	if err := DeleteQuestion(db, id); err != nil {
+	version, verr := ifMatchVersion(r)
+	if verr != nil {
+		writeError(w, verr)
+		return
+	}
+
+	author, aerr := optionalCallerID(r)
+	if aerr != nil {
+		writeError(w, aerr)
+		return
+	}
+	if err := DeleteQuestion(db, id, version, author); err != nil {
 		log.Printf("Error deleting question: %v", err)
-		http.Error(w, "Failed to delete question", http.StatusInternalServerError)
+		writeError(w, storageError(err, "Failed to delete question"))
+		return
+	}
+
+	writeJSON(w, r, map[string]string{"message": "Question deleted"})
+}
+
+// reorderQuestionsHandler handles PUT /api/polls/123/questions/order with a
+// body like {"ids": [7, 5, 6]} listing every question of the poll. If-Match
+// must carry the poll's ETag. It answers with the questions in their new
+// order.
+func reorderQuestionsHandler(db *sql.DB, w http.ResponseWriter, r *http.Request, idParam string) {
+	pollID, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		writeError(w, apierror.BadRequest("Invalid poll ID"))
+		return
+	}
+
+	var req OrderRequest
+	if err := decodeJSON(r, &req); err != nil {
+		log.Printf("Error decoding question order: %v", err)
+		writeError(w, apierror.BadRequest("Invalid request payload"))
+		return
+	}
+
+	version, verr := ifMatchVersion(r)
+	if verr != nil {
+		writeError(w, verr)
 		return
 	}
 
-	writeJSON(w, map[string]string{"message": "Question deleted"})
+	author, aerr := optionalCallerID(r)
+	if aerr != nil {
+		writeError(w, aerr)
+		return
+	}
+	if err := ReorderQuestions(db, pollID, req.IDs, version, author); err != nil {
+		log.Printf("Error reordering questions: %v", err)
+		writeError(w, storageError(err, "Failed to reorder questions"))
+		return
+	}
+
+	questions, next, err := ListQuestions(db, &pollID, ListOptions{Sort: "position", Limit: maxPageSize})
+	if err != nil {
+		log.Printf("Error listing questions: %v", err)
+		writeError(w, storageError(err, "Failed to list questions"))
+		return
+	}
+	writeJSON(w, r, Page[Question]{Items: questions, NextCursor: next})
 }
@@ -0,0 +1,295 @@
+package poll
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // TZID must resolve in images without a zoneinfo database
+)
+
+// Recurrence is a parsed recurrence rule, a subset of the iCalendar RRULE
+// syntax, e.g. "FREQ=WEEKLY;BYDAY=MO;BYHOUR=9;BYMINUTE=0" for every Monday at
+// 9:00. Rules are evaluated in UTC unless a TZID part names a time zone.
+// Parts that are left out default to the rule's anchor, the start date of the
+// poll that carries it.
+type Recurrence struct {
+	// Freq is DAILY, WEEKLY or MONTHLY.
+	Freq string
+	// Interval repeats the rule every Interval days, weeks or months.
+	Interval int
+	// ByDay restricts WEEKLY rules to these weekdays.
+	ByDay []time.Weekday
+	// ByMonthDay restricts MONTHLY rules to these days; -1 is the last day.
+	ByMonthDay []int
+	ByHour     []int
+	ByMinute   []int
+	// Until, when set, is the last moment an occurrence may fall on.
+	Until *time.Time
+	// Location is the time zone of BYDAY, BYHOUR and the other parts, from
+	// TZID; nil means UTC.
+	Location *time.Location
+}
+
+// maxRecurrenceInterval bounds INTERVAL, which keeps Next's search short.
+const maxRecurrenceInterval = 99
+
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday, "TH": time.Thursday,
+	"FR": time.Friday, "SA": time.Saturday, "SU": time.Sunday,
+}
+
+// ParseRecurrence parses a rule such as "FREQ=DAILY;INTERVAL=2;BYHOUR=12".
+// An "RRULE:" prefix is allowed. Supported parts are FREQ, INTERVAL, BYDAY,
+// BYMONTHDAY, BYHOUR, BYMINUTE, UNTIL and TZID. Times of day and weekdays are
+// in UTC unless TZID names an IANA time zone, e.g.
+// "FREQ=WEEKLY;BYDAY=MO;BYHOUR=9;TZID=Europe/Paris" fires at 9:00 Paris time
+// all year round.
+func ParseRecurrence(s string) (*Recurrence, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	r := &Recurrence{Interval: 1}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(s, ";") {
+		name, raw, ok := strings.Cut(part, "=")
+		name = strings.ToUpper(strings.TrimSpace(name))
+		raw = strings.TrimSpace(raw)
+		value := strings.ToUpper(raw)
+		if !ok || value == "" {
+			return nil, fmt.Errorf("invalid rule part %q, expected NAME=VALUE", part)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%s is given twice", name)
+		}
+		seen[name] = true
+
+		var err error
+		switch name {
+		case "FREQ":
+			switch value {
+			case "DAILY", "WEEKLY", "MONTHLY":
+				r.Freq = value
+			default:
+				return nil, fmt.Errorf("unsupported FREQ %q, expected DAILY, WEEKLY or MONTHLY", value)
+			}
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(value)
+			if err != nil || r.Interval < 1 || r.Interval > maxRecurrenceInterval {
+				return nil, fmt.Errorf("INTERVAL must be between 1 and %d", maxRecurrenceInterval)
+			}
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				wd, ok := weekdays[day]
+				if !ok {
+					return nil, fmt.Errorf("invalid BYDAY %q, expected MO, TU, WE, TH, FR, SA or SU", day)
+				}
+				r.ByDay = append(r.ByDay, wd)
+			}
+		case "BYMONTHDAY":
+			if r.ByMonthDay, err = parseRuleInts(name, value, -31, 31); err != nil {
+				return nil, err
+			}
+			for _, d := range r.ByMonthDay {
+				if d == 0 {
+					return nil, fmt.Errorf("BYMONTHDAY cannot be 0")
+				}
+			}
+		case "BYHOUR":
+			if r.ByHour, err = parseRuleInts(name, value, 0, 23); err != nil {
+				return nil, err
+			}
+		case "BYMINUTE":
+			if r.ByMinute, err = parseRuleInts(name, value, 0, 59); err != nil {
+				return nil, err
+			}
+		case "UNTIL":
+			until, err := parseRuleTime(value)
+			if err != nil {
+				return nil, err
+			}
+			r.Until = &until
+		case "TZID":
+			// Zone names are case-sensitive, e.g. America/New_York.
+			if r.Location, err = time.LoadLocation(raw); err != nil || raw == "Local" {
+				return nil, fmt.Errorf("invalid TZID %q, expected an IANA time zone such as Europe/Paris", raw)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported rule part %s", name)
+		}
+	}
+
+	if r.Freq == "" {
+		return nil, fmt.Errorf("FREQ is required")
+	}
+	if r.ByDay != nil && r.Freq != "WEEKLY" {
+		return nil, fmt.Errorf("BYDAY is only supported with FREQ=WEEKLY")
+	}
+	if r.ByMonthDay != nil && r.Freq != "MONTHLY" {
+		return nil, fmt.Errorf("BYMONTHDAY is only supported with FREQ=MONTHLY")
+	}
+	return r, nil
+}
+
+func parseRuleInts(name, value string, min, max int) ([]int, error) {
+	var out []int
+	for _, v := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < min || n > max {
+			return nil, fmt.Errorf("invalid %s %q, expected %d to %d", name, v, min, max)
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+// parseRuleTime reads an UNTIL value in iCalendar form (20261231T170000Z or
+// 20261231) or as RFC 3339.
+func parseRuleTime(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102", time.RFC3339} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid UNTIL %q, expected e.g. 20261231T170000Z", value)
+}
+
+// Next returns the first occurrence of the rule strictly after after, for a
+// rule anchored at anchor, in UTC. Occurrences fall strictly after the
+// anchor, which is the template poll itself rather than one of its copies. It
+// reports false when the rule has no further occurrence.
+func (r *Recurrence) Next(anchor, after time.Time) (time.Time, bool) {
+	loc := r.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	anchor, after = anchor.In(loc), after.In(loc)
+	if after.Before(anchor) {
+		after = anchor
+	}
+	times := r.timesOfDay(anchor)
+
+	// Every rule repeats within a few years times its interval, so a rule
+	// without a match in that window never matches again.
+	day := midnight(after)
+	for i := 0; i < 4*366*r.Interval; i++ {
+		d := day.AddDate(0, 0, i)
+		if !r.matchesDay(anchor, d) {
+			continue
+		}
+		for _, offset := range times {
+			// Build the wall clock time rather than adding the offset to
+			// midnight, so 9:00 stays 9:00 across a DST change.
+			at := time.Date(d.Year(), d.Month(), d.Day(),
+				int(offset/time.Hour), int(offset%time.Hour/time.Minute), 0, 0, loc)
+			if !at.After(after) {
+				continue
+			}
+			if r.Until != nil && at.After(*r.Until) {
+				return time.Time{}, false
+			}
+			return at.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// latestDue returns the latest occurrence at or before now that follows last,
+// the occurrence created most recently, or the anchor when none was. It
+// reports false when no such occurrence is due.
+func (r *Recurrence) latestDue(anchor time.Time, last *time.Time, now time.Time) (time.Time, bool) {
+	after := anchor
+	if last != nil {
+		after = *last
+	}
+	at, ok := r.Next(anchor, after)
+	if !ok || at.After(now) {
+		return time.Time{}, false
+	}
+	for {
+		next, ok := r.Next(anchor, at)
+		if !ok || next.After(now) {
+			return at, true
+		}
+		at = next
+	}
+}
+
+// timesOfDay lists the offsets from midnight the rule fires at, in order.
+func (r *Recurrence) timesOfDay(anchor time.Time) []time.Duration {
+	hours, minutes := r.ByHour, r.ByMinute
+	if hours == nil {
+		hours = []int{anchor.Hour()}
+	}
+	if minutes == nil {
+		minutes = []int{anchor.Minute()}
+	}
+	var times []time.Duration
+	for _, h := range hours {
+		for _, m := range minutes {
+			times = append(times, time.Duration(h)*time.Hour+time.Duration(m)*time.Minute)
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	return times
+}
+
+// matchesDay reports whether the rule fires on day d, a midnight in the
+// rule's time zone.
+func (r *Recurrence) matchesDay(anchor, d time.Time) bool {
+	switch r.Freq {
+	case "DAILY":
+		days := daysBetween(midnight(anchor), d)
+		return days >= 0 && days%r.Interval == 0
+	case "WEEKLY":
+		days := r.ByDay
+		if days == nil {
+			days = []time.Weekday{anchor.Weekday()}
+		}
+		if !containsWeekday(days, d.Weekday()) {
+			return false
+		}
+		weeks := daysBetween(weekStart(anchor), weekStart(d)) / 7
+		return weeks >= 0 && weeks%r.Interval == 0
+	case "MONTHLY":
+		monthDays := r.ByMonthDay
+		if monthDays == nil {
+			monthDays = []int{anchor.Day()}
+		}
+		last := d.AddDate(0, 1, -d.Day()).Day()
+		matched := false
+		for _, md := range monthDays {
+			if md == d.Day() || (md < 0 && last+md+1 == d.Day()) {
+				matched = true
+			}
+		}
+		months := (d.Year()-anchor.Year())*12 + int(d.Month()-anchor.Month())
+		return matched && months >= 0 && months%r.Interval == 0
+	}
+	return false
+}
+
+// midnight returns the start of t's day in t's location.
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// weekStart returns the Monday midnight of t's week, as RRULE's default WKST.
+func weekStart(t time.Time) time.Time {
+	return midnight(t).AddDate(0, 0, -((int(t.Weekday()) + 6) % 7))
+}
+
+// daysBetween counts calendar days, which are not all 24 hours long in a
+// time zone with DST.
+func daysBetween(from, to time.Time) int {
+	day := func(t time.Time) time.Time { return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC) }
+	return int(day(to).Sub(day(from)).Hours()) / 24
+}
+
+func containsWeekday(days []time.Weekday, wd time.Weekday) bool {
+	for _, d := range days {
+		if d == wd {
+			return true
+		}
+	}
+	return false
+}
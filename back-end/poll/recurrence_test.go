@@ -0,0 +1,225 @@
+package poll
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func mustTime(t *testing.T, s string) time.Time {
+	t.Helper()
+	at, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return at
+}
+
+func TestRecurrenceNext(t *testing.T) {
+	tests := []struct {
+		name   string
+		rule   string
+		anchor string
+		want   []string
+		// ends is set when no occurrence follows those in want.
+		ends bool
+	}{
+		{
+			name:   "weekly on two days",
+			rule:   "FREQ=WEEKLY;BYDAY=MO,WE",
+			anchor: "2026-03-02T09:00:00Z", // a Monday
+			want:   []string{"2026-03-04T09:00:00Z", "2026-03-09T09:00:00Z", "2026-03-11T09:00:00Z"},
+		},
+		{
+			name:   "last day of the month across February",
+			rule:   "FREQ=MONTHLY;BYMONTHDAY=-1",
+			anchor: "2026-01-31T12:00:00Z",
+			want:   []string{"2026-02-28T12:00:00Z", "2026-03-31T12:00:00Z", "2026-04-30T12:00:00Z"},
+		},
+		{
+			name:   "last day of the month across a leap February",
+			rule:   "FREQ=MONTHLY;BYMONTHDAY=-1",
+			anchor: "2028-01-31T12:00:00Z",
+			want:   []string{"2028-02-29T12:00:00Z", "2028-03-31T12:00:00Z"},
+		},
+		{
+			name:   "every other day",
+			rule:   "FREQ=DAILY;INTERVAL=2",
+			anchor: "2026-03-02T09:00:00Z",
+			want:   []string{"2026-03-04T09:00:00Z", "2026-03-06T09:00:00Z"},
+		},
+		{
+			name:   "every other week, counted from the anchor's week",
+			rule:   "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU",
+			anchor: "2026-03-02T09:00:00Z",
+			want:   []string{"2026-03-03T09:00:00Z", "2026-03-17T09:00:00Z", "2026-03-31T09:00:00Z"},
+		},
+		{
+			name:   "every other month",
+			rule:   "FREQ=MONTHLY;INTERVAL=2",
+			anchor: "2026-01-15T10:00:00Z",
+			want:   []string{"2026-03-15T10:00:00Z", "2026-05-15T10:00:00Z"},
+		},
+		{
+			name:   "UNTIL is the last moment an occurrence may fall on",
+			rule:   "FREQ=DAILY;UNTIL=20260304T090000Z",
+			anchor: "2026-03-02T09:00:00Z",
+			want:   []string{"2026-03-03T09:00:00Z", "2026-03-04T09:00:00Z"},
+			ends:   true,
+		},
+		{
+			name:   "UNTIL as a date",
+			rule:   "FREQ=WEEKLY;UNTIL=20260316",
+			anchor: "2026-03-02T09:00:00Z",
+			want:   []string{"2026-03-09T09:00:00Z"},
+			ends:   true,
+		},
+		{
+			name:   "weekday and time default to the anchor's",
+			rule:   "FREQ=WEEKLY",
+			anchor: "2026-03-04T17:30:00Z", // a Wednesday
+			want:   []string{"2026-03-11T17:30:00Z", "2026-03-18T17:30:00Z"},
+		},
+		{
+			name:   "day of the month defaults to the anchor's",
+			rule:   "FREQ=MONTHLY",
+			anchor: "2026-01-10T08:15:00Z",
+			want:   []string{"2026-02-10T08:15:00Z", "2026-03-10T08:15:00Z"},
+		},
+		{
+			name:   "minute defaults to the anchor's and nothing falls before the anchor",
+			rule:   "FREQ=DAILY;BYHOUR=9",
+			anchor: "2026-03-02T17:45:00Z",
+			want:   []string{"2026-03-03T09:45:00Z", "2026-03-04T09:45:00Z"},
+		},
+		{
+			name:   "several times a day",
+			rule:   "FREQ=DAILY;BYHOUR=18,9;BYMINUTE=0",
+			anchor: "2026-03-02T12:00:00Z",
+			want:   []string{"2026-03-02T18:00:00Z", "2026-03-03T09:00:00Z", "2026-03-03T18:00:00Z"},
+		},
+		{
+			name:   "local time of day across a DST change",
+			rule:   "FREQ=WEEKLY;BYDAY=MO;BYHOUR=9;BYMINUTE=0;TZID=Europe/Paris",
+			anchor: "2026-03-16T08:00:00Z", // 9:00 CET
+			want:   []string{"2026-03-23T08:00:00Z", "2026-03-30T07:00:00Z"},
+		},
+		{
+			name:   "local weekday on the next UTC day",
+			rule:   "FREQ=WEEKLY;BYDAY=MO;BYHOUR=20;BYMINUTE=0;TZID=America/New_York",
+			anchor: "2026-03-02T12:00:00Z", // Monday 7:00 EST
+			want:   []string{"2026-03-03T01:00:00Z", "2026-03-10T00:00:00Z"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := ParseRecurrence(tt.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			anchor := mustTime(t, tt.anchor)
+
+			n := len(tt.want)
+			if tt.ends {
+				n++ // look for one too many
+			}
+			var got []string
+			after := anchor.Add(-time.Nanosecond)
+			for len(got) < n {
+				at, ok := r.Next(anchor, after)
+				if !ok {
+					break
+				}
+				got = append(got, at.Format(time.RFC3339))
+				after = at
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("occurrences = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecurrenceLatestDue(t *testing.T) {
+	weekly, err := ParseRecurrence("FREQ=WEEKLY")
+	if err != nil {
+		t.Fatal(err)
+	}
+	until, err := ParseRecurrence("FREQ=WEEKLY;UNTIL=20260310")
+	if err != nil {
+		t.Fatal(err)
+	}
+	anchor := mustTime(t, "2026-03-02T09:00:00Z")
+
+	tests := []struct {
+		name string
+		r    *Recurrence
+		last string
+		now  string
+		want string // empty when nothing is due
+	}{
+		{name: "the anchor is the template, not a copy", r: weekly, now: "2026-03-02T10:00:00Z"},
+		{name: "first run", r: weekly, now: "2026-03-09T10:00:00Z", want: "2026-03-09T09:00:00Z"},
+		{name: "not started yet", r: weekly, now: "2026-03-01T10:00:00Z"},
+		{name: "due exactly now", r: weekly, last: "2026-03-02T09:00:00Z", now: "2026-03-09T09:00:00Z", want: "2026-03-09T09:00:00Z"},
+		{name: "catch up from the anchor", r: weekly, now: "2026-03-20T00:00:00Z", want: "2026-03-16T09:00:00Z"},
+		{name: "catch up after the last run", r: weekly, last: "2026-03-02T09:00:00Z", now: "2026-03-20T00:00:00Z", want: "2026-03-16T09:00:00Z"},
+		{name: "latest already created", r: weekly, last: "2026-03-16T09:00:00Z", now: "2026-03-20T00:00:00Z"},
+		{name: "catch up stops at UNTIL", r: until, now: "2026-04-01T00:00:00Z", want: "2026-03-09T09:00:00Z"},
+		{name: "nothing after UNTIL", r: until, last: "2026-03-09T09:00:00Z", now: "2026-04-01T00:00:00Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var last *time.Time
+			if tt.last != "" {
+				l := mustTime(t, tt.last)
+				last = &l
+			}
+			at, ok := tt.r.latestDue(anchor, last, mustTime(t, tt.now))
+			got := ""
+			if ok {
+				got = at.Format(time.RFC3339)
+			}
+			if got != tt.want {
+				t.Errorf("latestDue = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// DueOccurrences skips the runs missed during downtime and returns only the
+// latest one.
+func TestDueOccurrencesCatchesUpWithTheLatestRun(t *testing.T) {
+	// The fake series is anchored at fakeTime, a Monday at 9:00.
+	db := openFakeDB(&fakeDB{columns: map[string]driver.Value{
+		"recurrence":         "FREQ=WEEKLY",
+		"last_occurrence_at": fakeTime.AddDate(0, 0, 7),
+	}})
+
+	due, err := DueOccurrences(db, fakeTime.AddDate(0, 0, 30))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Occurrence{{SeriesID: 1, At: fakeTime.AddDate(0, 0, 28)}}
+	if fmt.Sprint(due) != fmt.Sprint(want) {
+		t.Errorf("DueOccurrences = %v, want %v", due, want)
+	}
+}
+
+func TestParseRecurrenceTZID(t *testing.T) {
+	r, err := ParseRecurrence("FREQ=DAILY;TZID=America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Location == nil || r.Location.String() != "America/New_York" {
+		t.Errorf("Location = %v, want America/New_York", r.Location)
+	}
+	for _, rule := range []string{"FREQ=DAILY;TZID=Mars/Olympus", "FREQ=DAILY;TZID=Local"} {
+		if _, err := ParseRecurrence(rule); err == nil {
+			t.Errorf("%s: no error", rule)
+		}
+	}
+}
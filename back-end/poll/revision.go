@@ -0,0 +1,186 @@
+package poll
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Revision actions name the change that produced a revision.
+const (
+	RevisionCreated            = "created"
+	RevisionUpdated            = "updated"
+	RevisionTrashed            = "trashed"
+	RevisionRestored           = "restored"
+	RevisionQuestionAdded      = "question_added"
+	RevisionQuestionEdited     = "question_edited"
+	RevisionQuestionDeleted    = "question_deleted"
+	RevisionQuestionsReordered = "questions_reordered"
+	RevisionChoiceAdded        = "choice_added"
+	RevisionChoiceEdited       = "choice_edited"
+	RevisionChoiceDeleted      = "choice_deleted"
+	RevisionChoicesReordered   = "choices_reordered"
+	RevisionTagged             = "tagged"
+	RevisionUntagged           = "untagged"
+)
+
+// Revision records one change to a poll or any of its questions and
+// choices. Every change bumps the poll's version, so the version reached by
+// the change numbers the revision. Lifecycle transitions are recorded with
+// the target state as their action, e.g. "published".
+type Revision struct {
+	PollID  int64  `json:"poll_id"`
+	Version int64  `json:"version"`
+	Action  string `json:"action"`
+	// AuthorID is the X-User-ID of the request that made the change; it is
+	// nil for changes made by the scheduler or by anonymous requests.
+	AuthorID  *int64    `json:"author_id"`
+	CreatedAt time.Time `json:"created_at"`
+	// Poll is the poll as it was right after the change, with its questions
+	// and choices. Listings leave it out.
+	Poll *Poll `json:"poll,omitempty"`
+}
+
+// recordRevision stores the current state of a poll tree as a revision. It
+// must run in the transaction that made the change, after the change, so
+// the history can never disagree with what was committed. author 0 means
+// no known author.
+func recordRevision(tx dbtx, pollID, author int64, action string) error {
+	var p Poll
+	err := tx.QueryRow(`
+		SELECT id, slug, title, description, created_by, start_date, end_date, created_at, state, version,
+		       deleted_at, recurrence, series_id
+		FROM polls
+		WHERE id = ?`, pollID,
+	).Scan(&p.ID, &p.Slug, &p.Title, &p.Description, &p.CreatedBy, &p.StartDate, &p.EndDate, &p.CreatedAt,
+		&p.State, &p.Version, &p.DeletedAt, &p.Recurrence, &p.SeriesID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("poll %w", ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("recordRevision: %w", err)
+	}
+	polls := []Poll{p}
+	if err := loadQuestions(tx, polls, true); err != nil {
+		return err
+	}
+	if err := loadTags(tx, polls); err != nil {
+		return err
+	}
+	snapshot, err := json.Marshal(polls[0])
+	if err != nil {
+		return fmt.Errorf("recordRevision: %w", err)
+	}
+
+	var authorID interface{}
+	if author > 0 {
+		authorID = author
+	}
+	_, err = tx.Exec(`
+		INSERT INTO poll_revisions (poll_id, version, action, author_id, snapshot)
+		VALUES (?, ?, ?, ?, ?)`, pollID, p.Version, action, authorID, snapshot)
+	if err != nil {
+		return fmt.Errorf("recordRevision: %w", err)
+	}
+	return nil
+}
+
+// pollOfQuestion returns the ID of the poll owning a question.
+func pollOfQuestion(tx dbtx, questionID int64) (int64, error) {
+	var pollID int64
+	err := tx.QueryRow("SELECT poll_id FROM questions WHERE id = ?", questionID).Scan(&pollID)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("question %w", ErrNotFound)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("pollOfQuestion: %w", err)
+	}
+	return pollID, nil
+}
+
+// pollOfChoice returns the ID of the poll owning a choice.
+func pollOfChoice(tx dbtx, choiceID int64) (int64, error) {
+	var pollID int64
+	err := tx.QueryRow(`
+		SELECT q.poll_id FROM choices c
+		JOIN questions q ON q.id = c.question_id
+		WHERE c.id = ?`, choiceID).Scan(&pollID)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("choice %w", ErrNotFound)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("pollOfChoice: %w", err)
+	}
+	return pollID, nil
+}
+
+// revisionSortColumns are the fields ListRevisions can sort by. The version
+// is unique within a poll, so it also breaks ties.
+var revisionSortColumns = map[string]string{
+	"version": "r.version",
+}
+
+// ListRevisions retrieves one page of a poll's revisions, newest first by
+// default, without their snapshots, and the cursor of the next page.
+func ListRevisions(db *sql.DB, pollID int64, opts ListOptions) ([]Revision, string, error) {
+	opts.normalize("-version")
+
+	var lq listQuery
+	lq.add("r.poll_id = ?", pollID)
+	tail, err := lq.page(opts, revisionSortColumns, "r.version")
+	if err != nil {
+		return nil, "", err
+	}
+
+	rows, err := db.Query(`
+		SELECT r.poll_id, r.version, r.action, r.author_id, r.created_at
+		FROM poll_revisions r`+lq.whereClause()+tail, lq.args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("ListRevisions: %w", err)
+	}
+	defer rows.Close()
+
+	revisions := []Revision{}
+	for rows.Next() {
+		var rev Revision
+		if err := rows.Scan(&rev.PollID, &rev.Version, &rev.Action, &rev.AuthorID, &rev.CreatedAt); err != nil {
+			return nil, "", fmt.Errorf("ListRevisions scan: %w", err)
+		}
+		revisions = append(revisions, rev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("ListRevisions: %w", err)
+	}
+
+	next := ""
+	if len(revisions) > opts.Limit {
+		revisions = revisions[:opts.Limit]
+		last := revisions[len(revisions)-1]
+		next = encodeCursor(pageCursor{Sort: opts.Sort, ID: last.Version})
+	}
+	return revisions, next, nil
+}
+
+// GetRevision returns a poll as it was at the given version, or nil when the
+// poll has no such revision.
+func GetRevision(db *sql.DB, pollID, version int64) (*Revision, error) {
+	var rev Revision
+	var snapshot []byte
+	err := db.QueryRow(`
+		SELECT poll_id, version, action, author_id, created_at, snapshot
+		FROM poll_revisions
+		WHERE poll_id = ? AND version = ?`, pollID, version,
+	).Scan(&rev.PollID, &rev.Version, &rev.Action, &rev.AuthorID, &rev.CreatedAt, &snapshot)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("GetRevision: %w", err)
+	}
+	rev.Poll = &Poll{}
+	if err := json.Unmarshal(snapshot, rev.Poll); err != nil {
+		return nil, fmt.Errorf("GetRevision snapshot: %w", err)
+	}
+	return &rev, nil
+}
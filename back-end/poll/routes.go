@@ -0,0 +1,278 @@
+package poll
+
+import (
+	"net/http"
+
+	"simple-poll/middleware"
+)
+
+// Route documents one endpoint for the OpenAPI document. Path is relative to
+// the version's base path, e.g. /polls/{id} under /api/v1. Request and
+// Response are zero values of the types the handler decodes and encodes.
+type Route struct {
+	Method      string
+	Path        string
+	OperationID string
+	Tag         string
+	Summary     string
+	Query       []QueryParam
+	// Headers are the request headers the handler reads. Those read by the
+	// middleware in front of every router are added by buildSpec.
+	Headers  []HeaderParam
+	Request  interface{}
+	Response interface{}
+	// Produces is the media type of a success body that is not JSON, such as
+	// an image; Response is nil then.
+	Produces string
+	// Status is the success status code; zero means 200.
+	Status int
+	// Errors are statuses the handler answers that the spec names besides
+	// those of its headers and the 422 of an invalid Request body.
+	Errors []int
+}
+
+// QueryParam documents a query string parameter.
+type QueryParam struct {
+	Name        string
+	Type        string
+	Required    bool
+	Description string
+}
+
+// HeaderParam documents a request header, with the statuses answered when it
+// is missing, does not match, or matches, e.g. 428, 412 and 304.
+type HeaderParam struct {
+	Name        string
+	Required    bool
+	Description string
+	Statuses    []int
+}
+
+var (
+	// ifMatch is read by every write through ifMatchVersion.
+	ifMatch = HeaderParam{Name: "If-Match", Required: true,
+		Description: "ETag from an earlier response, or * to write whatever the current version",
+		Statuses:    []int{http.StatusPreconditionFailed, http.StatusPreconditionRequired}}
+	// optionalIfMatch is checked only when sent.
+	optionalIfMatch = HeaderParam{Name: "If-Match",
+		Description: "ETag from an earlier response; the change is refused once the poll has changed",
+		Statuses:    []int{http.StatusPreconditionFailed}}
+	// ifNoneMatch is read by the GETs that answer with an ETag.
+	ifNoneMatch = HeaderParam{Name: "If-None-Match",
+		Description: "ETag from an earlier response; answered with 304 and no body while it is current",
+		Statuses:    []int{http.StatusNotModified}}
+	// caller is read by callerID, optionalCaller by optionalCallerID.
+	caller = HeaderParam{Name: UserIDHeader, Required: true,
+		Description: "ID of the user the request acts for",
+		Statuses:    []int{http.StatusUnauthorized}}
+	optionalCaller = HeaderParam{Name: UserIDHeader,
+		Description: "ID of the user the request acts for, recorded as the author of the change"}
+
+	idempotencyKey = HeaderParam{Name: middleware.IdempotencyKeyHeader,
+		Description: "Unique per request; a retry with the same key and body is answered with the first response",
+		Statuses:    []int{http.StatusConflict, http.StatusUnprocessableEntity}}
+	csrfToken = HeaderParam{Name: middleware.CSRFHeaderName,
+		Description: "Required with cookies: the csrf_token cookie, also sent in this response header",
+		Statuses:    []int{http.StatusForbidden}}
+)
+
+// middlewareHeaders are the headers the middleware main.go puts in front of
+// every router reads for method: the CSRF check on writes and Idempotency on
+// POSTs.
+func middlewareHeaders(method string) []HeaderParam {
+	var headers []HeaderParam
+	if method == http.MethodPost {
+		headers = append(headers, idempotencyKey)
+	}
+	if method != http.MethodGet {
+		headers = append(headers, csrfToken)
+	}
+	return headers
+}
+
+// qrParams are accepted by the QR code endpoints (see qrHandler).
+var qrParams = []QueryParam{
+	{Name: "size", Type: "integer", Description: "Image width and height in pixels, 64 to 4096; 512 by default"},
+	{Name: "ecc", Type: "string", Description: "Error correction level, L, M, Q or H; M by default"},
+	{Name: "token", Type: "string", Description: "Pre-issued voting token to include in the link"},
+}
+
+// listParams are accepted by every list endpoint (see parseListOptions).
+var listParams = []QueryParam{
+	{Name: "limit", Type: "integer", Description: "Page size, at most 200"},
+	{Name: "cursor", Type: "string", Description: "next_cursor from the previous page"},
+	{Name: "sort", Type: "string", Description: "Sort field, prefixed with - for descending order"},
+	{Name: "created_by", Type: "integer", Description: "Only rows of polls created by this user"},
+	{Name: "status", Type: "string", Description: "active, upcoming or closed, by the poll's dates"},
+	{Name: "state", Type: "string", Description: "draft, published, closed or archived"},
+	{Name: "series_id", Type: "integer", Description: "Only rows of occurrences of this recurring poll"},
+	{Name: "tag", Type: "string", Description: "Only rows of polls with this tag; repeat to require several"},
+	{Name: "created_after", Type: "string", Description: "RFC 3339 timestamp"},
+	{Name: "created_before", Type: "string", Description: "RFC 3339 timestamp"},
+	{Name: "starts_after", Type: "string", Description: "RFC 3339 timestamp"},
+	{Name: "starts_before", Type: "string", Description: "RFC 3339 timestamp"},
+	{Name: "ends_after", Type: "string", Description: "RFC 3339 timestamp"},
+	{Name: "ends_before", Type: "string", Description: "RFC 3339 timestamp"},
+	{Name: "fields", Type: "string", Description: "Comma-separated fields to keep in each item, e.g. id,title"},
+}
+
+func withParams(base []QueryParam, extra ...QueryParam) []QueryParam {
+	return append(append([]QueryParam{}, extra...), base...)
+}
+
+// Routes lists every endpoint served by the routers in this package. Keep it
+// in step with the routers: routes_test.go fails when a dispatch branch is
+// missing here or a handler's response differs from its documented schema,
+// and test_openapi.sh checks the same against a running server.
+var Routes = []Route{
+	// Polls
+	{Method: http.MethodGet, Path: "/polls/", OperationID: "listPolls", Tag: "polls",
+		Summary:  "List polls",
+		Query:    withParams(listParams, QueryParam{Name: "include", Type: "string", Description: "questions and/or choices, comma-separated, to embed each poll's tree"}),
+		Response: Page[Poll]{}},
+	{Method: http.MethodPost, Path: "/polls/", OperationID: "createPoll", Tag: "polls",
+		Headers: []HeaderParam{optionalCaller},
+		Summary: "Create a poll, optionally with nested questions and choices", Request: Poll{}, Response: Poll{}},
+	{Method: http.MethodGet, Path: "/polls/{id}", OperationID: "getPoll", Tag: "polls",
+		Headers: []HeaderParam{ifNoneMatch},
+		Summary: "Get a poll, by ID or slug, with its questions and choices", Response: Poll{}},
+	{Method: http.MethodPut, Path: "/polls/{id}", OperationID: "replacePoll", Tag: "polls",
+		Headers: []HeaderParam{ifMatch, optionalCaller},
+		Summary: "Replace the title, description, dates, recurrence and slug of a poll", Request: Poll{}, Response: Poll{}},
+	{Method: http.MethodPatch, Path: "/polls/{id}", OperationID: "updatePoll", Tag: "polls",
+		Headers: []HeaderParam{ifMatch, optionalCaller},
+		Summary: "Change only the poll fields present in the body", Request: Poll{}, Response: Poll{}},
+	{Method: http.MethodDelete, Path: "/polls/{id}", OperationID: "deletePoll", Tag: "polls",
+		Headers: []HeaderParam{ifMatch, optionalCaller},
+		Summary: "Move a poll to the trash", Response: messageResponse{}},
+	{Method: http.MethodGet, Path: "/polls/trash", OperationID: "listTrash", Tag: "polls",
+		Summary: "List polls in the trash, most recently deleted first", Query: listParams, Response: Page[Poll]{}},
+	{Method: http.MethodGet, Path: "/polls/{id}/occurrences", OperationID: "listOccurrences", Tag: "polls",
+		Summary:  "List the polls created from a recurring poll, latest first",
+		Query:    withParams(listParams, QueryParam{Name: "include", Type: "string", Description: "questions and/or choices, comma-separated, to embed each poll's tree"}),
+		Response: Page[Poll]{}},
+	{Method: http.MethodGet, Path: "/polls/{id}/revisions", OperationID: "listRevisions", Tag: "polls",
+		Summary: "List the changes made to a poll and its questions and choices, newest first",
+		Query: []QueryParam{
+			{Name: "limit", Type: "integer", Description: "Page size, at most 200"},
+			{Name: "cursor", Type: "string", Description: "next_cursor from the previous page"},
+			{Name: "sort", Type: "string", Description: "version or -version"},
+		},
+		Response: Page[Revision]{}},
+	{Method: http.MethodGet, Path: "/polls/{id}/revisions/{version}", OperationID: "getRevision", Tag: "polls",
+		Summary: "Get a poll with its questions and choices as of a revision", Response: Revision{}},
+	{Method: http.MethodGet, Path: "/polls/{id}/qr.png", OperationID: "getPollQRCodePNG", Tag: "polls",
+		Headers: []HeaderParam{ifNoneMatch},
+		Summary: "Get a PNG QR code of the poll's voting link", Query: qrParams, Produces: "image/png"},
+	{Method: http.MethodGet, Path: "/polls/{id}/qr.svg", OperationID: "getPollQRCodeSVG", Tag: "polls",
+		Headers: []HeaderParam{ifNoneMatch},
+		Summary: "Get an SVG QR code of the poll's voting link", Query: qrParams, Produces: "image/svg+xml"},
+	{Method: http.MethodPost, Path: "/polls/{id}/clone", OperationID: "clonePoll", Tag: "polls",
+		Headers: []HeaderParam{caller},
+		Summary: "Copy a poll with its questions and choices into a new draft owned by the X-User-ID caller",
+		Request: CloneRequest{}, Response: Poll{}},
+	{Method: http.MethodPost, Path: "/polls/{id}/restore", OperationID: "restorePoll", Tag: "polls",
+		Headers: []HeaderParam{optionalCaller},
+		Summary: "Take a poll out of the trash", Response: Poll{}},
+	{Method: http.MethodPost, Path: "/polls/{id}/publish", OperationID: "publishPoll", Tag: "polls",
+		Headers: []HeaderParam{optionalIfMatch, optionalCaller},
+		Summary: "Publish a draft poll, opening it for voting within its dates", Response: Poll{}},
+	{Method: http.MethodPost, Path: "/polls/{id}/close", OperationID: "closePoll", Tag: "polls",
+		Headers: []HeaderParam{optionalIfMatch, optionalCaller},
+		Summary: "Close a published poll to further votes", Response: Poll{}},
+	{Method: http.MethodPost, Path: "/polls/{id}/archive", OperationID: "archivePoll", Tag: "polls",
+		Headers: []HeaderParam{optionalIfMatch, optionalCaller},
+		Summary: "Archive a closed poll, making it read-only", Response: Poll{}},
+	{Method: http.MethodPut, Path: "/polls/{id}/questions/order", OperationID: "reorderQuestions", Tag: "questions",
+		Headers: []HeaderParam{ifMatch, optionalCaller},
+		Summary: "Set the order of all questions of a poll; If-Match carries the poll's ETag", Request: OrderRequest{}, Response: Page[Question]{}},
+	{Method: http.MethodPut, Path: "/polls/{id}/tags/{tag}", OperationID: "tagPoll", Tag: "tags",
+		Headers: []HeaderParam{ifMatch, optionalCaller}, Errors: []int{http.StatusUnprocessableEntity},
+		Summary: "Add a tag to a poll; adding a tag it already has changes nothing. If-Match carries the poll's ETag", Response: Poll{}},
+	{Method: http.MethodDelete, Path: "/polls/{id}/tags/{tag}", OperationID: "untagPoll", Tag: "tags",
+		Headers: []HeaderParam{ifMatch, optionalCaller}, Errors: []int{http.StatusUnprocessableEntity},
+		Summary: "Remove a tag from a poll; If-Match carries the poll's ETag", Response: Poll{}},
+
+	// Questions
+	{Method: http.MethodGet, Path: "/questions/", OperationID: "listQuestions", Tag: "questions",
+		Summary: "List questions",
+		Query: withParams(listParams,
+			QueryParam{Name: "poll_id", Type: "integer", Description: "Only questions of this poll"},
+			QueryParam{Name: "include", Type: "string", Description: "choices, to embed each question's choices"}),
+		Response: Page[Question]{}},
+	{Method: http.MethodPost, Path: "/questions/", OperationID: "createQuestion", Tag: "questions",
+		Headers: []HeaderParam{optionalCaller},
+		Summary: "Create a question", Request: Question{}, Response: Question{}},
+	{Method: http.MethodGet, Path: "/questions/{id}", OperationID: "getQuestion", Tag: "questions",
+		Headers: []HeaderParam{ifNoneMatch},
+		Summary: "Get a question", Response: Question{}},
+	{Method: http.MethodPut, Path: "/questions/{id}", OperationID: "updateQuestion", Tag: "questions",
+		Headers: []HeaderParam{ifMatch, optionalCaller},
+		Summary: "Update the text of a question", Request: Question{}, Response: Question{}},
+	{Method: http.MethodDelete, Path: "/questions/{id}", OperationID: "deleteQuestion", Tag: "questions",
+		Headers: []HeaderParam{ifMatch, optionalCaller},
+		Summary: "Delete a question", Response: messageResponse{}},
+	{Method: http.MethodPut, Path: "/questions/{id}/choices/order", OperationID: "reorderChoices", Tag: "choices",
+		Headers: []HeaderParam{ifMatch, optionalCaller},
+		Summary: "Set the order of all choices of a question; If-Match carries the poll's ETag", Request: OrderRequest{}, Response: Page[Choice]{}},
+
+	// Choices
+	{Method: http.MethodGet, Path: "/choices/", OperationID: "listChoices", Tag: "choices",
+		Summary:  "List choices",
+		Query:    withParams(listParams, QueryParam{Name: "question_id", Type: "integer", Description: "Only choices of this question"}),
+		Response: Page[Choice]{}},
+	{Method: http.MethodPost, Path: "/choices/", OperationID: "createChoice", Tag: "choices",
+		Headers: []HeaderParam{optionalCaller},
+		Summary: "Create a choice", Request: Choice{}, Response: Choice{}},
+	{Method: http.MethodGet, Path: "/choices/{id}", OperationID: "getChoice", Tag: "choices",
+		Headers: []HeaderParam{ifNoneMatch},
+		Summary: "Get a choice", Response: Choice{}},
+	{Method: http.MethodPut, Path: "/choices/{id}", OperationID: "updateChoice", Tag: "choices",
+		Headers: []HeaderParam{ifMatch, optionalCaller},
+		Summary: "Update the text of a choice", Request: Choice{}, Response: Choice{}},
+	{Method: http.MethodDelete, Path: "/choices/{id}", OperationID: "deleteChoice", Tag: "choices",
+		Headers: []HeaderParam{ifMatch, optionalCaller},
+		Summary: "Delete a choice", Response: messageResponse{}},
+
+	// Templates
+	{Method: http.MethodGet, Path: "/templates/", OperationID: "listTemplates", Tag: "templates",
+		Headers: []HeaderParam{optionalCaller},
+		Summary: "List the built-in templates and those of the X-User-ID caller's organizations",
+		Query: []QueryParam{
+			{Name: "organization_id", Type: "integer", Description: "Only templates of this organization"},
+		},
+		Response: Page[Template]{}},
+	{Method: http.MethodPost, Path: "/templates/", OperationID: "createTemplate", Tag: "templates",
+		Headers: []HeaderParam{caller},
+		Summary: "Publish a template for an organization the X-User-ID caller belongs to", Request: Template{}, Response: Template{}},
+	{Method: http.MethodGet, Path: "/templates/{id}", OperationID: "getTemplate", Tag: "templates",
+		Headers: []HeaderParam{optionalCaller},
+		Summary: "Get a template", Response: Template{}},
+	{Method: http.MethodDelete, Path: "/templates/{id}", OperationID: "deleteTemplate", Tag: "templates",
+		Headers: []HeaderParam{caller},
+		Summary: "Delete an organization template", Response: messageResponse{}},
+	{Method: http.MethodPost, Path: "/templates/{id}/polls", OperationID: "instantiateTemplate", Tag: "templates",
+		Headers: []HeaderParam{caller},
+		Summary: "Create a draft poll owned by the X-User-ID caller from a template", Request: InstantiateRequest{}, Response: Poll{}},
+
+	// Tags
+	{Method: http.MethodGet, Path: "/tags/", OperationID: "tagCloud", Tag: "tags",
+		Summary: "List the most used tags with the number of polls carrying each",
+		Query: []QueryParam{
+			{Name: "limit", Type: "integer", Description: "Number of tags, at most 200; 100 by default"},
+		},
+		Response: Page[TagCount]{}},
+
+	// Search
+	{Method: http.MethodGet, Path: "/search", OperationID: "search", Tag: "search",
+		Summary: "Search polls, questions and choices by keyword",
+		Query: []QueryParam{
+			{Name: "q", Type: "string", Required: true, Description: "Keywords"},
+			{Name: "limit", Type: "integer", Description: "Number of polls, at most 100"},
+		},
+		Response: []SearchResult{}},
+
+	// Meta
+	{Method: http.MethodGet, Path: "/openapi.json", OperationID: "getOpenAPI", Tag: "meta",
+		Summary: "This OpenAPI document", Response: map[string]interface{}{}},
+}
@@ -0,0 +1,571 @@
+package poll
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"simple-poll/apierror"
+)
+
+// testAPI mounts every router of the package under v's base path, the way
+// main.go does.
+func testAPI(db *sql.DB, v *APIVersion) http.Handler {
+	searcher := NewMemorySearcher()
+	searcher.Index(Poll{ID: 1, Title: "Team lunch"})
+
+	base := v.BasePath()
+	mux := http.NewServeMux()
+	mux.Handle(base+"/polls/", http.StripPrefix(base+"/polls", WithVersion(v, PollRouter(db, DefaultVoteBaseURL))))
+	mux.Handle(base+"/questions/", http.StripPrefix(base+"/questions", WithVersion(v, QuestionRouter(db))))
+	mux.Handle(base+"/choices/", http.StripPrefix(base+"/choices", WithVersion(v, ChoiceRouter(db))))
+	mux.Handle(base+"/templates/", http.StripPrefix(base+"/templates", WithVersion(v, TemplateRouter(db))))
+	mux.Handle(base+"/tags/", http.StripPrefix(base+"/tags", WithVersion(v, TagRouter(db))))
+	mux.Handle(base+"/search", WithVersion(v, SearchRouter(searcher)))
+	mux.Handle(base+"/openapi.json", WithVersion(v, OpenAPIHandler()))
+	return mux
+}
+
+// routerFiles are the sources whose dispatch code the routes are checked
+// against.
+const routerFiles = "*_handlers.go"
+
+// sampleBodies holds a valid request body for every type a Route decodes.
+var sampleBodies = map[reflect.Type]interface{}{
+	reflect.TypeOf(Poll{}): Poll{
+		Title:     "Team lunch",
+		CreatedBy: 1,
+		Questions: []Question{{
+			Text:    "Where?",
+			Choices: []Choice{{Text: "Pizza"}, {Text: "Tacos"}},
+		}},
+	},
+	reflect.TypeOf(Question{}):           Question{PollID: 1, Text: "Where?"},
+	reflect.TypeOf(Choice{}):             Choice{QuestionID: 1, Text: "Pizza"},
+	reflect.TypeOf(CloneRequest{}):       CloneRequest{Title: "Team lunch again"},
+	reflect.TypeOf(OrderRequest{}):       OrderRequest{IDs: []int64{1}},
+	reflect.TypeOf(InstantiateRequest{}): InstantiateRequest{},
+	reflect.TypeOf(Template{}): Template{
+		Name:           "Lunch",
+		OrganizationID: func() *int64 { id := int64(1); return &id }(),
+		Title:          "Team lunch",
+		DurationDays:   7,
+		Questions: []Question{{
+			Text:    "Where?",
+			Choices: []Choice{{Text: "Pizza"}, {Text: "Tacos"}},
+		}},
+	},
+}
+
+// routeColumns overrides fakeDB columns for routes that need a poll in
+// another state than draft.
+var routeColumns = map[string]map[string]driver.Value{
+	"closePoll":   {"state": string(StatePublished)},
+	"archivePoll": {"state": string(StateClosed)},
+}
+
+// routeRequest builds a request that rt's handler should answer with
+// success under version v.
+func routeRequest(t *testing.T, rt Route, v *APIVersion) *http.Request {
+	t.Helper()
+	path := strings.NewReplacer("{id}", "1", "{version}", "1", "{tag}", "lunch").Replace(rt.Path)
+	query := make([]string, 0)
+	for _, qp := range rt.Query {
+		if qp.Required {
+			query = append(query, qp.Name+"=lunch")
+		}
+	}
+	if len(query) > 0 {
+		path += "?" + strings.Join(query, "&")
+	}
+
+	var body []byte
+	if rt.Request != nil {
+		sample, ok := sampleBodies[reflect.TypeOf(rt.Request)]
+		if !ok {
+			t.Fatalf("no sample body for %T; add one to sampleBodies", rt.Request)
+		}
+		r := httptest.NewRequest(rt.Method, "/", nil)
+		var err error
+		body, err = encodeJSON(r.WithContext(context.WithValue(r.Context(), versionKey{}, v)), sample)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := httptest.NewRequest(rt.Method, v.BasePath()+path, bytes.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set(UserIDHeader, "1")
+	r.Header.Set("If-Match", "*")
+	return r
+}
+
+// TestRoutesMatchHandlers runs every documented route against its handler
+// and checks that the response is the documented one, down to the keys of
+// every JSON object.
+func TestRoutesMatchHandlers(t *testing.T) {
+	for _, v := range Versions {
+		spec := OpenAPISpec(v)
+		components := spec["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+		for _, rt := range Routes {
+			rt, v := rt, v
+			t.Run(v.Name+" "+rt.OperationID, func(t *testing.T) {
+				api := testAPI(openFakeDB(&fakeDB{columns: routeColumns[rt.OperationID]}), v)
+				rec := httptest.NewRecorder()
+				api.ServeHTTP(rec, routeRequest(t, rt, v))
+
+				status := rt.Status
+				if status == 0 {
+					status = http.StatusOK
+				}
+				if rec.Code != status {
+					t.Fatalf("%s %s: status %d, want %d; body %s", rt.Method, rt.Path, rec.Code, status, rec.Body)
+				}
+				if rt.Produces != "" {
+					if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, rt.Produces) {
+						t.Errorf("Content-Type = %q, want %q", got, rt.Produces)
+					}
+					return
+				}
+
+				dec := json.NewDecoder(rec.Body)
+				dec.UseNumber()
+				var body interface{}
+				if err := dec.Decode(&body); err != nil {
+					t.Fatalf("decode response: %v", err)
+				}
+				op := spec["paths"].(map[string]map[string]interface{})[rt.Path][strings.ToLower(rt.Method)].(map[string]interface{})
+				schema := op["responses"].(map[string]interface{})[strconv.Itoa(status)].(map[string]interface{})["content"].(map[string]interface{})["application/json"].(map[string]interface{})["schema"].(map[string]interface{})
+				c := schemaChecker{t: t, components: components, optional: optionalFields(reflect.TypeOf(rt.Response), v)}
+				c.check("body", body, schema)
+			})
+		}
+	}
+}
+
+// TestRoutesDocumentHeaders checks the headers each route documents against
+// what its handler does when they are left out or do not match, and that the
+// statuses they lead to are documented too.
+func TestRoutesDocumentHeaders(t *testing.T) {
+	v := Versions[0]
+	spec := OpenAPISpec(v)
+	for _, rt := range Routes {
+		op := spec["paths"].(map[string]map[string]interface{})[rt.Path][strings.ToLower(rt.Method)].(map[string]interface{})
+		responses := op["responses"].(map[string]interface{})
+		headers := make(map[string]bool) // name: required
+		params, _ := op["parameters"].([]interface{})
+		for _, p := range params {
+			p := p.(map[string]interface{})
+			if p["in"] == "header" {
+				headers[p["name"].(string)] = p["required"].(bool)
+			}
+		}
+		serve := func(edit func(r *http.Request)) int {
+			r := routeRequest(t, rt, v)
+			edit(r)
+			rec := httptest.NewRecorder()
+			testAPI(openFakeDB(&fakeDB{columns: routeColumns[rt.OperationID]}), v).ServeHTTP(rec, r)
+			return rec.Code
+		}
+		check := func(header string, required bool, observed bool, what string, statuses ...int) {
+			documented, ok := headers[header]
+			if required {
+				ok = ok && documented
+			}
+			if observed != ok {
+				t.Errorf("%s %s: %s, but %s documented=%v", rt.Method, rt.Path, what, header, ok)
+			}
+			for _, code := range statuses {
+				if _, listed := responses[strconv.Itoa(code)]; observed && !listed {
+					t.Errorf("%s %s: %d is not documented", rt.Method, rt.Path, code)
+				}
+			}
+		}
+
+		got := serve(func(r *http.Request) { r.Header.Del("If-Match") })
+		check("If-Match", true, got == http.StatusPreconditionRequired,
+			fmt.Sprintf("without If-Match: %d", got), http.StatusPreconditionRequired, http.StatusPreconditionFailed)
+		if got := serve(func(r *http.Request) { r.Header.Set("If-Match", `"v1.99"`) }); got == http.StatusPreconditionFailed {
+			check("If-Match", false, true, "a stale If-Match: 412", http.StatusPreconditionFailed)
+		}
+		got = serve(func(r *http.Request) { r.Header.Del(UserIDHeader) })
+		check(UserIDHeader, true, got == http.StatusUnauthorized,
+			fmt.Sprintf("without %s: %d", UserIDHeader, got), http.StatusUnauthorized)
+		got = serve(func(r *http.Request) { r.Header.Set(UserIDHeader, "someone") })
+		check(UserIDHeader, false, got == http.StatusBadRequest, fmt.Sprintf("an invalid %s: %d", UserIDHeader, got))
+		if rt.Method == http.MethodGet {
+			got = serve(func(r *http.Request) { r.Header.Set("If-None-Match", "*") })
+			check("If-None-Match", false, got == http.StatusNotModified,
+				fmt.Sprintf("with If-None-Match: %d", got), http.StatusNotModified)
+		}
+		if _, ok := responses[strconv.Itoa(http.StatusTooManyRequests)]; !ok {
+			t.Errorf("%s %s: 429 is not documented", rt.Method, rt.Path)
+		}
+	}
+}
+
+// schemaChecker compares a decoded JSON value with a schema of the spec.
+type schemaChecker struct {
+	t          *testing.T
+	components map[string]interface{}
+	// optional lists, by component name, the properties that may be left out
+	// because they are tagged omitempty.
+	optional map[string]map[string]bool
+}
+
+func (c schemaChecker) check(at string, value interface{}, schema map[string]interface{}) {
+	c.t.Helper()
+	component := ""
+	for {
+		if ref, ok := schema["$ref"].(string); ok {
+			component = strings.TrimPrefix(ref, "#/components/schemas/")
+			schema = c.components[component].(map[string]interface{})
+		} else if all, ok := schema["allOf"].([]interface{}); ok {
+			schema = all[0].(map[string]interface{})
+		} else {
+			break
+		}
+	}
+	if value == nil {
+		if schema["nullable"] != true {
+			c.t.Errorf("%s: null, but the schema is not nullable", at)
+		}
+		return
+	}
+
+	switch schema["type"] {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			c.t.Errorf("%s: got %T, want an object", at, value)
+			return
+		}
+		props, ok := schema["properties"].(map[string]interface{})
+		if !ok {
+			items, _ := schema["additionalProperties"].(map[string]interface{})
+			for k, v := range obj {
+				c.check(at+"."+k, v, items)
+			}
+			return
+		}
+		for k := range obj {
+			if _, ok := props[k]; !ok {
+				c.t.Errorf("%s: key %q is not a property of the schema", at, k)
+			}
+		}
+		for k, p := range props {
+			v, ok := obj[k]
+			if !ok {
+				if !c.optional[component][k] {
+					c.t.Errorf("%s: schema property %q is missing", at, k)
+				}
+				continue
+			}
+			c.check(at+"."+k, v, p.(map[string]interface{}))
+		}
+	case "array":
+		arr, ok := value.([]interface{})
+		if !ok {
+			c.t.Errorf("%s: got %T, want an array", at, value)
+			return
+		}
+		for i, v := range arr {
+			c.check(fmt.Sprintf("%s[%d]", at, i), v, schema["items"].(map[string]interface{}))
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			c.t.Errorf("%s: got %T, want a string", at, value)
+		}
+	case "integer", "number":
+		if _, ok := value.(json.Number); !ok {
+			c.t.Errorf("%s: got %T, want a number", at, value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			c.t.Errorf("%s: got %T, want a boolean", at, value)
+		}
+	}
+}
+
+// optionalFields collects the omitempty fields of every struct reachable
+// from t, by component name, under the field names of version v.
+func optionalFields(t reflect.Type, v *APIVersion) map[string]map[string]bool {
+	out := make(map[string]map[string]bool)
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+			walk(t.Elem())
+			return
+		case reflect.Struct:
+		default:
+			return
+		}
+		if t == timeType || t.Name() == "" {
+			return
+		}
+		name := schemaName(t)
+		if _, done := out[name]; done {
+			return
+		}
+		out[name] = make(map[string]bool)
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			field, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if strings.Contains(opts, "omitempty") {
+				out[name][v.fieldName(t, field)] = true
+			}
+			walk(f.Type)
+		}
+	}
+	walk(t)
+	return out
+}
+
+// TestRoutesDocumentEveryDispatchBranch sends every method to every path the
+// routers could dispatch on, going by the path segments they compare, and
+// checks that each request served is documented in Routes and that each
+// documented route is served.
+func TestRoutesDocumentEveryDispatchBranch(t *testing.T) {
+	segments := dispatchSegments(t)
+	api := testAPI(openFakeDB(&fakeDB{}), V1)
+	prefixes := []string{"/polls/", "/questions/", "/choices/", "/templates/", "/tags/", "/search", "/openapi.json"}
+	methods := []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+	// Any value stands for a path parameter; slugs and IDs alike.
+	candidates := func(i int) []string {
+		if i >= len(segments) {
+			return []string{"", "1", "lunch"}
+		}
+		return append([]string{"", "1", "lunch"}, segments[i]...)
+	}
+	var paths []string
+	var expand func(prefix string, i int)
+	expand = func(prefix string, i int) {
+		for _, s := range candidates(i) {
+			p := prefix + s
+			paths = append(paths, p)
+			if i+1 <= len(segments) && s != "" {
+				expand(p+"/", i+1)
+			}
+		}
+	}
+	for _, prefix := range prefixes {
+		if strings.HasSuffix(prefix, "/") {
+			expand(prefix, 0)
+		} else {
+			paths = append(paths, prefix)
+		}
+	}
+
+	served := make(map[string]bool)
+	for _, p := range paths {
+		for _, method := range methods {
+			rec := httptest.NewRecorder()
+			api.ServeHTTP(rec, httptest.NewRequest(method, V1.BasePath()+p, strings.NewReader("{}")))
+			if isRouteNotFound(rec) {
+				continue
+			}
+			rt, ok := documentedRoute(method, p)
+			if !ok {
+				t.Errorf("%s %s is served (status %d) but not documented in Routes", method, p, rec.Code)
+				continue
+			}
+			served[rt.Method+" "+rt.Path] = true
+		}
+	}
+	for _, rt := range Routes {
+		if !served[rt.Method+" "+rt.Path] {
+			t.Errorf("%s %s is documented but no handler serves it", rt.Method, rt.Path)
+		}
+	}
+}
+
+func isRouteNotFound(rec *httptest.ResponseRecorder) bool {
+	if rec.Code != http.StatusNotFound {
+		return false
+	}
+	var body errorEnvelope
+	return json.Unmarshal(rec.Body.Bytes(), &body) == nil && body.Error.Code == apierror.CodeRouteNotFound
+}
+
+// documentedRoute finds the Route whose path matches p, a path parameter
+// matching any segment; handlers answer empty ones as bad requests. Literal
+// segments win over parameters, so /polls/trash is not /polls/{id}.
+func documentedRoute(method, p string) (Route, bool) {
+	got := strings.Split(p, "/")
+	best, bestLiterals := -1, -1
+	for i, rt := range Routes {
+		want := strings.Split(rt.Path, "/")
+		if rt.Method != method || len(want) != len(got) {
+			continue
+		}
+		literals := 0
+		for j := range want {
+			if strings.HasPrefix(want[j], "{") {
+				continue
+			}
+			if want[j] != got[j] {
+				literals = -1
+				break
+			}
+			literals++
+		}
+		if literals > bestLiterals {
+			best, bestLiterals = i, literals
+		}
+	}
+	if best < 0 {
+		return Route{}, false
+	}
+	return Routes[best], true
+}
+
+// dispatchSegments returns, by position, the literal path segments the
+// routers compare: parts[1] == "clone" gives "clone" at position 1, a lookup
+// such as qrFormats[parts[1]] gives the keys of that map, and path == "x/y"
+// gives "x" and "y".
+func dispatchSegments(t *testing.T) [][]string {
+	t.Helper()
+	files, err := filepath.Glob(routerFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	allFiles, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fset := token.NewFileSet()
+	mapKeys := make(map[string][]string) // package-level map literal => string keys
+	var parsed []*ast.File
+	for _, name := range allFiles {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.VAR {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				vs := spec.(*ast.ValueSpec)
+				for i, value := range vs.Values {
+					lit, ok := value.(*ast.CompositeLit)
+					if !ok {
+						continue
+					}
+					if _, isMap := lit.Type.(*ast.MapType); !isMap {
+						continue
+					}
+					for _, elt := range lit.Elts {
+						if key, ok := stringLit(elt.(*ast.KeyValueExpr).Key); ok {
+							mapKeys[vs.Names[i].Name] = append(mapKeys[vs.Names[i].Name], key)
+						}
+					}
+				}
+			}
+		}
+		if matched, _ := filepath.Match(routerFiles, name); matched {
+			parsed = append(parsed, f)
+		}
+	}
+	if len(parsed) != len(files) {
+		t.Fatalf("parsed %d router files, want %d", len(parsed), len(files))
+	}
+
+	found := make(map[int]map[string]bool)
+	add := func(i int, s string) {
+		if found[i] == nil {
+			found[i] = make(map[string]bool)
+		}
+		if s != "" {
+			found[i][s] = true
+		}
+	}
+	for _, f := range parsed {
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.BinaryExpr:
+				if n.Op != token.EQL && n.Op != token.NEQ {
+					break
+				}
+				for _, pair := range [][2]ast.Expr{{n.X, n.Y}, {n.Y, n.X}} {
+					s, ok := stringLit(pair[1])
+					if !ok {
+						continue
+					}
+					if i, ok := partsIndex(pair[0]); ok {
+						add(i, s)
+					} else if id, ok := pair[0].(*ast.Ident); ok && id.Name == "path" {
+						for i, seg := range strings.Split(s, "/") {
+							add(i, seg)
+						}
+					}
+				}
+			case *ast.IndexExpr:
+				if i, ok := partsIndex(n.Index); ok {
+					if id, ok := n.X.(*ast.Ident); ok {
+						for _, key := range mapKeys[id.Name] {
+							add(i, key)
+						}
+					}
+				}
+			}
+			return true
+		})
+	}
+
+	segments := make([][]string, len(found))
+	for i := range segments {
+		for s := range found[i] {
+			segments[i] = append(segments[i], s)
+		}
+		sort.Strings(segments[i])
+	}
+	return segments
+}
+
+// partsIndex recognizes parts[N], the split request path of a router.
+func partsIndex(e ast.Expr) (int, bool) {
+	ix, ok := e.(*ast.IndexExpr)
+	if !ok {
+		return 0, false
+	}
+	if id, ok := ix.X.(*ast.Ident); !ok || id.Name != "parts" {
+		return 0, false
+	}
+	lit, ok := ix.Index.(*ast.BasicLit)
+	if !ok || lit.Kind != token.INT {
+		return 0, false
+	}
+	i, err := strconv.Atoi(lit.Value)
+	return i, err == nil
+}
+
+func stringLit(e ast.Expr) (string, bool) {
+	lit, ok := e.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	s, err := strconv.Unquote(lit.Value)
+	return s, err == nil
+}
@@ -0,0 +1,129 @@
+package poll
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// TransitionKind names a date-driven event in a published poll's life.
+type TransitionKind string
+
+const (
+	// TransitionOpened happens when a published poll reaches its start date
+	// and starts accepting votes. The state stays published.
+	TransitionOpened TransitionKind = "opened"
+	// TransitionClosed happens when a published poll reaches its end date;
+	// the poll moves to the closed state.
+	TransitionClosed TransitionKind = "closed"
+)
+
+// Transition is one date-driven event of one poll. Due is the date that
+// triggered it.
+type Transition struct {
+	PollID int64          `json:"poll_id"`
+	Kind   TransitionKind `json:"kind"`
+	Due    time.Time      `json:"due"`
+}
+
+// DueTransitions returns up to limit transitions that are due at now and
+// not yet recorded, oldest first, with a poll's opening before its closing.
+func DueTransitions(db *sql.DB, now time.Time, limit int) ([]Transition, error) {
+	rows, err := db.Query(`
+		SELECT p.id, 'opened' AS kind, COALESCE(p.start_date, p.created_at) AS due
+		FROM polls p
+		WHERE p.state = 'published' AND p.deleted_at IS NULL
+		  AND COALESCE(p.start_date, p.created_at) <= ?
+		  AND NOT EXISTS (
+		      SELECT 1 FROM poll_transitions t WHERE t.poll_id = p.id AND t.kind = 'opened')
+		UNION ALL
+		SELECT p.id, 'closed' AS kind, p.end_date AS due
+		FROM polls p
+		WHERE p.state = 'published' AND p.deleted_at IS NULL
+		  AND p.end_date <= ?
+		ORDER BY due, kind DESC
+		LIMIT ?`, now, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("DueTransitions: %w", err)
+	}
+	defer rows.Close()
+
+	var due []Transition
+	for rows.Next() {
+		var t Transition
+		if err := rows.Scan(&t.PollID, &t.Kind, &t.Due); err != nil {
+			return nil, fmt.Errorf("DueTransitions scan: %w", err)
+		}
+		due = append(due, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("DueTransitions: %w", err)
+	}
+	return due, nil
+}
+
+// RecordTransition applies t at now, closing the poll for TransitionClosed,
+// and records it so it is never applied again. It reports false, without
+// error, when t was already recorded or no longer applies, for instance
+// because another scheduler got there first or the poll was closed by hand.
+func RecordTransition(db *sql.DB, t Transition, now time.Time) (bool, error) {
+	applied := false
+	err := withTx(db, func(tx *sql.Tx) error {
+		_, err := tx.Exec(
+			"INSERT INTO poll_transitions (poll_id, kind, due_at, recorded_at) VALUES (?, ?, ?, ?)",
+			t.PollID, t.Kind, t.Due, now,
+		)
+		if isDuplicateEntry(err) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("RecordTransition: %w", err)
+		}
+
+		if t.Kind == TransitionClosed {
+			result, err := tx.Exec(
+				"UPDATE polls SET state = ?, version = version + 1 WHERE id = ? AND state = ?",
+				StateClosed, t.PollID, StatePublished,
+			)
+			if err != nil {
+				return fmt.Errorf("RecordTransition: %w", err)
+			}
+			if n, _ := result.RowsAffected(); n == 0 {
+				return errTransitionStale
+			}
+			if err := recordRevision(tx, t.PollID, 0, string(StateClosed)); err != nil {
+				return err
+			}
+		}
+		applied = true
+		return nil
+	})
+	if errors.Is(err, errTransitionStale) {
+		return false, nil
+	}
+	return applied, err
+}
+
+// errTransitionStale rolls back a transition whose poll changed state since
+// it was found due.
+var errTransitionStale = errors.New("transition no longer applies")
+
+// SnapshotResults stores the current vote count of every choice of a poll,
+// taken at at. Running it again for the same poll replaces the snapshot.
+func SnapshotResults(db *sql.DB, pollID int64, at time.Time) error {
+	_, err := db.Exec(`
+		INSERT INTO result_snapshots (poll_id, question_id, choice_id, votes, taken_at)
+		SELECT q.poll_id, q.id, c.id, COUNT(v.id), ?
+		FROM questions q
+		JOIN choices c ON c.question_id = q.id
+		LEFT JOIN votes v ON v.choice_id = c.id
+		WHERE q.poll_id = ?
+		GROUP BY q.poll_id, q.id, c.id
+		ON DUPLICATE KEY UPDATE votes = VALUES(votes), taken_at = VALUES(taken_at)`,
+		at, pollID)
+	if err != nil {
+		return fmt.Errorf("SnapshotResults: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+package poll
+
+import (
+	"database/sql"
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+
+	// snippetContext is how many bytes of text are kept on each side of the
+	// first match in a snippet.
+	snippetContext = 60
+)
+
+// Searcher finds polls whose title, description, questions or choices match
+// a keyword query.
+type Searcher interface {
+	// Search returns at most limit polls, best match first.
+	Search(query string, limit int) ([]SearchResult, error)
+}
+
+// SearchResult groups every match that belongs to one poll.
+type SearchResult struct {
+	PollID  int64         `json:"poll_id"`
+	Title   string        `json:"title"`
+	Score   float64       `json:"score"`
+	Matches []SearchMatch `json:"matches"`
+}
+
+// SearchMatch is one field that matched the query. Snippet is HTML-escaped
+// text around the match with matched terms wrapped in <mark>.
+type SearchMatch struct {
+	// Field is "title", "description", "question" or "choice".
+	Field   string `json:"field"`
+	ID      int64  `json:"id"`
+	Snippet string `json:"snippet"`
+	// Score is the field's own score; a title and description matched by
+	// MySQL share the score of their row, counted once in SearchResult.Score.
+	Score float64 `json:"score"`
+}
+
+// MySQLSearcher searches with the FULLTEXT indexes declared in fly.sql.
+type MySQLSearcher struct {
+	db *sql.DB
+}
+
+// NewMySQLSearcher returns a Searcher backed by MySQL.
+func NewMySQLSearcher(db *sql.DB) *MySQLSearcher {
+	return &MySQLSearcher{db: db}
+}
+
+// searchCandidates caps how many rows each table contributes before grouping.
+const searchCandidates = 500
+
+// Search implements Searcher.
+func (s *MySQLSearcher) Search(query string, limit int) ([]SearchResult, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return []SearchResult{}, nil
+	}
+	against := strings.Join(terms, " ")
+	g := newSearchGrouper(terms)
+
+	pollRows, err := s.db.Query(`
+		SELECT id, title, COALESCE(description, ''),
+		       MATCH(title, description) AGAINST (? IN NATURAL LANGUAGE MODE) AS score
+		FROM polls
+		WHERE MATCH(title, description) AGAINST (? IN NATURAL LANGUAGE MODE)
+		  AND deleted_at IS NULL
+		ORDER BY score DESC
+		LIMIT ?`, against, against, searchCandidates)
+	if err != nil {
+		return nil, fmt.Errorf("Search polls: %w", err)
+	}
+	defer pollRows.Close()
+	for pollRows.Next() {
+		var id int64
+		var title, description string
+		var score float64
+		if err := pollRows.Scan(&id, &title, &description, &score); err != nil {
+			return nil, fmt.Errorf("Search polls scan: %w", err)
+		}
+		g.addPoll(id, title, description, score)
+	}
+	if err := pollRows.Err(); err != nil {
+		return nil, fmt.Errorf("Search polls: %w", err)
+	}
+
+	childQueries := []struct {
+		field string
+		query string
+	}{
+		{"question", `
+		SELECT q.id, p.id, p.title, q.question_text,
+		       MATCH(q.question_text) AGAINST (? IN NATURAL LANGUAGE MODE) AS score
+		FROM questions q
+		JOIN polls p ON p.id = q.poll_id
+		WHERE MATCH(q.question_text) AGAINST (? IN NATURAL LANGUAGE MODE)
+		  AND p.deleted_at IS NULL
+		ORDER BY score DESC
+		LIMIT ?`},
+		{"choice", `
+		SELECT c.id, p.id, p.title, c.choice_text,
+		       MATCH(c.choice_text) AGAINST (? IN NATURAL LANGUAGE MODE) AS score
+		FROM choices c
+		JOIN questions q ON q.id = c.question_id
+		JOIN polls p ON p.id = q.poll_id
+		WHERE MATCH(c.choice_text) AGAINST (? IN NATURAL LANGUAGE MODE)
+		  AND p.deleted_at IS NULL
+		ORDER BY score DESC
+		LIMIT ?`},
+	}
+	for _, cq := range childQueries {
+		if err := s.searchChildren(g, cq.field, cq.query, against); err != nil {
+			return nil, err
+		}
+	}
+
+	return g.results(limit), nil
+}
+
+func (s *MySQLSearcher) searchChildren(g *searchGrouper, field, query, against string) error {
+	rows, err := s.db.Query(query, against, against, searchCandidates)
+	if err != nil {
+		return fmt.Errorf("Search %ss: %w", field, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, pollID int64
+		var title, text string
+		var score float64
+		if err := rows.Scan(&id, &pollID, &title, &text, &score); err != nil {
+			return fmt.Errorf("Search %ss scan: %w", field, err)
+		}
+		g.add(pollID, title, field, id, text, score)
+	}
+	return rows.Err()
+}
+
+// searchTerms splits a query into lowercase words, dropping punctuation so
+// that user input never reaches MySQL as full-text operators.
+func searchTerms(query string) []string {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	seen := make(map[string]bool)
+	terms := []string{}
+	for _, w := range words {
+		if !seen[w] {
+			seen[w] = true
+			terms = append(terms, w)
+		}
+	}
+	return terms
+}
+
+// searchGrouper collects matches per poll and ranks the polls.
+type searchGrouper struct {
+	highlighter *regexp.Regexp
+	byPoll      map[int64]*SearchResult
+}
+
+func newSearchGrouper(terms []string) *searchGrouper {
+	// Longest terms first so the alternation prefers whole words over prefixes.
+	sorted := append([]string(nil), terms...)
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	quoted := make([]string, len(sorted))
+	for i, t := range sorted {
+		quoted[i] = regexp.QuoteMeta(t)
+	}
+	return &searchGrouper{
+		highlighter: regexp.MustCompile(`(?i)` + strings.Join(quoted, "|")),
+		byPoll:      make(map[int64]*SearchResult),
+	}
+}
+
+// add records a match and counts its score towards the poll.
+func (g *searchGrouper) add(pollID int64, title, field string, id int64, text string, score float64) {
+	if res := g.attach(pollID, title, field, id, text, score); res != nil {
+		res.Score += score
+	}
+}
+
+// addPoll records the title and description matches of a polls row. MySQL
+// scores the two columns together, so the row's score counts once towards
+// the poll however many of them match.
+func (g *searchGrouper) addPoll(id int64, title, description string, score float64) {
+	res := g.attach(id, title, "title", id, title, score)
+	if r := g.attach(id, title, "description", id, description, score); r != nil {
+		res = r
+	}
+	if res != nil {
+		res.Score += score
+	}
+}
+
+// attach records a match without scoring it, unless none of the terms
+// actually appear in text, which happens for the poll field that did not
+// cause a row to match. It returns the poll's result if it recorded one.
+func (g *searchGrouper) attach(pollID int64, title, field string, id int64, text string, score float64) *SearchResult {
+	snippet, ok := g.snippet(text)
+	if !ok {
+		return nil
+	}
+	res, exists := g.byPoll[pollID]
+	if !exists {
+		res = &SearchResult{PollID: pollID, Title: title}
+		g.byPoll[pollID] = res
+	}
+	res.Matches = append(res.Matches, SearchMatch{Field: field, ID: id, Snippet: snippet, Score: score})
+	return res
+}
+
+func (g *searchGrouper) results(limit int) []SearchResult {
+	results := make([]SearchResult, 0, len(g.byPoll))
+	for _, res := range g.byPoll {
+		sort.SliceStable(res.Matches, func(i, j int) bool {
+			return res.Matches[i].Score > res.Matches[j].Score
+		})
+		results = append(results, *res)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].PollID > results[j].PollID
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// snippet cuts text down to the area around the first match and highlights
+// every match inside it.
+func (g *searchGrouper) snippet(text string) (string, bool) {
+	first := g.highlighter.FindStringIndex(text)
+	if first == nil {
+		return "", false
+	}
+
+	start, end := first[0]-snippetContext, first[1]+snippetContext
+	prefix, suffix := "…", "…"
+	if start <= 0 {
+		start, prefix = 0, ""
+	}
+	if end >= len(text) {
+		end, suffix = len(text), ""
+	}
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start--
+	}
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end++
+	}
+	window := text[start:end]
+
+	var b strings.Builder
+	b.WriteString(prefix)
+	last := 0
+	for _, m := range g.highlighter.FindAllStringIndex(window, -1) {
+		b.WriteString(html.EscapeString(window[last:m[0]]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(window[m[0]:m[1]]))
+		b.WriteString("</mark>")
+		last = m[1]
+	}
+	b.WriteString(html.EscapeString(window[last:]))
+	b.WriteString(suffix)
+	return b.String(), true
+}
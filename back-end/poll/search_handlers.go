@@ -0,0 +1,55 @@
+package poll
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"simple-poll/apierror"
+)
+
+// SearchRouter is the main entry point for /api/search.
+// Example usage:
+//
+//	mux.Handle("/api/search", SearchRouter(NewMySQLSearcher(db)))
+func SearchRouter(s Searcher) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			routeNotFound(w, r)
+			return
+		}
+		searchHandler(s, w, r)
+	})
+}
+
+// searchHandler handles GET /api/search?q=lunch&limit=10
+func searchHandler(s Searcher, w http.ResponseWriter, r *http.Request) {
+	queryValues := r.URL.Query()
+	q := strings.TrimSpace(queryValues.Get("q"))
+	if q == "" {
+		writeError(w, apierror.BadRequest("q is required"))
+		return
+	}
+
+	limit := defaultSearchLimit
+	if v := queryValues.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, apierror.BadRequest("Invalid limit"))
+			return
+		}
+		limit = n
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+
+	results, err := s.Search(q, limit)
+	if err != nil {
+		log.Printf("Error searching polls: %v", err)
+		writeError(w, storageError(err, "Failed to search polls"))
+		return
+	}
+	writeJSON(w, r, results)
+}
@@ -0,0 +1,71 @@
+package poll
+
+import (
+	"strings"
+	"sync"
+)
+
+// MemorySearcher is an in-process Searcher over polls added with Index. It
+// scores a field by how many query terms it contains, which is enough to back
+// tests and small deployments without MySQL.
+type MemorySearcher struct {
+	mu    sync.RWMutex
+	polls map[int64]Poll
+}
+
+// NewMemorySearcher returns an empty MemorySearcher.
+func NewMemorySearcher() *MemorySearcher {
+	return &MemorySearcher{polls: make(map[int64]Poll)}
+}
+
+// Index adds or replaces a poll, including its questions and choices.
+func (s *MemorySearcher) Index(p Poll) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.polls[p.ID] = p
+}
+
+// Remove drops a poll from the index.
+func (s *MemorySearcher) Remove(pollID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.polls, pollID)
+}
+
+// Search implements Searcher.
+func (s *MemorySearcher) Search(query string, limit int) ([]SearchResult, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return []SearchResult{}, nil
+	}
+	g := newSearchGrouper(terms)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, p := range s.polls {
+		if p.DeletedAt != nil {
+			continue
+		}
+		g.add(p.ID, p.Title, "title", p.ID, p.Title, termScore(p.Title, terms))
+		g.add(p.ID, p.Title, "description", p.ID, p.Description, termScore(p.Description, terms))
+		for _, q := range p.Questions {
+			g.add(p.ID, p.Title, "question", q.ID, q.Text, termScore(q.Text, terms))
+			for _, c := range q.Choices {
+				g.add(p.ID, p.Title, "choice", c.ID, c.Text, termScore(c.Text, terms))
+			}
+		}
+	}
+	return g.results(limit), nil
+}
+
+// termScore counts the distinct query terms that occur in text.
+func termScore(text string, terms []string) float64 {
+	lower := strings.ToLower(text)
+	score := 0.0
+	for _, t := range terms {
+		if strings.Contains(lower, t) {
+			score++
+		}
+	}
+	return score
+}
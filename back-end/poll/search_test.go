@@ -0,0 +1,167 @@
+package poll
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestSearcher() *MemorySearcher {
+	deleted := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	s := NewMemorySearcher()
+	s.Index(Poll{
+		ID:          1,
+		Title:       "Team lunch",
+		Description: "Where should we go on Friday?",
+		Questions: []Question{{
+			ID:   10,
+			Text: "Pizza or tacos?",
+			Choices: []Choice{
+				{ID: 100, Text: "Pizza"},
+				{ID: 101, Text: "Tacos & burritos"},
+			},
+		}},
+	})
+	s.Index(Poll{
+		ID:        2,
+		Title:     "Friday lunch",
+		Questions: []Question{{ID: 20, Text: "Is <lunch> at 12 OK?"}},
+	})
+	s.Index(Poll{ID: 3, Title: "Offsite planning"})
+	s.Index(Poll{ID: 4, Title: "Lunch and learn", DeletedAt: &deleted})
+	return s
+}
+
+func search(t *testing.T, s Searcher, query string) []SearchResult {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	SearchRouter(s).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/search?"+query, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /api/search?%s: status %d, body %s", query, rec.Code, rec.Body)
+	}
+	var results []SearchResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &results); err != nil {
+		t.Fatalf("GET /api/search?%s: %v", query, err)
+	}
+	return results
+}
+
+func TestSearchRanksPollsByTotalScore(t *testing.T) {
+	results := search(t, newTestSearcher(), "q=lunch+friday+tacos")
+
+	var ids []int64
+	for _, r := range results {
+		ids = append(ids, r.PollID)
+	}
+	// Poll 1 matches in four fields, poll 2 in two, poll 3 not at all and
+	// poll 4 is in the trash.
+	if len(ids) != 2 || ids[0] != 1 || ids[1] != 2 {
+		t.Fatalf("poll IDs = %v, want [1 2]", ids)
+	}
+	if results[0].Score != 4 || results[1].Score != 3 {
+		t.Errorf("scores = %v, %v, want 4, 3", results[0].Score, results[1].Score)
+	}
+}
+
+func TestSearchGroupsMatchesByPoll(t *testing.T) {
+	results := search(t, newTestSearcher(), "q=lunch+friday+tacos")
+	first := results[0]
+
+	if first.Title != "Team lunch" {
+		t.Errorf("title = %q, want %q", first.Title, "Team lunch")
+	}
+	type match struct {
+		field string
+		id    int64
+	}
+	want := []match{{"title", 1}, {"description", 1}, {"question", 10}, {"choice", 101}}
+	if len(first.Matches) != len(want) {
+		t.Fatalf("matches = %+v, want %v", first.Matches, want)
+	}
+	for i, m := range first.Matches {
+		if (match{m.Field, m.ID}) != want[i] {
+			t.Errorf("match %d = %s %d, want %s %d", i, m.Field, m.ID, want[i].field, want[i].id)
+		}
+	}
+
+	// The best field comes first.
+	second := results[1]
+	if len(second.Matches) != 2 || second.Matches[0].Field != "title" || second.Matches[0].Score != 2 {
+		t.Errorf("poll 2 matches = %+v, want the title first with score 2", second.Matches)
+	}
+}
+
+func TestSearchHighlightsSnippets(t *testing.T) {
+	results := search(t, newTestSearcher(), "q=lunch+friday+tacos")
+
+	snippets := make(map[string]string)
+	for _, r := range results {
+		for _, m := range r.Matches {
+			snippets[m.Field+" "+r.Title] = m.Snippet
+		}
+	}
+	for key, want := range map[string]string{
+		"title Friday lunch":     "<mark>Friday</mark> <mark>lunch</mark>",
+		"description Team lunch": "Where should we go on <mark>Friday</mark>?",
+		"choice Team lunch":      "<mark>Tacos</mark> &amp; burritos",
+		"question Friday lunch":  "Is &lt;<mark>lunch</mark>&gt; at 12 OK?",
+	} {
+		if got := snippets[key]; got != want {
+			t.Errorf("%s snippet = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestSearchTrimsLongSnippets(t *testing.T) {
+	s := NewMemorySearcher()
+	s.Index(Poll{
+		ID:          1,
+		Title:       "Retro",
+		Description: strings.Repeat("a", 100) + " budget " + strings.Repeat("b", 100),
+	})
+
+	results := search(t, s, "q=budget")
+	if len(results) != 1 || len(results[0].Matches) != 1 {
+		t.Fatalf("results = %+v, want one match", results)
+	}
+	want := "…" + strings.Repeat("a", snippetContext-1) + " <mark>budget</mark> " + strings.Repeat("b", snippetContext-1) + "…"
+	if got := results[0].Matches[0].Snippet; got != want {
+		t.Errorf("snippet = %q, want %q", got, want)
+	}
+}
+
+func TestSearchLimit(t *testing.T) {
+	results := search(t, newTestSearcher(), "q=lunch&limit=1")
+	if len(results) != 1 || results[0].PollID != 2 {
+		t.Errorf("results = %+v, want poll 2 only", results)
+	}
+}
+
+func TestSearchRejectsBadQueries(t *testing.T) {
+	for _, query := range []string{"", "q=+", "q=lunch&limit=0", "q=lunch&limit=x"} {
+		rec := httptest.NewRecorder()
+		SearchRouter(newTestSearcher()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/search?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("GET /api/search?%s: status %d, want 400", query, rec.Code)
+		}
+	}
+}
+
+// MySQL scores a poll's title and description together, so a polls row that
+// matches in both counts once, like a question or a choice.
+func TestMySQLSearchScoresThePollRowOnce(t *testing.T) {
+	// Every text column of the fake database reads "lunch", with score 1.
+	results := search(t, NewMySQLSearcher(openFakeDB(&fakeDB{})), "q=lunch")
+	if len(results) != 1 {
+		t.Fatalf("results = %+v, want poll 1 only", results)
+	}
+	if got := len(results[0].Matches); got != 4 {
+		t.Errorf("%d matches, want title, description, question and choice", got)
+	}
+	if got := results[0].Score; got != 3 {
+		t.Errorf("score = %v, want 3: the polls row, the question and the choice", got)
+	}
+}
@@ -0,0 +1,128 @@
+package poll
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Occurrence is one run of a recurring poll that is due to be created.
+type Occurrence struct {
+	SeriesID int64     `json:"series_id"`
+	At       time.Time `json:"at"`
+}
+
+// DueOccurrences returns, for every recurring poll that is neither trashed
+// nor archived, the latest occurrence of its rule at or before now that has
+// not been created yet. Older missed occurrences are skipped: after downtime
+// a weekly poll resumes with this week's run rather than replaying the past.
+func DueOccurrences(db *sql.DB, now time.Time) ([]Occurrence, error) {
+	rows, err := db.Query(`
+		SELECT id, recurrence, COALESCE(start_date, created_at), last_occurrence_at
+		FROM polls
+		WHERE recurrence IS NOT NULL AND deleted_at IS NULL AND state <> ?`, StateArchived)
+	if err != nil {
+		return nil, fmt.Errorf("DueOccurrences: %w", err)
+	}
+	defer rows.Close()
+
+	var due []Occurrence
+	for rows.Next() {
+		var id int64
+		var rule string
+		var anchor time.Time
+		var last *time.Time
+		if err := rows.Scan(&id, &rule, &anchor, &last); err != nil {
+			return nil, fmt.Errorf("DueOccurrences scan: %w", err)
+		}
+		r, err := ParseRecurrence(rule)
+		if err != nil {
+			log.Printf("Skipping poll %d with invalid recurrence %q: %v", id, rule, err)
+			continue
+		}
+		if at, ok := r.latestDue(anchor, last, now); ok {
+			due = append(due, Occurrence{SeriesID: id, At: at})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("DueOccurrences: %w", err)
+	}
+	return due, nil
+}
+
+// CreateOccurrence copies a recurring poll, with its questions and choices,
+// into a new poll starting at o.At and linked to the series. The copy lasts
+// as long as the template does, from its start to its end date, and is
+// published straight away when it has a complete ballot; otherwise it stays a
+// draft. It returns nil, without error, when the occurrence was already
+// created, so concurrent schedulers never create it twice.
+func CreateOccurrence(db *sql.DB, o Occurrence) (*Poll, error) {
+	var created *Poll
+	err := withTx(db, func(tx *sql.Tx) error {
+		var template Poll
+		var last *time.Time
+		err := tx.QueryRow(`
+			SELECT id, title, description, created_by, start_date, end_date, last_occurrence_at
+			FROM polls
+			WHERE id = ? AND recurrence IS NOT NULL AND deleted_at IS NULL
+			FOR UPDATE`, o.SeriesID,
+		).Scan(&template.ID, &template.Title, &template.Description, &template.CreatedBy,
+			&template.StartDate, &template.EndDate, &last)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("CreateOccurrence: %w", err)
+		}
+		if last != nil && !last.Before(o.At) {
+			return nil
+		}
+
+		templates := []Poll{template}
+		if err := loadQuestions(tx, templates, true); err != nil {
+			return err
+		}
+
+		start := o.At
+		p := &Poll{
+			Title:       template.Title,
+			Description: template.Description,
+			CreatedBy:   template.CreatedBy,
+			StartDate:   &start,
+			SeriesID:    &template.ID,
+			Questions:   copyQuestions(templates[0].Questions),
+		}
+		if template.StartDate != nil && template.EndDate != nil {
+			end := start.Add(template.EndDate.Sub(*template.StartDate))
+			p.EndDate = &end
+		}
+		if err := createPollTree(tx, p); err != nil {
+			return err
+		}
+
+		if checkPublishable(tx, p.ID) == nil {
+			_, err := tx.Exec("UPDATE polls SET state = ?, version = version + 1 WHERE id = ?", StatePublished, p.ID)
+			if err != nil {
+				return fmt.Errorf("CreateOccurrence: %w", err)
+			}
+			p.State = StatePublished
+			p.Version++
+		}
+
+		if err := recordRevision(tx, p.ID, 0, RevisionCreated); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("UPDATE polls SET last_occurrence_at = ? WHERE id = ?", o.At, template.ID)
+		if err != nil {
+			return fmt.Errorf("CreateOccurrence: %w", err)
+		}
+		created = p
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
@@ -0,0 +1,133 @@
+package poll
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Every poll has a slug, a short name that can stand in for its ID in URLs
+// (/api/polls/k7rm2xq9bd). Generated slugs are random, so unlike IDs they
+// cannot be guessed or enumerated; vanity slugs are chosen by the poll's
+// author.
+const (
+	// slugAlphabet leaves out characters easily confused when read aloud
+	// or retyped: 0/o, 1/l.
+	slugAlphabet     = "23456789abcdefghijkmnpqrstuvwxyz"
+	generatedSlugLen = 10
+	// maxSlugAttempts bounds retries after a generated slug collides.
+	maxSlugAttempts = 5
+	minSlugLen      = 3
+	maxSlugLen      = 64 // polls.slug is VARCHAR(64)
+)
+
+var vanitySlug = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// reservedSlugs are path segments PollRouter serves in place of a poll.
+var reservedSlugs = map[string]bool{
+	"trash": true,
+}
+
+// ErrSlugTaken is returned when a vanity slug already belongs to another
+// poll; handlers report it as a conflict.
+var ErrSlugTaken = errors.New("slug taken")
+
+// newSlug returns a random slug of generatedSlugLen characters. It never
+// returns one made only of digits, which PollRouter would take for an ID.
+func newSlug() (string, error) {
+	b := make([]byte, generatedSlugLen)
+	max := big.NewInt(int64(len(slugAlphabet)))
+	for {
+		for i := range b {
+			n, err := rand.Int(rand.Reader, max)
+			if err != nil {
+				return "", fmt.Errorf("newSlug: %w", err)
+			}
+			b[i] = slugAlphabet[n.Int64()]
+		}
+		if !isNumericID(string(b)) {
+			return string(b), nil
+		}
+	}
+}
+
+// checkVanitySlug explains why s cannot be used as a vanity slug, or
+// returns nil. Slugs made only of digits would be mistaken for IDs.
+func checkVanitySlug(s string) error {
+	switch {
+	case len(s) < minSlugLen || len(s) > maxSlugLen:
+		return fmt.Errorf("must be %d to %d characters long", minSlugLen, maxSlugLen)
+	case !vanitySlug.MatchString(s):
+		return fmt.Errorf("may only contain lower case letters, digits and single hyphens between them")
+	case isNumericID(s):
+		return fmt.Errorf("must contain a letter or hyphen, so it cannot be mistaken for an ID")
+	case reservedSlugs[s]:
+		return fmt.Errorf("%q is reserved", s)
+	}
+	return nil
+}
+
+func isNumericID(s string) bool {
+	_, err := strconv.ParseInt(s, 10, 64)
+	return err == nil
+}
+
+func isDuplicateEntry(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlDuplicateEntry
+}
+
+// slugTaken builds the error for a vanity slug in use, suggesting the first
+// free variant with a numeric suffix, e.g. "team-retro-2".
+func slugTaken(db dbtx, slug string) error {
+	rows, err := db.Query("SELECT slug FROM polls WHERE slug LIKE ?", slug+"-%")
+	if err != nil {
+		return fmt.Errorf("slugTaken: %w", err)
+	}
+	defer rows.Close()
+
+	used := make(map[string]bool)
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return fmt.Errorf("slugTaken scan: %w", err)
+		}
+		used[s] = true
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("slugTaken: %w", err)
+	}
+
+	for n := 2; ; n++ {
+		suffix := "-" + strconv.Itoa(n)
+		candidate := slug
+		if len(candidate)+len(suffix) > maxSlugLen {
+			candidate = strings.TrimRight(candidate[:maxSlugLen-len(suffix)], "-")
+		}
+		candidate += suffix
+		if !used[candidate] {
+			return fmt.Errorf("%w: %q is already in use; %q is available", ErrSlugTaken, slug, candidate)
+		}
+	}
+}
+
+// PollIDBySlug returns the ID of the poll with the given slug, including
+// polls in the trash, so it can be restored by slug too.
+func PollIDBySlug(db *sql.DB, slug string) (int64, error) {
+	var id int64
+	err := db.QueryRow("SELECT id FROM polls WHERE slug = ?", slug).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("poll %w", ErrNotFound)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("PollIDBySlug: %w", err)
+	}
+	return id, nil
+}
@@ -0,0 +1,190 @@
+package poll
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// tagName matches a normalized tag: lower case words of letters and digits,
+// joined by single spaces or hyphens, e.g. "q3 planning" or "all-hands".
+var tagName = regexp.MustCompile(`^[\p{Ll}\p{Lo}\p{N}]+([ -][\p{Ll}\p{Lo}\p{N}]+)*$`)
+
+// TagCount is one entry of the tag cloud.
+type TagCount struct {
+	Name string `json:"name"`
+	// Count is the number of polls outside the trash carrying the tag.
+	Count int `json:"count"`
+}
+
+// normalizeTag lower-cases a tag and collapses runs of white space, so
+// "Q3  Planning" and "q3 planning" are the same tag.
+func normalizeTag(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// TagPoll adds a tag to a poll, creating the tag on first use. Adding a tag
+// the poll already has changes nothing; otherwise the poll's version is
+// bumped and a revision recorded. The poll's version must still equal
+// version (0 skips the check). It returns ErrNotFound when the poll is
+// missing or in the trash, ErrVersionConflict when it changed, and
+// ErrInvalidState when it is archived.
+func TagPoll(db *sql.DB, pollID int64, tag string, version, author int64) error {
+	return withTx(db, func(tx *sql.Tx) error {
+		if err := lockTaggablePoll(tx, pollID, version); err != nil {
+			return err
+		}
+		// LAST_INSERT_ID(id) hands back the existing tag's ID on a duplicate.
+		result, err := tx.Exec(`
+			INSERT INTO tags (name) VALUES (?)
+			ON DUPLICATE KEY UPDATE id = LAST_INSERT_ID(id)`, tag)
+		if err != nil {
+			return fmt.Errorf("TagPoll: %w", err)
+		}
+		tagID, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("TagPoll: %w", err)
+		}
+
+		result, err = tx.Exec("INSERT IGNORE INTO poll_tags (poll_id, tag_id) VALUES (?, ?)", pollID, tagID)
+		if err != nil {
+			return fmt.Errorf("TagPoll: %w", err)
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("TagPoll: %w", err)
+		}
+		if n == 0 {
+			return nil // already tagged
+		}
+		return bumpPollVersion(tx, pollID, author, RevisionTagged)
+	})
+}
+
+// UntagPoll removes a tag from a poll, bumping the poll's version and
+// recording a revision. The poll's version must still equal version (0 skips
+// the check). It returns ErrNotFound when the poll is missing or in the
+// trash, or does not carry the tag, ErrVersionConflict when the poll changed,
+// and ErrInvalidState when it is archived.
+func UntagPoll(db *sql.DB, pollID int64, tag string, version, author int64) error {
+	return withTx(db, func(tx *sql.Tx) error {
+		if err := lockTaggablePoll(tx, pollID, version); err != nil {
+			return err
+		}
+		result, err := tx.Exec(`
+			DELETE pt FROM poll_tags pt
+			JOIN tags t ON t.id = pt.tag_id
+			WHERE pt.poll_id = ? AND t.name = ?`, pollID, tag)
+		if err != nil {
+			return fmt.Errorf("UntagPoll: %w", err)
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("UntagPoll: %w", err)
+		}
+		if n == 0 {
+			return fmt.Errorf("tag %w", ErrNotFound)
+		}
+		return bumpPollVersion(tx, pollID, author, RevisionUntagged)
+	})
+}
+
+// lockTaggablePoll locks a poll outside the trash for the rest of tx, and
+// refuses archived polls, which are read-only, and polls whose version no
+// longer equals version (0 skips the check).
+func lockTaggablePoll(tx dbtx, pollID, version int64) error {
+	state, current, err := lockPollState(tx, pollID)
+	if err != nil {
+		return err
+	}
+	if version != 0 && version != current {
+		return ErrVersionConflict
+	}
+	if state == StateArchived {
+		return fmt.Errorf("%w: archived polls cannot be tagged or untagged", ErrInvalidState)
+	}
+	return nil
+}
+
+// bumpPollVersion records a change that touched no column of the poll row
+// itself.
+func bumpPollVersion(tx dbtx, pollID, author int64, action string) error {
+	if _, err := tx.Exec("UPDATE polls SET version = version + 1 WHERE id = ?", pollID); err != nil {
+		return fmt.Errorf("bumpPollVersion: %w", err)
+	}
+	return recordRevision(tx, pollID, author, action)
+}
+
+// loadTags fills in the tags of every poll in polls, in alphabetical order,
+// with a single query.
+func loadTags(db dbtx, polls []Poll) error {
+	if len(polls) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(polls))
+	index := make(map[int64]int, len(polls)) // poll ID => index in polls
+	for i := range polls {
+		ids[i] = polls[i].ID
+		index[polls[i].ID] = i
+		polls[i].Tags = []string{}
+	}
+
+	in, args := inClause(ids)
+	rows, err := db.Query(`
+		SELECT pt.poll_id, t.name
+		FROM poll_tags pt
+		JOIN tags t ON t.id = pt.tag_id
+		WHERE pt.poll_id IN (`+in+`)
+		ORDER BY pt.poll_id, t.name`, args...)
+	if err != nil {
+		return fmt.Errorf("loadTags: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var pollID int64
+		var name string
+		if err := rows.Scan(&pollID, &name); err != nil {
+			return fmt.Errorf("loadTags scan: %w", err)
+		}
+		i := index[pollID]
+		polls[i].Tags = append(polls[i].Tags, name)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("loadTags: %w", err)
+	}
+	return nil
+}
+
+// TagCloud returns the limit most used tags with the number of polls
+// outside the trash carrying each, most used first and alphabetically among
+// equals. Tags left on trashed polls only are not listed.
+func TagCloud(db *sql.DB, limit int) ([]TagCount, error) {
+	rows, err := db.Query(`
+		SELECT t.name, COUNT(*) AS uses
+		FROM tags t
+		JOIN poll_tags pt ON pt.tag_id = t.id
+		JOIN polls p ON p.id = pt.poll_id
+		WHERE p.deleted_at IS NULL
+		GROUP BY t.id, t.name
+		ORDER BY uses DESC, t.name
+		LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("TagCloud: %w", err)
+	}
+	defer rows.Close()
+
+	cloud := []TagCount{}
+	for rows.Next() {
+		var tc TagCount
+		if err := rows.Scan(&tc.Name, &tc.Count); err != nil {
+			return nil, fmt.Errorf("TagCloud scan: %w", err)
+		}
+		cloud = append(cloud, tc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("TagCloud: %w", err)
+	}
+	return cloud, nil
+}
@@ -0,0 +1,104 @@
+package poll
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"simple-poll/apierror"
+)
+
+// defaultTagCloudSize is how many tags GET /api/tags/ returns by default.
+const defaultTagCloudSize = 100
+
+// TagRouter is the main entry point for /api/tags routes.
+// Example usage:
+//
+//	mux.Handle("/api/tags/", http.StripPrefix("/api/tags", TagRouter(db)))
+func TagRouter(db *sql.DB) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/")
+
+		switch {
+		case r.Method == http.MethodGet && path == "":
+			// GET /api/tags/ => tag cloud
+			tagCloudHandler(db, w, r)
+		default:
+			routeNotFound(w, r)
+		}
+	})
+
+	return mux
+}
+
+// tagCloudHandler lists the most used tags with their poll counts, e.g.
+// /api/tags/?limit=20.
+func tagCloudHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	limit := defaultTagCloudSize
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, apierror.BadRequest("Invalid limit"))
+			return
+		}
+		limit = min(n, maxPageSize)
+	}
+
+	cloud, err := TagCloud(db, limit)
+	if err != nil {
+		log.Printf("Error listing tags: %v", err)
+		writeError(w, storageError(err, "Failed to list tags"))
+		return
+	}
+	writeJSON(w, r, Page[TagCount]{Items: cloud})
+}
+
+// tagPollHandler adds a tag to a poll (PUT /api/polls/123/tags/{tag}) or,
+// with add false, removes it (DELETE) and returns the poll. Both change the
+// poll's version, so If-Match must carry the poll's ETag.
+func tagPollHandler(db *sql.DB, w http.ResponseWriter, r *http.Request, idParam, tag string, add bool) {
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		writeError(w, apierror.BadRequest("Invalid poll ID"))
+		return
+	}
+	if verr := validateTag(&tag); verr != nil {
+		writeError(w, verr)
+		return
+	}
+
+	version, verr := ifMatchVersion(r)
+	if verr != nil {
+		writeError(w, verr)
+		return
+	}
+
+	author, aerr := optionalCallerID(r)
+	if aerr != nil {
+		writeError(w, aerr)
+		return
+	}
+	if add {
+		err = TagPoll(db, id, tag, version, author)
+	} else {
+		err = UntagPoll(db, id, tag, version, author)
+	}
+	if err != nil {
+		log.Printf("Error tagging poll: %v", err)
+		writeError(w, storageError(err, "Failed to update poll tags"))
+		return
+	}
+
+	updated, err := GetPoll(db, id)
+	if err != nil || updated == nil {
+		log.Printf("Error getting updated poll: %v", err)
+		writeError(w, storageError(err, "Failed to get poll"))
+		return
+	}
+	w.Header().Set("ETag", etag(r, updated.Version))
+	writeJSON(w, r, updated)
+}
@@ -0,0 +1,41 @@
+package poll
+
+import (
+	"database/sql/driver"
+	"errors"
+	"testing"
+)
+
+func TestTagsOfArchivedPollsAreReadOnly(t *testing.T) {
+	for _, state := range []PollState{StateDraft, StatePublished, StateClosed, StateArchived} {
+		var want error
+		if state == StateArchived {
+			want = ErrInvalidState
+		}
+		for name, change := range map[string]func(*fakeDB) error{
+			"TagPoll":   func(f *fakeDB) error { return TagPoll(openFakeDB(f), 1, "lunch", 0, 1) },
+			"UntagPoll": func(f *fakeDB) error { return UntagPoll(openFakeDB(f), 1, "lunch", 0, 1) },
+		} {
+			f := &fakeDB{columns: map[string]driver.Value{"state": string(state)}}
+			if err := change(f); !errors.Is(err, want) {
+				t.Errorf("%s on a %s poll: err = %v, want %v", name, state, err, want)
+			}
+			if want != nil && f.count() != 1 {
+				t.Errorf("%s on a %s poll ran %d statements, want only the poll lock", name, state, f.count())
+			}
+		}
+	}
+}
+
+func TestTagsCheckThePollVersion(t *testing.T) {
+	// The fake poll is at version 1.
+	if err := TagPoll(openFakeDB(&fakeDB{}), 1, "lunch", 2, 1); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("TagPoll with a stale version: err = %v, want ErrVersionConflict", err)
+	}
+	if err := UntagPoll(openFakeDB(&fakeDB{}), 1, "lunch", 2, 1); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("UntagPoll with a stale version: err = %v, want ErrVersionConflict", err)
+	}
+	if err := TagPoll(openFakeDB(&fakeDB{}), 1, "lunch", 1, 1); err != nil {
+		t.Errorf("TagPoll with the current version: %v", err)
+	}
+}
@@ -0,0 +1,365 @@
+package poll
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Template is a reusable blueprint a poll can be created from. Built-in
+// templates ship with the server and have a name such as "nps" as their ID;
+// organizations publish their own, which have numeric IDs and are visible to
+// the organization's members only.
+//
+// Title, PollDescription and the text of every question and choice may refer
+// to parameters as {{name}}. A choice whose whole text is {{name}} of a list
+// parameter stands for one choice per item of the list, which is how a
+// template leaves its choice set open.
+type Template struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// OrganizationID is nil for built-in templates.
+	OrganizationID *int64          `json:"organization_id"`
+	CreatedBy      *int64          `json:"created_by,omitempty"`
+	Parameters     []TemplateParam `json:"parameters"`
+	Title          string          `json:"title"`
+	// PollDescription becomes the description of polls made from the template.
+	PollDescription string `json:"poll_description"`
+	// DurationDays is how long polls made from the template run by default.
+	DurationDays int        `json:"duration_days"`
+	Questions    []Question `json:"questions"`
+	CreatedAt    *time.Time `json:"created_at,omitempty"`
+}
+
+// Parameter types.
+const (
+	ParamText = "text"
+	ParamList = "list"
+)
+
+// TemplateParam is a value asked for when a poll is created from a template.
+type TemplateParam struct {
+	Name  string `json:"name"`
+	Label string `json:"label"`
+	// Type is "text" (the default), filled in with a string, or "list",
+	// filled in with an array of strings.
+	Type     string          `json:"type,omitempty"`
+	Required bool            `json:"required"`
+	Default  json.RawMessage `json:"default,omitempty"`
+}
+
+// InstantiateRequest is the body of POST /templates/{id}/polls.
+type InstantiateRequest struct {
+	Params    map[string]json.RawMessage `json:"params"`
+	StartDate *time.Time                 `json:"start_date"`
+	EndDate   *time.Time                 `json:"end_date"`
+}
+
+// templateBody is the part of a template stored as JSON in
+// poll_templates.definition.
+type templateBody struct {
+	Parameters      []TemplateParam `json:"parameters"`
+	Title           string          `json:"title"`
+	PollDescription string          `json:"poll_description"`
+	DurationDays    int             `json:"duration_days"`
+	Questions       []Question      `json:"questions"`
+}
+
+var (
+	paramName   = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+	placeholder = regexp.MustCompile(`\{\{\s*([a-z_][a-z0-9_]*)\s*\}\}`)
+)
+
+// builtinTemplates is the catalog every user can create polls from.
+var builtinTemplates = []Template{
+	{
+		ID:          "nps",
+		Name:        "NPS survey",
+		Description: "Net Promoter Score: how likely people are to recommend something, from 0 to 10.",
+		Parameters: []TemplateParam{
+			{Name: "subject", Label: "Product, service or team being rated", Required: true},
+		},
+		Title:           "How likely are you to recommend {{subject}}?",
+		PollDescription: "Scores of 9 and 10 are promoters, 0 to 6 detractors.",
+		DurationDays:    14,
+		Questions: []Question{{
+			Text:    "On a scale from 0 to 10, how likely are you to recommend {{subject}} to a friend or colleague?",
+			Choices: textChoices("0", "1", "2", "3", "4", "5", "6", "7", "8", "9", "10"),
+		}},
+	},
+	{
+		ID:          "meeting",
+		Name:        "Meeting scheduling",
+		Description: "Find the time that suits most people for a meeting.",
+		Parameters: []TemplateParam{
+			{Name: "topic", Label: "What the meeting is about", Required: true},
+			{Name: "slots", Label: "Proposed times", Type: ParamList, Required: true},
+		},
+		Title:        "When should we meet about {{topic}}?",
+		DurationDays: 3,
+		Questions: []Question{{
+			Text:    "Which time works best for you?",
+			Choices: textChoices("{{slots}}"),
+		}},
+	},
+	{
+		ID:          "motion",
+		Name:        "Yes/no/abstain motion",
+		Description: "Put a motion to a vote.",
+		Parameters: []TemplateParam{
+			{Name: "motion", Label: "Text of the motion", Required: true},
+			{Name: "body", Label: "Who is voting", Default: json.RawMessage(`"the members"`)},
+		},
+		Title:           "Motion: {{motion}}",
+		PollDescription: "A vote of {{body}} on the motion below.",
+		DurationDays:    7,
+		Questions: []Question{{
+			Text:    "Do you support the motion \"{{motion}}\"?",
+			Choices: textChoices("Yes", "No", "Abstain"),
+		}},
+	},
+}
+
+func textChoices(texts ...string) []Choice {
+	choices := make([]Choice, len(texts))
+	for i, text := range texts {
+		choices[i].Text = text
+	}
+	return choices
+}
+
+func builtinTemplate(id string) *Template {
+	for i := range builtinTemplates {
+		if builtinTemplates[i].ID == id {
+			t := builtinTemplates[i]
+			return &t
+		}
+	}
+	return nil
+}
+
+// ListTemplates returns the built-in templates followed by those of the
+// organizations userID belongs to, newest first. A userID of 0 sees the
+// built-in templates only. With orgID set, only that organization's
+// templates are listed.
+func ListTemplates(db *sql.DB, userID int64, orgID *int64) ([]Template, error) {
+	templates := []Template{}
+	if orgID == nil {
+		templates = append(templates, builtinTemplates...)
+	}
+	if userID == 0 {
+		return templates, nil
+	}
+
+	var lq listQuery
+	lq.add("t.organization_id IN (SELECT organization_id FROM organization_members WHERE user_id = ?)", userID)
+	if orgID != nil {
+		lq.add("t.organization_id = ?", *orgID)
+	}
+	rows, err := db.Query(`
+		SELECT t.id, t.name, t.description, t.organization_id, t.created_by, t.definition, t.created_at
+		FROM poll_templates t`+lq.whereClause()+`
+		ORDER BY t.created_at DESC, t.id DESC`, lq.args...)
+	if err != nil {
+		return nil, fmt.Errorf("ListTemplates: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		t, err := scanTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, *t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ListTemplates: %w", err)
+	}
+	return templates, nil
+}
+
+// GetTemplate returns a built-in template, or a template of an organization
+// userID belongs to. Other templates are reported as ErrNotFound.
+func GetTemplate(db *sql.DB, id string, userID int64) (*Template, error) {
+	if t := builtinTemplate(id); t != nil {
+		return t, nil
+	}
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || userID == 0 {
+		return nil, fmt.Errorf("template %w", ErrNotFound)
+	}
+	row := db.QueryRow(`
+		SELECT t.id, t.name, t.description, t.organization_id, t.created_by, t.definition, t.created_at
+		FROM poll_templates t
+		JOIN organization_members m ON m.organization_id = t.organization_id AND m.user_id = ?
+		WHERE t.id = ?`, userID, n)
+	t, err := scanTemplate(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("template %w", ErrNotFound)
+	}
+	return t, err
+}
+
+func scanTemplate(row interface{ Scan(...interface{}) error }) (*Template, error) {
+	var t Template
+	var id int64
+	var createdAt time.Time
+	var definition []byte
+	err := row.Scan(&id, &t.Name, &t.Description, &t.OrganizationID, &t.CreatedBy, &definition, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanTemplate: %w", err)
+	}
+	var body templateBody
+	if err := json.Unmarshal(definition, &body); err != nil {
+		return nil, fmt.Errorf("template %d definition: %w", id, err)
+	}
+	t.ID = strconv.FormatInt(id, 10)
+	t.CreatedAt = &createdAt
+	t.Parameters, t.Title, t.PollDescription = body.Parameters, body.Title, body.PollDescription
+	t.DurationDays, t.Questions = body.DurationDays, copyQuestions(body.Questions)
+	return &t, nil
+}
+
+// CreateTemplate publishes t for its organization, filling in its ID.
+func CreateTemplate(db *sql.DB, t *Template) error {
+	definition, err := json.Marshal(templateBody{
+		Parameters:      t.Parameters,
+		Title:           t.Title,
+		PollDescription: t.PollDescription,
+		DurationDays:    t.DurationDays,
+		Questions:       copyQuestions(t.Questions),
+	})
+	if err != nil {
+		return fmt.Errorf("CreateTemplate: %w", err)
+	}
+	result, err := db.Exec(`
+		INSERT INTO poll_templates (organization_id, created_by, name, description, definition)
+		VALUES (?, ?, ?, ?, ?)`,
+		t.OrganizationID, t.CreatedBy, t.Name, t.Description, definition)
+	if err != nil {
+		return fmt.Errorf("CreateTemplate: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("CreateTemplate LastInsertId: %w", err)
+	}
+	t.ID = strconv.FormatInt(id, 10)
+	t.Questions = copyQuestions(t.Questions)
+	return nil
+}
+
+// DeleteTemplate removes a template of an organization userID belongs to.
+// Polls already created from it are not affected.
+func DeleteTemplate(db *sql.DB, id, userID int64) error {
+	result, err := db.Exec(`
+		DELETE FROM poll_templates
+		WHERE id = ? AND organization_id IN (
+			SELECT organization_id FROM organization_members WHERE user_id = ?)`, id, userID)
+	if err != nil {
+		return fmt.Errorf("DeleteTemplate: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("template %w", ErrNotFound)
+	}
+	return nil
+}
+
+// IsOrganizationMember reports whether userID belongs to organization orgID.
+func IsOrganizationMember(db *sql.DB, orgID, userID int64) (bool, error) {
+	var one int
+	err := db.QueryRow(
+		"SELECT 1 FROM organization_members WHERE organization_id = ? AND user_id = ?", orgID, userID,
+	).Scan(&one)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("IsOrganizationMember: %w", err)
+	}
+	return true, nil
+}
+
+// paramValue is a parameter filled in for one poll. Exactly one of text and
+// list is used, depending on the parameter's type.
+type paramValue struct {
+	text string
+	list []string
+}
+
+// decodeParam reads raw as a value of p's type.
+func decodeParam(p TemplateParam, raw json.RawMessage) (paramValue, error) {
+	var v paramValue
+	if p.Type == ParamList {
+		if err := json.Unmarshal(raw, &v.list); err != nil {
+			return v, fmt.Errorf("must be an array of strings")
+		}
+		return v, nil
+	}
+	if err := json.Unmarshal(raw, &v.text); err != nil {
+		return v, fmt.Errorf("must be a string")
+	}
+	return v, nil
+}
+
+// render builds the poll a template describes with the parameters filled
+// in. Every parameter the template refers to must be in values.
+func (t *Template) render(values map[string]paramValue) *Poll {
+	fill := func(s string) string {
+		return placeholder.ReplaceAllStringFunc(s, func(m string) string {
+			v := values[placeholder.FindStringSubmatch(m)[1]]
+			if v.list != nil {
+				return strings.Join(v.list, ", ")
+			}
+			return v.text
+		})
+	}
+
+	p := &Poll{
+		Title:       fill(t.Title),
+		Description: fill(t.PollDescription),
+		Questions:   make([]Question, len(t.Questions)),
+	}
+	for i, q := range t.Questions {
+		p.Questions[i] = Question{Text: fill(q.Text), Choices: []Choice{}}
+		for _, c := range q.Choices {
+			if m := placeholder.FindStringSubmatch(strings.TrimSpace(c.Text)); m != nil && m[0] == strings.TrimSpace(c.Text) {
+				if v := values[m[1]]; v.list != nil {
+					p.Questions[i].Choices = append(p.Questions[i].Choices, textChoices(v.list...)...)
+					continue
+				}
+			}
+			p.Questions[i].Choices = append(p.Questions[i].Choices, Choice{Text: fill(c.Text)})
+		}
+	}
+	return p
+}
+
+// placeholders lists the parameter names a template refers to, with the
+// field, as api names it, each reference was found in.
+func (t *Template) placeholders(api *APIVersion) map[string]string {
+	found := make(map[string]string)
+	scan := func(field, s string) {
+		for _, m := range placeholder.FindAllStringSubmatch(s, -1) {
+			if _, ok := found[m[1]]; !ok {
+				found[m[1]] = field
+			}
+		}
+	}
+	scan("title", t.Title)
+	scan("poll_description", t.PollDescription)
+	for i, q := range t.Questions {
+		scan(fmt.Sprintf("questions[%d].text", i), q.Text)
+		for j, c := range q.Choices {
+			scan(fmt.Sprintf("questions[%d].choices[%d].%s", i, j, api.choiceText()), c.Text)
+		}
+	}
+	return found
+}
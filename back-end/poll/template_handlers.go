@@ -0,0 +1,221 @@
+package poll
+
+import (
+	"database/sql"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"simple-poll/apierror"
+)
+
+// TemplateRouter is the main entry point for /api/templates routes.
+// Example usage:
+//
+//	mux.Handle("/api/templates/", http.StripPrefix("/api/templates", TemplateRouter(db)))
+func TemplateRouter(db *sql.DB) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		// For instance, /api/templates/, /api/templates/nps or /api/templates/12/polls
+		path := strings.TrimPrefix(r.URL.Path, "/")
+		parts := strings.Split(path, "/")
+
+		switch {
+		case r.Method == http.MethodGet && len(parts) == 1 && parts[0] == "":
+			// GET /api/templates/ => list the catalog
+			listTemplatesHandler(db, w, r)
+		case r.Method == http.MethodGet && len(parts) == 1:
+			// GET /api/templates/nps => get one template
+			getTemplateHandler(db, w, r, parts[0])
+		case r.Method == http.MethodPost && len(parts) == 1 && parts[0] == "":
+			// POST /api/templates/ => publish an organization template
+			createTemplateHandler(db, w, r)
+		case r.Method == http.MethodPost && len(parts) == 2 && parts[0] != "" && parts[1] == "polls":
+			// POST /api/templates/nps/polls => create a poll from the template
+			instantiateTemplateHandler(db, w, r, parts[0])
+		case r.Method == http.MethodDelete && len(parts) == 1 && parts[0] != "":
+			// DELETE /api/templates/12
+			deleteTemplateHandler(db, w, r, parts[0])
+		default:
+			routeNotFound(w, r)
+		}
+	})
+
+	return mux
+}
+
+// listTemplatesHandler lists the built-in templates and those of the
+// caller's organizations, e.g. /api/templates/?organization_id=3 for one
+// organization's only. Without X-User-ID only built-in templates are listed.
+func listTemplatesHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	userID, aerr := optionalCallerID(r)
+	if aerr != nil {
+		writeError(w, aerr)
+		return
+	}
+	var orgID *int64
+	if v := r.URL.Query().Get("organization_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			writeError(w, apierror.BadRequest("Invalid organization_id"))
+			return
+		}
+		orgID = &id
+	}
+
+	templates, err := ListTemplates(db, userID, orgID)
+	if err != nil {
+		log.Printf("Error listing templates: %v", err)
+		writeError(w, storageError(err, "Failed to list templates"))
+		return
+	}
+	writeJSON(w, r, Page[Template]{Items: templates})
+}
+
+func getTemplateHandler(db *sql.DB, w http.ResponseWriter, r *http.Request, idParam string) {
+	userID, aerr := optionalCallerID(r)
+	if aerr != nil {
+		writeError(w, aerr)
+		return
+	}
+
+	t, err := GetTemplate(db, idParam, userID)
+	if err != nil {
+		log.Printf("Error getting template: %v", err)
+		writeError(w, storageError(err, "Failed to get template"))
+		return
+	}
+	writeJSON(w, r, t)
+}
+
+// createTemplateHandler publishes a template for an organization the caller
+// belongs to.
+func createTemplateHandler(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	userID, aerr := callerID(r)
+	if aerr != nil {
+		writeError(w, aerr)
+		return
+	}
+
+	var t Template
+	if err := decodeJSON(r, &t); err != nil {
+		log.Printf("Error decoding template: %v", err)
+		writeError(w, apierror.BadRequest("Invalid request payload"))
+		return
+	}
+	t.CreatedBy = &userID
+	t.CreatedAt = nil
+
+	if verr := validateTemplate(db, &t, versionOf(r)); verr != nil {
+		writeError(w, verr)
+		return
+	}
+	member, err := IsOrganizationMember(db, *t.OrganizationID, userID)
+	if err != nil {
+		log.Printf("Error checking organization membership: %v", err)
+		writeError(w, storageError(err, "Failed to create template"))
+		return
+	}
+	if !member {
+		writeError(w, apierror.Forbidden("Only members of the organization can publish its templates"))
+		return
+	}
+
+	if err := CreateTemplate(db, &t); err != nil {
+		log.Printf("Error creating template: %v", err)
+		writeError(w, storageError(err, "Failed to create template"))
+		return
+	}
+	writeJSON(w, r, t)
+}
+
+// instantiateTemplateHandler creates a draft poll owned by the caller from a
+// template, filling in its parameters. Dates default to now and the
+// template's duration.
+func instantiateTemplateHandler(db *sql.DB, w http.ResponseWriter, r *http.Request, idParam string) {
+	userID, aerr := callerID(r)
+	if aerr != nil {
+		writeError(w, aerr)
+		return
+	}
+
+	t, err := GetTemplate(db, idParam, userID)
+	if err != nil {
+		log.Printf("Error getting template: %v", err)
+		writeError(w, storageError(err, "Failed to get template"))
+		return
+	}
+
+	var req InstantiateRequest
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r, &req); err != nil && err != io.EOF {
+			log.Printf("Error decoding template parameters: %v", err)
+			writeError(w, apierror.BadRequest("Invalid request payload"))
+			return
+		}
+	}
+	values, verr := validateTemplateParams(t, req.Params)
+	if verr != nil {
+		writeError(w, verr)
+		return
+	}
+
+	p := t.render(values)
+	p.CreatedBy = userID
+	p.StartDate, p.EndDate = req.StartDate, req.EndDate
+	if p.StartDate == nil {
+		now := time.Now()
+		p.StartDate = &now
+	}
+	if p.EndDate == nil {
+		duration := 24 * time.Hour
+		if t.DurationDays > 0 {
+			duration = time.Duration(t.DurationDays) * 24 * time.Hour
+		}
+		end := p.StartDate.Add(duration)
+		p.EndDate = &end
+	}
+
+	if verr := validateNewPoll(db, p, versionOf(r)); verr != nil {
+		writeError(w, verr)
+		return
+	}
+	if err := CreatePollTree(db, p, userID); err != nil {
+		log.Printf("Error creating poll from template: %v", err)
+		writeError(w, storageError(err, "Failed to create poll"))
+		return
+	}
+
+	w.Header().Set("ETag", etag(r, p.Version))
+	writeJSON(w, r, p)
+}
+
+// deleteTemplateHandler removes one of the caller's organization templates.
+// Built-in templates cannot be deleted.
+func deleteTemplateHandler(db *sql.DB, w http.ResponseWriter, r *http.Request, idParam string) {
+	userID, aerr := callerID(r)
+	if aerr != nil {
+		writeError(w, aerr)
+		return
+	}
+	if builtinTemplate(idParam) != nil {
+		writeError(w, apierror.Forbidden("Built-in templates cannot be deleted"))
+		return
+	}
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		writeError(w, apierror.BadRequest("Invalid template ID"))
+		return
+	}
+
+	if err := DeleteTemplate(db, id, userID); err != nil {
+		log.Printf("Error deleting template: %v", err)
+		writeError(w, storageError(err, "Failed to delete template"))
+		return
+	}
+	writeJSON(w, r, map[string]string{"message": "Template deleted"})
+}
@@ -0,0 +1,85 @@
+#!/usr/bin/env bash
+#
+# test_openapi.sh
+#
+# Checks, for every API version, that /api/<version>/openapi.json and the
+# routers agree:
+#   - every documented method/path is served by a handler, and
+#   - every other method on a documented path is rejected as route_not_found.
+#
+# Path parameters are replaced with 0 and bodies are '{}', so the requests
+# only ever hit validation or "not found" errors and change no data.
+
+set -euo pipefail
+
+# Adjust as needed; no trailing slash unless your API requires it.
+API_BASE_URL="http://localhost:3000"
+METHODS="GET POST PUT PATCH DELETE"
+VERSIONS="v1 v2"
+
+# error_code METHOD URL prints the error code of the response, or nothing on
+# success. Rate-limited requests are retried after the advertised delay.
+error_code() {
+  local method="$1" url="$2" headers body
+  while true; do
+    headers=$(mktemp)
+    body=$(curl -s -D "$headers" -X "$method" "$url" \
+      -H "Content-Type: application/json" -d '{}')
+    local code
+    code=$(echo "$body" | jq -r '.error.code // empty' 2>/dev/null || true)
+    if [[ "$code" == "rate_limited" ]]; then
+      local wait
+      wait=$(grep -i '^Retry-After:' "$headers" | tr -dc '0-9')
+      rm -f "$headers"
+      sleep "${wait:-1}"
+      continue
+    fi
+    rm -f "$headers"
+    echo "$code"
+    return
+  done
+}
+
+FAILED=0
+for version in $VERSIONS; do
+  echo "=================================="
+  echo "Checking API ${version}"
+  echo "=================================="
+  SPEC=$(curl -s "${API_BASE_URL}/api/${version}/openapi.json")
+  if ! echo "$SPEC" | jq -e '.openapi' >/dev/null 2>&1; then
+    echo "ERROR: /api/${version}/openapi.json did not return an OpenAPI document."
+    exit 1
+  fi
+  SERVER_URL=$(echo "$SPEC" | jq -r '.servers[0].url')
+  echo "Documented paths: $(echo "$SPEC" | jq '.paths | length') under ${SERVER_URL}"
+
+  while read -r path; do
+    url="${API_BASE_URL}${SERVER_URL}$(echo "$path" | sed -e 's/{[^}]*}/0/g')"
+    documented=$(echo "$SPEC" | jq -r --arg p "$path" '.paths[$p] | keys[] | ascii_upcase')
+
+    for method in $METHODS; do
+      code=$(error_code "$method" "$url")
+      if echo "$documented" | grep -qx "$method"; then
+        if [[ "$code" == "route_not_found" ]]; then
+          echo "DRIFT: $method ${SERVER_URL}$path is documented but no handler serves it"
+          FAILED=1
+        fi
+      elif [[ "$code" != "route_not_found" ]]; then
+        echo "DRIFT: $method ${SERVER_URL}$path is served (got '${code:-success}') but not documented"
+        FAILED=1
+      fi
+    done
+  done < <(echo "$SPEC" | jq -r '.paths | keys[]')
+  echo
+done
+
+if [[ "$FAILED" -ne 0 ]]; then
+  echo "ERROR: The OpenAPI document and the routers have drifted apart."
+  echo "Update Routes in poll/routes.go to match the handlers."
+  exit 1
+fi
+
+echo "=========================================="
+echo "All steps completed successfully."
+echo "=========================================="
+exit 0
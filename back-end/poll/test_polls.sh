@@ -86,9 +86,9 @@ echo "STEP 4: Delete the newly created Poll"
 echo "==================================="
 
 echo "About to run:"
-echo "curl -s -w \"\\nHTTP_CODE:%{http_code}\" -X DELETE \"${ENDPOINT}\""
+echo "curl -s -w \"\\nHTTP_CODE:%{http_code}\" -X DELETE -H \"If-Match: *\" \"${ENDPOINT}\""
 
-RESPONSE=$(curl -s -w "\nHTTP_CODE:%{http_code}" -X DELETE "${ENDPOINT}")
+RESPONSE=$(curl -s -w "\nHTTP_CODE:%{http_code}" -X DELETE -H "If-Match: *" "${ENDPOINT}")
 BODY=$(echo "$RESPONSE" | sed -e '/HTTP_CODE:/d')
 HTTP_CODE=$(echo "$RESPONSE" | sed -n 's/.*HTTP_CODE:\([0-9]*\).*/\1/p')
 
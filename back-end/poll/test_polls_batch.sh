@@ -0,0 +1,81 @@
+#!/usr/bin/env bash
+#
+# test_polls_batch.sh
+#
+# Creates a Poll with nested Questions and Choices in a single POST, then
+# checks that every generated ID came back. Deletes the Poll afterwards.
+
+set -euo pipefail
+
+# Adjust as needed; no trailing slash unless your API requires it.
+API_BASE_URL="http://localhost:3000"
+
+echo "=================================="
+echo "STEP 1: Create a Poll tree via POST"
+echo "=================================="
+POLL_PAYLOAD='{
+  "title": "Sample Poll (Batch)",
+  "description": "Created with nested questions and choices",
+  "created_by": 100,
+  "questions": [
+    {
+      "text": "Favorite color?",
+      "choices": [
+        {"choice_text": "Red"},
+        {"choice_text": "Green"},
+        {"choice_text": "Blue"}
+      ]
+    },
+    {
+      "text": "Coffee or tea?",
+      "choices": [
+        {"choice_text": "Coffee"},
+        {"choice_text": "Tea"}
+      ]
+    }
+  ]
+}'
+
+RESPONSE=$(curl -s -w "\nHTTP_CODE:%{http_code}" \
+  -X POST "${API_BASE_URL}/api/polls/" \
+  -H "Content-Type: application/json" \
+  -d "${POLL_PAYLOAD}"
+)
+
+BODY=$(echo "$RESPONSE" | sed -e '/HTTP_CODE:/d')
+HTTP_CODE=$(echo "$RESPONSE" | sed -n 's/.*HTTP_CODE:\([0-9]*\).*/\1/p')
+
+echo "HTTP code: $HTTP_CODE"
+echo "Response body: $BODY"
+echo
+
+if [[ "$HTTP_CODE" -ne 200 && "$HTTP_CODE" -ne 201 ]]; then
+  echo "ERROR: Failed to create poll tree."
+  exit 1
+fi
+
+POLL_ID=$(echo "$BODY" | jq -r '.id')
+QUESTION_COUNT=$(echo "$BODY" | jq '[.questions[] | select(.id > 0 and .poll_id == '"$POLL_ID"')] | length')
+CHOICE_COUNT=$(echo "$BODY" | jq '[.questions[] | .id as $qid | .choices[] | select(.id > 0 and .question_id == $qid)] | length')
+
+echo "Poll ID: $POLL_ID, questions: $QUESTION_COUNT, choices: $CHOICE_COUNT"
+if [[ "$QUESTION_COUNT" -ne 2 || "$CHOICE_COUNT" -ne 5 ]]; then
+  echo "ERROR: Expected 2 questions and 5 choices with generated IDs."
+  exit 1
+fi
+echo
+
+echo "=================================="
+echo "STEP 2: Delete the Poll"
+echo "=================================="
+HTTP_CODE=$(curl -s -o /dev/null -w "%{http_code}" -X DELETE -H "If-Match: *" "${API_BASE_URL}/api/polls/${POLL_ID}")
+echo "HTTP code: $HTTP_CODE"
+if [[ "$HTTP_CODE" != "200" ]]; then
+  echo "ERROR: Could not delete poll with ID: $POLL_ID"
+  exit 1
+fi
+
+echo "=========================================="
+echo "All steps completed successfully."
+echo "=========================================="
+exit 0
@@ -0,0 +1,83 @@
+#!/usr/bin/env bash
+#
+# test_polls_clone.sh
+#
+# Clones a published Poll and checks that the copy is a new draft owned by
+# the caller, with the same questions and choices in the same order, shifted
+# dates and, when asked, no description. Deletes both Polls afterwards.
+
+set -euo pipefail
+
+# Adjust as needed; no trailing slash unless your API requires it.
+API_BASE_URL="http://localhost:3000"
+
+echo "=================================="
+echo "STEP 1: Create and publish a Poll"
+echo "=================================="
+BODY=$(curl -s -X POST "${API_BASE_URL}/api/polls/" \
+  -H "Content-Type: application/json" \
+  -d '{
+    "title": "Sample Poll (Clone source)",
+    "description": "Quarterly survey",
+    "created_by": 100,
+    "start_date": "2026-01-05T09:00:00Z",
+    "end_date": "2026-01-12T09:00:00Z",
+    "questions": [
+      {"text": "Favourite colour?", "choices": [{"choice_text": "Red"}, {"choice_text": "Blue"}]},
+      {"text": "Favourite season?", "choices": [{"choice_text": "Summer"}, {"choice_text": "Winter"}]}
+    ]
+  }')
+SOURCE_ID=$(echo "$BODY" | jq -r '.id')
+echo "Created Poll ID: $SOURCE_ID"
+curl -s -o /dev/null -X POST "${API_BASE_URL}/api/polls/${SOURCE_ID}/publish"
+echo
+
+echo "=================================="
+echo "STEP 2: Refuse a clone without a caller"
+echo "=================================="
+CODE=$(curl -s -o /dev/null -w "%{http_code}" -X POST "${API_BASE_URL}/api/polls/${SOURCE_ID}/clone")
+echo "POST clone without X-User-ID => $CODE"
+if [[ "$CODE" != "401" ]]; then
+  echo "ERROR: Expected 401"
+  exit 1
+fi
+echo
+
+echo "=================================="
+echo "STEP 3: Clone it a week later without the description"
+echo "=================================="
+CLONE=$(curl -s -X POST "${API_BASE_URL}/api/polls/${SOURCE_ID}/clone" \
+  -H "Content-Type: application/json" \
+  -H "X-User-ID: 100" \
+  -d '{"shift_days": 7, "drop_description": true}')
+echo "$CLONE" | jq .
+CLONE_ID=$(echo "$CLONE" | jq -r '.id')
+
+check() {
+  local got
+  got=$(echo "$CLONE" | jq -r "$1")
+  if [[ "$got" != "$2" ]]; then
+    echo "ERROR: $1 is $got, expected $2"
+    exit 1
+  fi
+}
+check '.id != '"$SOURCE_ID" true
+check .state draft
+check .created_by 100
+check .description ""
+check .start_date 2026-01-12T09:00:00Z
+check .end_date 2026-01-19T09:00:00Z
+check '[.questions[].text] | join(",")' "Favourite colour?,Favourite season?"
+check '[.questions[].choices[].choice_text] | join(",")' "Red,Blue,Summer,Winter"
+echo "Clone ID: $CLONE_ID checks out"
+echo
+
+echo "=================================="
+echo "STEP 4: Delete both Polls"
+echo "=================================="
+for ID in "$SOURCE_ID" "$CLONE_ID"; do
+  curl -s -X DELETE "${API_BASE_URL}/api/polls/${ID}" -H "If-Match: *" | jq .
+done
+
+echo
+echo "All clone checks passed."
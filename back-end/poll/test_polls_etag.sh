@@ -0,0 +1,142 @@
+#!/usr/bin/env bash
+#
+# test_polls_etag.sh
+#
+# Exercises ETags and optimistic concurrency on a Poll:
+#   - GET returns an ETag and honours If-None-Match with 304
+#   - PATCH without If-Match is refused with 428
+#   - PATCH with the current ETag succeeds and yields a new ETag
+#   - PATCH with the old, now stale ETag is refused with 412
+#   - the ETag returned when creating a Poll with nested questions is current
+# Deletes the Polls afterwards.
+
+set -euo pipefail
+
+# Adjust as needed; no trailing slash unless your API requires it.
+API_BASE_URL="http://localhost:3000"
+
+# etag_of prints the ETag header of a GET on the given URL.
+etag_of() {
+  curl -s -D - -o /dev/null "$1" | tr -d '\r' | sed -n 's/^[Ee][Tt]ag: //p'
+}
+
+echo "=================================="
+echo "STEP 1: Create a Poll"
+echo "=================================="
+RESPONSE=$(curl -s -w "\nHTTP_CODE:%{http_code}" \
+  -X POST "${API_BASE_URL}/api/polls/" \
+  -H "Content-Type: application/json" \
+  -d '{"title": "Sample Poll (ETag)", "description": "Concurrency check", "created_by": 100}')
+BODY=$(echo "$RESPONSE" | sed -e '/HTTP_CODE:/d')
+HTTP_CODE=$(echo "$RESPONSE" | sed -n 's/.*HTTP_CODE:\([0-9]*\).*/\1/p')
+echo "HTTP code: $HTTP_CODE"
+if [[ "$HTTP_CODE" != "200" && "$HTTP_CODE" != "201" ]]; then
+  echo "ERROR: Poll creation failed"
+  echo "$BODY"
+  exit 1
+fi
+POLL_ID=$(echo "$BODY" | jq -r '.id')
+ENDPOINT="${API_BASE_URL}/api/polls/${POLL_ID}"
+echo "Created Poll ID: $POLL_ID"
+echo
+
+echo "=================================="
+echo "STEP 2: Conditional GET"
+echo "=================================="
+ETAG=$(etag_of "$ENDPOINT")
+echo "ETag: $ETAG"
+if [[ -z "$ETAG" ]]; then
+  echo "ERROR: GET returned no ETag"
+  exit 1
+fi
+HTTP_CODE=$(curl -s -o /dev/null -w "%{http_code}" -H "If-None-Match: ${ETAG}" "$ENDPOINT")
+echo "HTTP code with If-None-Match: $HTTP_CODE"
+if [[ "$HTTP_CODE" != "304" ]]; then
+  echo "ERROR: Expected 304 Not Modified"
+  exit 1
+fi
+echo
+
+echo "=================================="
+echo "STEP 3: PATCH without If-Match"
+echo "=================================="
+HTTP_CODE=$(curl -s -o /dev/null -w "%{http_code}" -X PATCH "$ENDPOINT" \
+  -H "Content-Type: application/json" -d '{"title": "No precondition"}')
+echo "HTTP code: $HTTP_CODE"
+if [[ "$HTTP_CODE" != "428" ]]; then
+  echo "ERROR: Expected 428 Precondition Required"
+  exit 1
+fi
+echo
+
+echo "=================================="
+echo "STEP 4: PATCH with the current ETag"
+echo "=================================="
+HTTP_CODE=$(curl -s -o /dev/null -w "%{http_code}" -X PATCH "$ENDPOINT" \
+  -H "Content-Type: application/json" -H "If-Match: ${ETAG}" -d '{"title": "Renamed once"}')
+echo "HTTP code: $HTTP_CODE"
+if [[ "$HTTP_CODE" != "200" ]]; then
+  echo "ERROR: Expected 200 OK"
+  exit 1
+fi
+NEW_ETAG=$(etag_of "$ENDPOINT")
+echo "New ETag: $NEW_ETAG"
+if [[ "$NEW_ETAG" == "$ETAG" ]]; then
+  echo "ERROR: ETag did not change after the update"
+  exit 1
+fi
+echo
+
+echo "=================================="
+echo "STEP 5: PATCH with the stale ETag"
+echo "=================================="
+HTTP_CODE=$(curl -s -o /dev/null -w "%{http_code}" -X PATCH "$ENDPOINT" \
+  -H "Content-Type: application/json" -H "If-Match: ${ETAG}" -d '{"title": "Lost update"}')
+echo "HTTP code: $HTTP_CODE"
+if [[ "$HTTP_CODE" != "412" ]]; then
+  echo "ERROR: Expected 412 Precondition Failed"
+  exit 1
+fi
+echo
+
+echo "=================================="
+echo "STEP 6: Create a Poll with nested questions and PATCH it with the returned ETag"
+echo "=================================="
+NESTED_HEADERS=$(mktemp)
+NESTED=$(curl -s -D "$NESTED_HEADERS" -X POST "${API_BASE_URL}/api/polls/" \
+  -H "Content-Type: application/json" \
+  -d '{
+    "title": "Sample Poll (ETag, nested)",
+    "created_by": 100,
+    "questions": [{"text": "Ready?", "choices": [{"choice_text": "Yes"}, {"choice_text": "No"}]}]
+  }')
+NESTED_ID=$(echo "$NESTED" | jq -r '.id')
+NESTED_ETAG=$(tr -d '\r' < "$NESTED_HEADERS" | sed -n 's/^[Ee][Tt]ag: //p')
+rm -f "$NESTED_HEADERS"
+echo "Created Poll ID: $NESTED_ID with ETag: $NESTED_ETAG"
+if [[ "$NESTED_ETAG" != "$(etag_of "${API_BASE_URL}/api/polls/${NESTED_ID}")" ]]; then
+  echo "ERROR: The ETag returned on creation is not the Poll's current ETag"
+  exit 1
+fi
+HTTP_CODE=$(curl -s -o /dev/null -w "%{http_code}" -X PATCH "${API_BASE_URL}/api/polls/${NESTED_ID}" \
+  -H "Content-Type: application/json" -H "If-Match: ${NESTED_ETAG}" -d '{"title": "Nested, renamed"}')
+echo "HTTP code: $HTTP_CODE"
+if [[ "$HTTP_CODE" != "200" ]]; then
+  echo "ERROR: Expected 200 when patching with the ETag returned on creation"
+  exit 1
+fi
+curl -s -o /dev/null -X DELETE -H "If-Match: *" "${API_BASE_URL}/api/polls/${NESTED_ID}"
+echo
+
+echo "=================================="
+echo "STEP 7: Delete the Poll"
+echo "=================================="
+HTTP_CODE=$(curl -s -o /dev/null -w "%{http_code}" -X DELETE -H "If-Match: ${NEW_ETAG}" "$ENDPOINT")
+echo "HTTP code: $HTTP_CODE"
+if [[ "$HTTP_CODE" != "200" ]]; then
+  echo "ERROR: Could not delete poll with ID: $POLL_ID"
+  exit 1
+fi
+
+echo
+echo "All ETag checks passed."
@@ -0,0 +1,80 @@
+#!/usr/bin/env bash
+#
+# test_polls_idempotency.sh
+#
+# Sends the same poll-creating POST twice with one Idempotency-Key and checks
+# that the second is replayed instead of creating a duplicate, then reuses the
+# key with a different payload and expects 422. Deletes the Poll afterwards.
+
+set -euo pipefail
+
+# Adjust as needed; no trailing slash unless your API requires it.
+API_BASE_URL="http://localhost:3000"
+
+KEY="test-$(date +%s)-$$"
+PAYLOAD='{"title": "Sample Poll (Idempotent)", "description": "Sent twice", "created_by": 100}'
+
+# post_poll sends the given JSON with the test's Idempotency-Key and
+# prints the body followed by an HTTP_CODE line.
+post_poll() {
+  curl -s -w "\nHTTP_CODE:%{http_code}" \
+    -X POST "${API_BASE_URL}/api/polls/" \
+    -H "Content-Type: application/json" \
+    -H "Idempotency-Key: ${KEY}" \
+    -d "$1"
+}
+
+echo "=================================="
+echo "STEP 1: Create a Poll with Idempotency-Key: ${KEY}"
+echo "=================================="
+RESPONSE=$(post_poll "$PAYLOAD")
+BODY=$(echo "$RESPONSE" | sed -e '/HTTP_CODE:/d')
+HTTP_CODE=$(echo "$RESPONSE" | sed -n 's/.*HTTP_CODE:\([0-9]*\).*/\1/p')
+echo "HTTP code: $HTTP_CODE"
+if [[ "$HTTP_CODE" != "200" && "$HTTP_CODE" != "201" ]]; then
+  echo "ERROR: Poll creation failed"
+  echo "$BODY"
+  exit 1
+fi
+POLL_ID=$(echo "$BODY" | jq -r '.id')
+echo "Created Poll ID: $POLL_ID"
+echo
+
+echo "=================================="
+echo "STEP 2: Retry the same request"
+echo "=================================="
+RESPONSE=$(post_poll "$PAYLOAD")
+BODY=$(echo "$RESPONSE" | sed -e '/HTTP_CODE:/d')
+HTTP_CODE=$(echo "$RESPONSE" | sed -n 's/.*HTTP_CODE:\([0-9]*\).*/\1/p')
+RETRY_ID=$(echo "$BODY" | jq -r '.id')
+echo "HTTP code: $HTTP_CODE, Poll ID: $RETRY_ID"
+if [[ "$RETRY_ID" != "$POLL_ID" ]]; then
+  echo "ERROR: Retry created a second poll ($RETRY_ID) instead of replaying $POLL_ID"
+  exit 1
+fi
+echo
+
+echo "=================================="
+echo "STEP 3: Reuse the key with another payload"
+echo "=================================="
+RESPONSE=$(post_poll '{"title": "Something else", "created_by": 100}')
+HTTP_CODE=$(echo "$RESPONSE" | sed -n 's/.*HTTP_CODE:\([0-9]*\).*/\1/p')
+echo "HTTP code: $HTTP_CODE"
+if [[ "$HTTP_CODE" != "422" ]]; then
+  echo "ERROR: Expected 422 for a reused Idempotency-Key"
+  exit 1
+fi
+echo
+
+echo "=================================="
+echo "STEP 4: Delete the Poll"
+echo "=================================="
+HTTP_CODE=$(curl -s -o /dev/null -w "%{http_code}" -X DELETE -H "If-Match: *" "${API_BASE_URL}/api/polls/${POLL_ID}")
+echo "HTTP code: $HTTP_CODE"
+if [[ "$HTTP_CODE" != "200" ]]; then
+  echo "ERROR: Could not delete poll with ID: $POLL_ID"
+  exit 1
+fi
+
+echo
+echo "All idempotency checks passed."
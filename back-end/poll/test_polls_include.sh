@@ -0,0 +1,75 @@
+#!/usr/bin/env bash
+#
+# test_polls_include.sh
+#
+# Creates a Poll tree, then lists polls with ?include=questions,choices and
+# ?fields=id,title and checks that the listed Poll carries its questions and
+# choices but no other fields. Deletes the Poll afterwards.
+
+set -euo pipefail
+
+# Adjust as needed; no trailing slash unless your API requires it.
+API_BASE_URL="http://localhost:3000"
+
+echo "=================================="
+echo "STEP 1: Create a Poll tree"
+echo "=================================="
+BODY=$(curl -s -X POST "${API_BASE_URL}/api/polls/" \
+  -H "Content-Type: application/json" \
+  -d '{
+    "title": "Sample Poll (Include)",
+    "created_by": 100,
+    "questions": [{"text": "Cats or dogs?", "choices": [{"choice_text": "Cats"}, {"choice_text": "Dogs"}]}]
+  }')
+POLL_ID=$(echo "$BODY" | jq -r '.id')
+if [[ -z "$POLL_ID" || "$POLL_ID" == "null" ]]; then
+  echo "ERROR: Poll creation failed"
+  echo "$BODY"
+  exit 1
+fi
+echo "Created Poll ID: $POLL_ID"
+echo
+
+echo "=================================="
+echo "STEP 2: List with include and fields"
+echo "=================================="
+URL="${API_BASE_URL}/api/polls/?sort=-created_at&limit=10&include=questions,choices&fields=id,title"
+echo "GET $URL"
+ITEM=$(curl -s "$URL" | jq --argjson id "$POLL_ID" '.items[] | select(.id == $id)')
+echo "$ITEM"
+
+KEYS=$(echo "$ITEM" | jq -c 'keys')
+if [[ "$KEYS" != '["id","questions","title"]' ]]; then
+  echo "ERROR: Expected only id, title and questions, got $KEYS"
+  exit 1
+fi
+CHOICES=$(echo "$ITEM" | jq '.questions[0].choices | length')
+if [[ "$CHOICES" != "2" ]]; then
+  echo "ERROR: Expected 2 embedded choices, got $CHOICES"
+  exit 1
+fi
+echo
+
+echo "=================================="
+echo "STEP 3: Unknown field"
+echo "=================================="
+HTTP_CODE=$(curl -s -o /dev/null -w "%{http_code}" "${API_BASE_URL}/api/polls/?fields=id,nope")
+echo "HTTP code: $HTTP_CODE"
+if [[ "$HTTP_CODE" != "400" ]]; then
+  echo "ERROR: Expected 400 for an unknown field"
+  exit 1
+fi
+echo
+
+echo "=================================="
+echo "STEP 4: Delete the Poll"
+echo "=================================="
+HTTP_CODE=$(curl -s -o /dev/null -w "%{http_code}" -X DELETE -H "If-Match: *" "${API_BASE_URL}/api/polls/${POLL_ID}")
+echo "HTTP code: $HTTP_CODE"
+if [[ "$HTTP_CODE" != "200" ]]; then
+  echo "ERROR: Could not delete poll with ID: $POLL_ID"
+  exit 1
+fi
+
+echo
+echo "All include/fields checks passed."
@@ -0,0 +1,67 @@
+#!/usr/bin/env bash
+#
+# test_polls_lifecycle.sh
+#
+# Walks a Poll through draft -> published -> closed -> archived and checks
+# that questions can only be added while it is a draft, that skipping a state
+# is refused, and that an archived poll cannot be edited or (un)tagged.
+# Deletes the Poll afterwards.
+
+set -euo pipefail
+
+# Adjust as needed; no trailing slash unless your API requires it.
+API_BASE_URL="http://localhost:3000"
+
+# expect_code METHOD URL BODY WANT runs a request and fails unless it
+# answers with HTTP status WANT.
+expect_code() {
+  local code
+  code=$(curl -s -o /dev/null -w "%{http_code}" -X "$1" "$2" \
+    -H "Content-Type: application/json" -H "If-Match: *" -d "$3")
+  echo "$1 $2 => $code"
+  if [[ "$code" != "$4" ]]; then
+    echo "ERROR: Expected $4"
+    exit 1
+  fi
+}
+
+echo "=================================="
+echo "STEP 1: Create a draft Poll"
+echo "=================================="
+BODY=$(curl -s -X POST "${API_BASE_URL}/api/polls/" \
+  -H "Content-Type: application/json" \
+  -d '{
+    "title": "Sample Poll (Lifecycle)",
+    "created_by": 100,
+    "questions": [{"text": "Ready?", "choices": [{"choice_text": "Yes"}, {"choice_text": "No"}]}]
+  }')
+POLL_ID=$(echo "$BODY" | jq -r '.id')
+STATE=$(echo "$BODY" | jq -r '.state')
+echo "Created Poll ID: $POLL_ID in state $STATE"
+if [[ "$STATE" != "draft" ]]; then
+  echo "ERROR: New polls should be drafts"
+  exit 1
+fi
+POLL_URL="${API_BASE_URL}/api/polls/${POLL_ID}"
+echo
+
+echo "=================================="
+echo "STEP 2: Transitions"
+echo "=================================="
+expect_code POST "${POLL_URL}/close" '' 409
+expect_code POST "${POLL_URL}/publish" '' 200
+expect_code POST "${API_BASE_URL}/api/questions/" "{\"poll_id\": ${POLL_ID}, \"text\": \"Too late?\"}" 409
+expect_code POST "${POLL_URL}/close" '' 200
+expect_code POST "${POLL_URL}/archive" '' 200
+expect_code PATCH "$POLL_URL" '{"title": "Rewriting history"}' 409
+expect_code PUT "${POLL_URL}/tags/rewritten" '' 409
+expect_code DELETE "${POLL_URL}/tags/rewritten" '' 409
+echo
+
+echo "=================================="
+echo "STEP 3: Delete the Poll"
+echo "=================================="
+expect_code DELETE "$POLL_URL" '' 200
+
+echo
+echo "All lifecycle checks passed."
@@ -0,0 +1,103 @@
+#!/usr/bin/env bash
+#
+# test_polls_order.sh
+#
+# Creates a Poll with three Questions, reverses their order through
+# PUT /api/polls/<id>/questions/order and checks that GET returns them in the
+# new order. Also checks that an incomplete ID list is refused with 422, and
+# that reordering without If-Match or with a stale ETag is refused.
+# Deletes the Poll afterwards.
+
+set -euo pipefail
+
+# Adjust as needed; no trailing slash unless your API requires it.
+API_BASE_URL="http://localhost:3000"
+
+# etag_of prints the ETag header of a GET on the given URL.
+etag_of() {
+  curl -s -D - -o /dev/null "$1" | tr -d '\r' | sed -n 's/^[Ee][Tt]ag: //p'
+}
+
+echo "=================================="
+echo "STEP 1: Create a Poll with three Questions"
+echo "=================================="
+BODY=$(curl -s -X POST "${API_BASE_URL}/api/polls/" \
+  -H "Content-Type: application/json" \
+  -d '{
+    "title": "Sample Poll (Order)",
+    "created_by": 100,
+    "questions": [{"text": "First?"}, {"text": "Second?"}, {"text": "Third?"}]
+  }')
+POLL_ID=$(echo "$BODY" | jq -r '.id')
+IDS=$(echo "$BODY" | jq -c '[.questions[].id]')
+REVERSED=$(echo "$BODY" | jq -c '[.questions[].id] | reverse')
+if [[ -z "$POLL_ID" || "$POLL_ID" == "null" ]]; then
+  echo "ERROR: Poll creation failed"
+  echo "$BODY"
+  exit 1
+fi
+echo "Created Poll ID: $POLL_ID with questions $IDS"
+echo
+
+echo "=================================="
+echo "STEP 2: Reverse the question order"
+echo "=================================="
+ETAG=$(etag_of "${API_BASE_URL}/api/polls/${POLL_ID}")
+HTTP_CODE=$(curl -s -o /dev/null -w "%{http_code}" -X PUT \
+  "${API_BASE_URL}/api/polls/${POLL_ID}/questions/order" \
+  -H "Content-Type: application/json" -d "{\"ids\": ${REVERSED}}")
+echo "HTTP code without If-Match: $HTTP_CODE"
+if [[ "$HTTP_CODE" != "428" ]]; then
+  echo "ERROR: Expected 428 Precondition Required"
+  exit 1
+fi
+HTTP_CODE=$(curl -s -o /dev/null -w "%{http_code}" -X PUT \
+  "${API_BASE_URL}/api/polls/${POLL_ID}/questions/order" \
+  -H "Content-Type: application/json" -H "If-Match: ${ETAG}" -d "{\"ids\": ${REVERSED}}")
+echo "HTTP code: $HTTP_CODE"
+if [[ "$HTTP_CODE" != "200" ]]; then
+  echo "ERROR: Reorder failed"
+  exit 1
+fi
+ORDER=$(curl -s "${API_BASE_URL}/api/polls/${POLL_ID}" | jq -c '[.questions[].id]')
+echo "Order after GET: $ORDER"
+if [[ "$ORDER" != "$REVERSED" ]]; then
+  echo "ERROR: Expected $REVERSED"
+  exit 1
+fi
+HTTP_CODE=$(curl -s -o /dev/null -w "%{http_code}" -X PUT \
+  "${API_BASE_URL}/api/polls/${POLL_ID}/questions/order" \
+  -H "Content-Type: application/json" -H "If-Match: ${ETAG}" -d "{\"ids\": ${IDS}}")
+echo "HTTP code with the stale ETag: $HTTP_CODE"
+if [[ "$HTTP_CODE" != "412" ]]; then
+  echo "ERROR: Expected 412 Precondition Failed"
+  exit 1
+fi
+echo
+
+echo "=================================="
+echo "STEP 3: Reorder with a missing ID"
+echo "=================================="
+PARTIAL=$(echo "$REVERSED" | jq -c '.[1:]')
+HTTP_CODE=$(curl -s -o /dev/null -w "%{http_code}" -X PUT \
+  "${API_BASE_URL}/api/polls/${POLL_ID}/questions/order" \
+  -H "Content-Type: application/json" -H "If-Match: *" -d "{\"ids\": ${PARTIAL}}")
+echo "HTTP code: $HTTP_CODE"
+if [[ "$HTTP_CODE" != "422" ]]; then
+  echo "ERROR: Expected 422 for an incomplete order"
+  exit 1
+fi
+echo
+
+echo "=================================="
+echo "STEP 4: Delete the Poll"
+echo "=================================="
+HTTP_CODE=$(curl -s -o /dev/null -w "%{http_code}" -X DELETE -H "If-Match: *" "${API_BASE_URL}/api/polls/${POLL_ID}")
+echo "HTTP code: $HTTP_CODE"
+if [[ "$HTTP_CODE" != "200" ]]; then
+  echo "ERROR: Could not delete poll with ID: $POLL_ID"
+  exit 1
+fi
+
+echo
+echo "All ordering checks passed."
@@ -6,8 +6,8 @@
 # Afterward, creates Choices (4 for Question #1 and 2 for Question #2).
 # This script does NOT delete the created Poll, Questions, or Choices.
 #
-# Note: The API does not support batch creation. We must call each endpoint
-# (Poll, Question, Choice) individually.
+# Note: This script calls each endpoint (Poll, Question, Choice) individually.
+# See test_polls_batch.sh for creating the whole tree in one request.
 
 set -euo pipefail
 
@@ -0,0 +1,83 @@
+#!/usr/bin/env bash
+#
+# test_polls_qr.sh
+#
+# Fetches the QR code of a Poll's voting link as PNG and SVG, by ID and by
+# slug, with a custom size, error correction level and voting token, and
+# checks that bad parameters are refused. Deletes the Poll afterwards.
+
+set -euo pipefail
+
+# Adjust as needed; no trailing slash unless your API requires it.
+API_BASE_URL="http://localhost:3000"
+
+# expect_image WANT_TYPE URL fails unless URL serves a 200 of WANT_TYPE.
+expect_image() {
+  local out
+  out=$(curl -s -o /tmp/qr_body -w "%{http_code} %{content_type}" "$2")
+  echo "GET $2 => $out"
+  if [[ "$out" != "200 $1" ]]; then
+    echo "ERROR: Expected 200 $1"
+    exit 1
+  fi
+}
+
+# expect_code WANT URL fails unless GET URL returns WANT.
+expect_code() {
+  local code
+  code=$(curl -s -o /dev/null -w "%{http_code}" "$2")
+  echo "GET $2 => $code"
+  if [[ "$code" != "$1" ]]; then
+    echo "ERROR: Expected $1"
+    exit 1
+  fi
+}
+
+echo "=================================="
+echo "STEP 1: Create a Poll"
+echo "=================================="
+BODY=$(curl -s -X POST "${API_BASE_URL}/api/polls/" \
+  -H "Content-Type: application/json" \
+  -d '{"title": "Sample Poll (QR code)", "created_by": 100}')
+POLL_ID=$(echo "$BODY" | jq -r '.id')
+SLUG=$(echo "$BODY" | jq -r '.slug')
+echo "Created Poll ID: $POLL_ID with slug $SLUG"
+echo
+
+echo "=================================="
+echo "STEP 2: Fetch the PNG"
+echo "=================================="
+expect_image image/png "${API_BASE_URL}/api/polls/${POLL_ID}/qr.png"
+if [[ "$(head -c 8 /tmp/qr_body | od -An -tx1 | tr -d ' \n')" != "89504e470d0a1a0a" ]]; then
+  echo "ERROR: The body is not a PNG file"
+  exit 1
+fi
+expect_image image/png "${API_BASE_URL}/api/polls/${SLUG}/qr.png?size=1024&ecc=h&token=abc-123_XYZ"
+echo
+
+echo "=================================="
+echo "STEP 3: Fetch the SVG"
+echo "=================================="
+expect_image image/svg+xml "${API_BASE_URL}/api/polls/${POLL_ID}/qr.svg?size=300&ecc=L"
+if ! grep -q '<svg .*width="300"' /tmp/qr_body; then
+  echo "ERROR: Expected a 300 pixel SVG document"
+  exit 1
+fi
+echo
+
+echo "=================================="
+echo "STEP 4: Refuse bad parameters and unknown Polls"
+echo "=================================="
+expect_code 400 "${API_BASE_URL}/api/polls/${POLL_ID}/qr.png?size=10"
+expect_code 400 "${API_BASE_URL}/api/polls/${POLL_ID}/qr.png?ecc=X"
+expect_code 400 "${API_BASE_URL}/api/polls/${POLL_ID}/qr.svg?token=not%20url%20safe"
+expect_code 404 "${API_BASE_URL}/api/polls/0/qr.png"
+echo
+
+echo "=================================="
+echo "STEP 5: Delete the Poll"
+echo "=================================="
+curl -s -X DELETE "${API_BASE_URL}/api/polls/${POLL_ID}" -H "If-Match: *" | jq .
+
+echo
+echo "All QR code checks passed."
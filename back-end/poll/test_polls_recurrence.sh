@@ -0,0 +1,103 @@
+#!/usr/bin/env bash
+#
+# test_polls_recurrence.sh
+#
+# Creates a recurring Poll, checks that invalid rules are refused, that the
+# rule can be changed, given a time zone and cleared, and that the occurrences endpoint lists the
+# series. Occurrences themselves are created by the scheduler once the rule
+# falls due, so with a fresh Poll the list is empty. Deletes the Poll
+# afterwards.
+
+set -euo pipefail
+
+# Adjust as needed; no trailing slash unless your API requires it.
+API_BASE_URL="http://localhost:3000"
+
+# expect_code METHOD URL BODY WANT runs a request and fails unless it
+# answers with HTTP status WANT.
+expect_code() {
+  local code
+  code=$(curl -s -o /dev/null -w "%{http_code}" -X "$1" "$2" \
+    -H "Content-Type: application/json" -H "If-Match: *" -d "$3")
+  echo "$1 $2 => $code"
+  if [[ "$code" != "$4" ]]; then
+    echo "ERROR: Expected $4"
+    exit 1
+  fi
+}
+
+echo "=================================="
+echo "STEP 1: Refuse invalid rules"
+echo "=================================="
+expect_code POST "${API_BASE_URL}/api/polls/" \
+  '{"title": "Bad rule", "created_by": 100, "recurrence": "FREQ=YEARLY"}' 422
+expect_code POST "${API_BASE_URL}/api/polls/" \
+  '{"title": "Bad rule", "created_by": 100, "recurrence": "FREQ=DAILY;BYDAY=MO"}' 422
+echo
+
+echo "=================================="
+echo "STEP 2: Create a weekly Poll"
+echo "=================================="
+BODY=$(curl -s -X POST "${API_BASE_URL}/api/polls/" \
+  -H "Content-Type: application/json" \
+  -d '{
+    "title": "Sample Poll (Weekly retro)",
+    "created_by": 100,
+    "recurrence": "FREQ=WEEKLY;BYDAY=MO;BYHOUR=9;BYMINUTE=0",
+    "questions": [{"text": "How was the week?", "choices": [{"choice_text": "Good"}, {"choice_text": "Bad"}]}]
+  }')
+POLL_ID=$(echo "$BODY" | jq -r '.id')
+RULE=$(echo "$BODY" | jq -r '.recurrence')
+echo "Created Poll ID: $POLL_ID recurring $RULE"
+if [[ "$RULE" != "FREQ=WEEKLY;BYDAY=MO;BYHOUR=9;BYMINUTE=0" ]]; then
+  echo "ERROR: Recurrence was not stored"
+  exit 1
+fi
+POLL_URL="${API_BASE_URL}/api/polls/${POLL_ID}"
+echo
+
+echo "=================================="
+echo "STEP 3: Change and clear the rule"
+echo "=================================="
+expect_code PATCH "$POLL_URL" '{"recurrence": "FREQ=DAILY;BYHOUR=12"}' 200
+RULE=$(curl -s "$POLL_URL" | jq -r '.recurrence')
+echo "Recurrence is now $RULE"
+if [[ "$RULE" != "FREQ=DAILY;BYHOUR=12" ]]; then
+  echo "ERROR: Recurrence was not changed"
+  exit 1
+fi
+expect_code PATCH "$POLL_URL" '{"recurrence": ""}' 200
+RULE=$(curl -s "$POLL_URL" | jq -r '.recurrence')
+if [[ "$RULE" != "null" ]]; then
+  echo "ERROR: Recurrence was not cleared"
+  exit 1
+fi
+expect_code PATCH "$POLL_URL" '{"recurrence": "FREQ=DAILY;TZID=Mars/Olympus"}' 422
+expect_code PATCH "$POLL_URL" '{"recurrence": "FREQ=WEEKLY;BYDAY=MO;BYHOUR=9;TZID=Europe/Paris"}' 200
+expect_code PATCH "$POLL_URL" '{"recurrence": "FREQ=WEEKLY;BYDAY=MO;BYHOUR=9;BYMINUTE=0"}' 200
+echo
+
+echo "=================================="
+echo "STEP 4: List occurrences"
+echo "=================================="
+COUNT=$(curl -s "${POLL_URL}/occurrences" | jq '.items | length')
+echo "Occurrences so far: $COUNT"
+if [[ "$COUNT" != "0" ]]; then
+  echo "ERROR: A new series should have no occurrences yet"
+  exit 1
+fi
+COUNT=$(curl -s "${API_BASE_URL}/api/polls/?series_id=${POLL_ID}" | jq '.items | length')
+if [[ "$COUNT" != "0" ]]; then
+  echo "ERROR: series_id filter should match the occurrences endpoint"
+  exit 1
+fi
+expect_code GET "${API_BASE_URL}/api/polls/999999999/occurrences" '' 404
+echo
+
+echo "=================================="
+echo "STEP 5: Delete the Poll"
+echo "=================================="
+expect_code DELETE "$POLL_URL" '' 200
+
+echo
+echo "All recurrence checks passed."
@@ -0,0 +1,79 @@
+#!/usr/bin/env bash
+#
+# test_polls_revisions.sh
+#
+# Edits a Poll and one of its questions as user 100, then checks that every
+# change shows up in the revision history with its author, and that each
+# revision shows the Poll as it was at the time. Deletes the Poll afterwards.
+
+set -euo pipefail
+
+# Adjust as needed; no trailing slash unless your API requires it.
+API_BASE_URL="http://localhost:3000"
+
+# send METHOD URL BODY sends a request as user 100.
+send() {
+  curl -s -X "$1" "$2" -H "Content-Type: application/json" \
+    -H "X-User-ID: 100" -H "If-Match: *" -d "$3"
+}
+
+echo "=================================="
+echo "STEP 1: Create and edit a Poll"
+echo "=================================="
+BODY=$(send POST "${API_BASE_URL}/api/polls/" '{
+  "title": "Sample Poll (Revisions)",
+  "created_by": 100,
+  "questions": [{"text": "Original question?", "choices": [{"choice_text": "A"}, {"choice_text": "B"}]}]
+}')
+POLL_ID=$(echo "$BODY" | jq -r '.id')
+QUESTION_ID=$(echo "$BODY" | jq -r '.questions[0].id')
+FIRST_VERSION=$(echo "$BODY" | jq -r '.version')
+POLL_URL="${API_BASE_URL}/api/polls/${POLL_ID}"
+echo "Created Poll ID: $POLL_ID at version $FIRST_VERSION"
+
+send PATCH "$POLL_URL" '{"title": "Sample Poll (Revisions, renamed)"}' >/dev/null
+send PUT "${API_BASE_URL}/api/questions/${QUESTION_ID}" '{"text": "Edited question?"}' >/dev/null
+echo
+
+echo "=================================="
+echo "STEP 2: List revisions"
+echo "=================================="
+REVISIONS=$(curl -s "${POLL_URL}/revisions")
+echo "$REVISIONS" | jq .
+ACTIONS=$(echo "$REVISIONS" | jq -r '[.items[].action] | join(",")')
+AUTHORS=$(echo "$REVISIONS" | jq -r '[.items[].author_id] | unique | join(",")')
+if [[ "$ACTIONS" != "question_edited,updated,created" ]]; then
+  echo "ERROR: Expected question_edited,updated,created, got $ACTIONS"
+  exit 1
+fi
+if [[ "$AUTHORS" != "100" ]]; then
+  echo "ERROR: Every revision should be authored by user 100, got $AUTHORS"
+  exit 1
+fi
+echo
+
+echo "=================================="
+echo "STEP 3: View the Poll as of its first revision"
+echo "=================================="
+OLD=$(curl -s "${POLL_URL}/revisions/${FIRST_VERSION}")
+TITLE=$(echo "$OLD" | jq -r '.poll.title')
+QUESTION=$(echo "$OLD" | jq -r '.poll.questions[0].text')
+echo "Revision $FIRST_VERSION: \"$TITLE\" asking \"$QUESTION\""
+if [[ "$TITLE" != "Sample Poll (Revisions)" || "$QUESTION" != "Original question?" ]]; then
+  echo "ERROR: The first revision should show the Poll as created"
+  exit 1
+fi
+CODE=$(curl -s -o /dev/null -w "%{http_code}" "${POLL_URL}/revisions/999999")
+if [[ "$CODE" != "404" ]]; then
+  echo "ERROR: Expected 404 for an unknown revision, got $CODE"
+  exit 1
+fi
+echo
+
+echo "=================================="
+echo "STEP 4: Delete the Poll"
+echo "=================================="
+send DELETE "$POLL_URL" '' | jq .
+
+echo
+echo "All revision checks passed."
@@ -0,0 +1,89 @@
+#!/usr/bin/env bash
+#
+# test_polls_slug.sh
+#
+# Creates a Poll with a generated slug and one with a vanity slug, fetches
+# both by slug, then checks that a taken slug is refused with a suggestion
+# and that a numeric slug is rejected. Deletes the Polls afterwards.
+
+set -euo pipefail
+
+# Adjust as needed; no trailing slash unless your API requires it.
+API_BASE_URL="http://localhost:3000"
+
+# A vanity slug unique to this run, so reruns do not collide.
+VANITY="sample-retro-$$"
+
+# expect_code WANT METHOD URL [BODY] fails unless the request returns WANT.
+expect_code() {
+  local code
+  code=$(curl -s -o /tmp/slug_body.json -w "%{http_code}" -X "$2" "$3" \
+    -H "Content-Type: application/json" -H "If-Match: *" -d "${4:-}")
+  echo "$2 $3 => $code"
+  if [[ "$code" != "$1" ]]; then
+    echo "ERROR: Expected $1"
+    jq . /tmp/slug_body.json || true
+    exit 1
+  fi
+}
+
+echo "=================================="
+echo "STEP 1: Create a Poll with a generated slug"
+echo "=================================="
+BODY=$(curl -s -X POST "${API_BASE_URL}/api/polls/" \
+  -H "Content-Type: application/json" \
+  -d '{"title": "Sample Poll (Generated slug)", "created_by": 100}')
+GENERATED_ID=$(echo "$BODY" | jq -r '.id')
+GENERATED_SLUG=$(echo "$BODY" | jq -r '.slug')
+echo "Created Poll ID: $GENERATED_ID with slug $GENERATED_SLUG"
+if [[ ! "$GENERATED_SLUG" =~ ^[a-z0-9]{10}$ ]]; then
+  echo "ERROR: Expected a 10 character generated slug"
+  exit 1
+fi
+expect_code 200 GET "${API_BASE_URL}/api/polls/${GENERATED_SLUG}"
+if [[ "$(jq -r '.id' /tmp/slug_body.json)" != "$GENERATED_ID" ]]; then
+  echo "ERROR: The slug resolved to another Poll"
+  exit 1
+fi
+echo
+
+echo "=================================="
+echo "STEP 2: Create a Poll with a vanity slug"
+echo "=================================="
+expect_code 200 POST "${API_BASE_URL}/api/polls/" \
+  '{"title": "Sample Poll (Vanity slug)", "created_by": 100, "slug": "'"$VANITY"'"}'
+VANITY_ID=$(jq -r '.id' /tmp/slug_body.json)
+expect_code 200 GET "${API_BASE_URL}/api/polls/${VANITY}"
+expect_code 200 GET "${API_BASE_URL}/api/polls/${VANITY}/revisions"
+echo
+
+echo "=================================="
+echo "STEP 3: Refuse the same slug for another Poll"
+echo "=================================="
+expect_code 409 PATCH "${API_BASE_URL}/api/polls/${GENERATED_ID}" '{"slug": "'"$VANITY"'"}'
+SUGGESTION=$(jq -r '.error.message' /tmp/slug_body.json)
+echo "$SUGGESTION"
+if [[ "$SUGGESTION" != *"${VANITY}-2"* ]]; then
+  echo "ERROR: Expected ${VANITY}-2 to be suggested"
+  exit 1
+fi
+expect_code 200 PATCH "${API_BASE_URL}/api/polls/${GENERATED_ID}" '{"slug": "'"${VANITY}-2"'"}'
+expect_code 200 GET "${API_BASE_URL}/api/polls/${VANITY}-2"
+echo
+
+echo "=================================="
+echo "STEP 4: Reject slugs that look like IDs or are unknown"
+echo "=================================="
+expect_code 422 PATCH "${API_BASE_URL}/api/polls/${VANITY_ID}" '{"slug": "12345"}'
+expect_code 404 GET "${API_BASE_URL}/api/polls/no-such-slug-$$"
+echo
+
+echo "=================================="
+echo "STEP 5: Delete both Polls"
+echo "=================================="
+for ID in "$GENERATED_ID" "$VANITY_ID"; do
+  curl -s -X DELETE "${API_BASE_URL}/api/polls/${ID}" -H "If-Match: *" | jq .
+done
+
+echo
+echo "All slug checks passed."
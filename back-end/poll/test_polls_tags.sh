@@ -0,0 +1,115 @@
+#!/usr/bin/env bash
+#
+# test_polls_tags.sh
+#
+# Tags two Polls, checks that tags are normalized, that tagging twice is a
+# no-op, that tagging needs the Poll's current ETag, that listings filter by
+# one or several tags and that the tag cloud counts every use. Untags one
+# Poll, then deletes both Polls.
+
+set -euo pipefail
+
+# Adjust as needed; no trailing slash unless your API requires it.
+API_BASE_URL="http://localhost:3000"
+
+# Tags unique to this run, so the counts below are exact on a shared database.
+TAG_A="sample-a-$$"
+TAG_B="sample-b-$$"
+
+# etag_of prints the ETag header of a GET on the given URL.
+etag_of() {
+  curl -s -D - -o /dev/null "$1" | tr -d '\r' | sed -n 's/^[Ee][Tt]ag: //p'
+}
+
+# tag METHOD POLL_ID TAG sends the Poll's current ETag, prints the response
+# body and fails unless it is 200.
+tag() {
+  local code etag
+  etag=$(etag_of "${API_BASE_URL}/api/polls/$2")
+  code=$(curl -s -o /tmp/tags_body.json -w "%{http_code}" -X "$1" \
+    "${API_BASE_URL}/api/polls/$2/tags/$3" -H "X-User-ID: 100" -H "If-Match: ${etag}")
+  echo "$1 /api/polls/$2/tags/$3 => $code" >&2
+  if [[ "$code" != "200" ]]; then
+    echo "ERROR: Expected 200" >&2
+    jq . /tmp/tags_body.json >&2 || true
+    exit 1
+  fi
+  cat /tmp/tags_body.json
+}
+
+# check LABEL GOT WANT fails unless GOT equals WANT.
+check() {
+  if [[ "$2" != "$3" ]]; then
+    echo "ERROR: $1 is '$2', expected '$3'"
+    exit 1
+  fi
+  echo "$1: $2"
+}
+
+echo "=================================="
+echo "STEP 1: Create two Polls"
+echo "=================================="
+FIRST_ID=$(curl -s -X POST "${API_BASE_URL}/api/polls/" -H "Content-Type: application/json" \
+  -d '{"title": "Sample Poll (Tags, first)", "created_by": 100}' | jq -r '.id')
+SECOND_ID=$(curl -s -X POST "${API_BASE_URL}/api/polls/" -H "Content-Type: application/json" \
+  -d '{"title": "Sample Poll (Tags, second)", "created_by": 100}' | jq -r '.id')
+echo "Created Poll IDs: $FIRST_ID, $SECOND_ID"
+echo
+
+echo "=================================="
+echo "STEP 2: Tag them"
+echo "=================================="
+UPPER_A=$(echo "$TAG_A" | tr '[:lower:]' '[:upper:]')
+BODY=$(tag PUT "$FIRST_ID" "$UPPER_A")
+check "first Poll tags" "$(echo "$BODY" | jq -r '.tags | join(",")')" "$TAG_A"
+VERSION=$(echo "$BODY" | jq -r '.version')
+BODY=$(tag PUT "$FIRST_ID" "$TAG_A")
+check "version after tagging twice" "$(echo "$BODY" | jq -r '.version')" "$VERSION"
+tag PUT "$FIRST_ID" "$TAG_B" >/dev/null
+tag PUT "$SECOND_ID" "$TAG_A" >/dev/null
+CODE=$(curl -s -o /dev/null -w "%{http_code}" -X PUT -H "If-Match: *" "${API_BASE_URL}/api/polls/${FIRST_ID}/tags/no%20%20--ok")
+check "invalid tag status" "$CODE" "422"
+CODE=$(curl -s -o /dev/null -w "%{http_code}" -X PUT "${API_BASE_URL}/api/polls/${FIRST_ID}/tags/${TAG_B}")
+check "status without If-Match" "$CODE" "428"
+CODE=$(curl -s -o /dev/null -w "%{http_code}" -X PUT -H "If-Match: \"v1.${VERSION}\"" \
+  "${API_BASE_URL}/api/polls/${FIRST_ID}/tags/${TAG_B}")
+check "status with a stale ETag" "$CODE" "412"
+echo
+
+echo "=================================="
+echo "STEP 3: Filter listings by tag"
+echo "=================================="
+IDS=$(curl -s "${API_BASE_URL}/api/polls/?tag=${TAG_A}&sort=created_at" | jq -r '[.items[].id] | join(",")')
+check "polls tagged ${TAG_A}" "$IDS" "${FIRST_ID},${SECOND_ID}"
+IDS=$(curl -s "${API_BASE_URL}/api/polls/?tag=${TAG_A}&tag=${TAG_B}" | jq -r '[.items[].id] | join(",")')
+check "polls tagged ${TAG_A} and ${TAG_B}" "$IDS" "$FIRST_ID"
+echo
+
+echo "=================================="
+echo "STEP 4: Count uses in the tag cloud"
+echo "=================================="
+CLOUD=$(curl -s "${API_BASE_URL}/api/tags/?limit=200")
+check "uses of ${TAG_A}" "$(echo "$CLOUD" | jq -r --arg t "$TAG_A" '.items[] | select(.name == $t) | .count')" "2"
+check "uses of ${TAG_B}" "$(echo "$CLOUD" | jq -r --arg t "$TAG_B" '.items[] | select(.name == $t) | .count')" "1"
+echo
+
+echo "=================================="
+echo "STEP 5: Untag the second Poll"
+echo "=================================="
+BODY=$(tag DELETE "$SECOND_ID" "$TAG_A")
+check "second Poll tags" "$(echo "$BODY" | jq -r '.tags | length')" "0"
+CODE=$(curl -s -o /dev/null -w "%{http_code}" -X DELETE -H "If-Match: *" "${API_BASE_URL}/api/polls/${SECOND_ID}/tags/${TAG_A}")
+check "untagging again status" "$CODE" "404"
+ACTION=$(curl -s "${API_BASE_URL}/api/polls/${SECOND_ID}/revisions" | jq -r '.items[0].action')
+check "latest revision" "$ACTION" "untagged"
+echo
+
+echo "=================================="
+echo "STEP 6: Delete both Polls"
+echo "=================================="
+for ID in "$FIRST_ID" "$SECOND_ID"; do
+  curl -s -X DELETE "${API_BASE_URL}/api/polls/${ID}" -H "If-Match: *" | jq .
+done
+
+echo
+echo "All tag checks passed."
@@ -0,0 +1,77 @@
+#!/usr/bin/env bash
+#
+# test_polls_trash.sh
+#
+# Deletes a Poll and checks that it disappears from GET but shows up in
+# /api/polls/trash, then restores it and checks that it is back, questions
+# included. Deletes the Poll again at the end; it stays in the trash until
+# the purge removes it.
+
+set -euo pipefail
+
+# Adjust as needed; no trailing slash unless your API requires it.
+API_BASE_URL="http://localhost:3000"
+
+echo "=================================="
+echo "STEP 1: Create a Poll with a Question"
+echo "=================================="
+BODY=$(curl -s -X POST "${API_BASE_URL}/api/polls/" \
+  -H "Content-Type: application/json" \
+  -d '{"title": "Sample Poll (Trash)", "created_by": 100, "questions": [{"text": "Keep me?"}]}')
+POLL_ID=$(echo "$BODY" | jq -r '.id')
+if [[ -z "$POLL_ID" || "$POLL_ID" == "null" ]]; then
+  echo "ERROR: Poll creation failed"
+  echo "$BODY"
+  exit 1
+fi
+echo "Created Poll ID: $POLL_ID"
+echo
+
+echo "=================================="
+echo "STEP 2: Delete the Poll"
+echo "=================================="
+HTTP_CODE=$(curl -s -o /dev/null -w "%{http_code}" -X DELETE -H "If-Match: *" "${API_BASE_URL}/api/polls/${POLL_ID}")
+echo "HTTP code: $HTTP_CODE"
+if [[ "$HTTP_CODE" != "200" ]]; then
+  echo "ERROR: Could not delete poll with ID: $POLL_ID"
+  exit 1
+fi
+HTTP_CODE=$(curl -s -o /dev/null -w "%{http_code}" "${API_BASE_URL}/api/polls/${POLL_ID}")
+echo "GET after delete: $HTTP_CODE"
+if [[ "$HTTP_CODE" != "404" ]]; then
+  echo "ERROR: Trashed poll is still visible"
+  exit 1
+fi
+FOUND=$(curl -s "${API_BASE_URL}/api/polls/trash?limit=200" | jq --argjson id "$POLL_ID" '[.items[] | select(.id == $id)] | length')
+echo "Found in trash: $FOUND"
+if [[ "$FOUND" != "1" ]]; then
+  echo "ERROR: Poll is not listed in the trash"
+  exit 1
+fi
+echo
+
+echo "=================================="
+echo "STEP 3: Restore the Poll"
+echo "=================================="
+BODY=$(curl -s -w "\nHTTP_CODE:%{http_code}" -X POST "${API_BASE_URL}/api/polls/${POLL_ID}/restore")
+HTTP_CODE=$(echo "$BODY" | sed -n 's/.*HTTP_CODE:\([0-9]*\).*/\1/p')
+QUESTIONS=$(echo "$BODY" | sed -e '/HTTP_CODE:/d' | jq '.questions | length')
+echo "HTTP code: $HTTP_CODE, questions: $QUESTIONS"
+if [[ "$HTTP_CODE" != "200" || "$QUESTIONS" != "1" ]]; then
+  echo "ERROR: Poll was not restored with its question"
+  exit 1
+fi
+echo
+
+echo "=================================="
+echo "STEP 4: Delete the Poll again"
+echo "=================================="
+HTTP_CODE=$(curl -s -o /dev/null -w "%{http_code}" -X DELETE -H "If-Match: *" "${API_BASE_URL}/api/polls/${POLL_ID}")
+echo "HTTP code: $HTTP_CODE"
+if [[ "$HTTP_CODE" != "200" ]]; then
+  echo "ERROR: Could not delete poll with ID: $POLL_ID"
+  exit 1
+fi
+
+echo
+echo "All trash checks passed."
@@ -0,0 +1,100 @@
+#!/usr/bin/env bash
+#
+# test_templates.sh
+#
+# Lists the template catalog, creates a Poll from a built-in template with
+# its parameters filled in, and publishes, uses and deletes a template of
+# organization 1. The organization steps need user 100 to be a member of
+# organization 1 (see the commented inserts at the end of database/fly.sql)
+# and are skipped otherwise. Deletes the Polls it creates.
+
+set -euo pipefail
+
+# Adjust as needed; no trailing slash unless your API requires it.
+API_BASE_URL="http://localhost:3000"
+USER_ID=100
+
+# expect_code METHOD URL BODY WANT runs a request as user 100 and fails
+# unless it answers with HTTP status WANT.
+expect_code() {
+  local code
+  code=$(curl -s -o /dev/null -w "%{http_code}" -X "$1" "$2" \
+    -H "Content-Type: application/json" -H "X-User-ID: ${USER_ID}" -d "$3")
+  echo "$1 $2 => $code"
+  if [[ "$code" != "$4" ]]; then
+    echo "ERROR: Expected $4"
+    exit 1
+  fi
+}
+
+echo "=================================="
+echo "STEP 1: List the catalog"
+echo "=================================="
+IDS=$(curl -s "${API_BASE_URL}/api/templates/" | jq -r '[.items[].id] | join(",")')
+echo "Templates: $IDS"
+for ID in nps meeting motion; do
+  if [[ ",$IDS," != *",$ID,"* ]]; then
+    echo "ERROR: Built-in template $ID is missing"
+    exit 1
+  fi
+done
+echo
+
+echo "=================================="
+echo "STEP 2: Create a Poll from the meeting template"
+echo "=================================="
+expect_code POST "${API_BASE_URL}/api/templates/meeting/polls" '{"params": {"topic": "Budget"}}' 422
+expect_code POST "${API_BASE_URL}/api/templates/meeting/polls" '{"params": {"topic": "Budget", "slots": ["Mon"], "nope": 1}}' 422
+POLL=$(curl -s -X POST "${API_BASE_URL}/api/templates/meeting/polls" \
+  -H "Content-Type: application/json" \
+  -H "X-User-ID: ${USER_ID}" \
+  -d '{"params": {"topic": "Budget", "slots": ["Mon 10:00", "Tue 14:00", "Wed 09:30"]}}')
+echo "$POLL" | jq .
+POLL_ID=$(echo "$POLL" | jq -r '.id')
+TITLE=$(echo "$POLL" | jq -r '.title')
+CHOICES=$(echo "$POLL" | jq -r '[.questions[0].choices[].choice_text] | join(",")')
+if [[ "$TITLE" != "When should we meet about Budget?" || "$CHOICES" != "Mon 10:00,Tue 14:00,Wed 09:30" ]]; then
+  echo "ERROR: Parameters were not filled in"
+  exit 1
+fi
+expect_code DELETE "${API_BASE_URL}/api/templates/meeting" '' 403
+echo
+
+echo "=================================="
+echo "STEP 3: Publish an organization template"
+echo "=================================="
+TEMPLATE=$(curl -s -X POST "${API_BASE_URL}/api/templates/" \
+  -H "Content-Type: application/json" \
+  -H "X-User-ID: ${USER_ID}" \
+  -d '{
+    "name": "Sample Template (Lunch)",
+    "organization_id": 1,
+    "parameters": [{"name": "day", "label": "Day of the lunch", "required": true}],
+    "title": "Lunch on {{day}}",
+    "duration_days": 1,
+    "questions": [{"text": "Where shall we eat on {{day}}?", "choices": [{"choice_text": "Pizza"}, {"choice_text": "Sushi"}]}]
+  }')
+TEMPLATE_ID=$(echo "$TEMPLATE" | jq -r '.id // empty')
+if [[ -z "$TEMPLATE_ID" ]]; then
+  echo "Skipping organization steps: $(echo "$TEMPLATE" | jq -r '.error.message')"
+else
+  echo "Published template ID: $TEMPLATE_ID"
+  expect_code GET "${API_BASE_URL}/api/templates/${TEMPLATE_ID}" '' 200
+  ORG_POLL_ID=$(curl -s -X POST "${API_BASE_URL}/api/templates/${TEMPLATE_ID}/polls" \
+    -H "Content-Type: application/json" \
+    -H "X-User-ID: ${USER_ID}" \
+    -d '{"params": {"day": "Friday"}}' | jq -r '.id')
+  echo "Created Poll ID: $ORG_POLL_ID from it"
+  curl -s -o /dev/null -X DELETE "${API_BASE_URL}/api/polls/${ORG_POLL_ID}" -H "If-Match: *"
+  expect_code DELETE "${API_BASE_URL}/api/templates/${TEMPLATE_ID}" '' 200
+  expect_code GET "${API_BASE_URL}/api/templates/${TEMPLATE_ID}" '' 404
+fi
+echo
+
+echo "=================================="
+echo "STEP 4: Delete the Poll"
+echo "=================================="
+curl -s -X DELETE "${API_BASE_URL}/api/polls/${POLL_ID}" -H "If-Match: *" | jq .
+
+echo
+echo "All template checks passed."
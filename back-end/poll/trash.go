@@ -0,0 +1,86 @@
+package poll
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// DefaultTrashRetention is how long a deleted poll stays in the trash before
+// PurgeTrash removes it for good.
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+// liveRow is, per table, the condition a row must meet to be visible: polls
+// must not be in the trash, and questions and choices must not belong to a
+// poll that is. Conditions refer to the table without an alias.
+var liveRow = map[string]string{
+	"polls":     "deleted_at IS NULL",
+	"questions": "poll_id IN (SELECT id FROM polls WHERE deleted_at IS NULL)",
+	"choices": `question_id IN (
+		SELECT q.id FROM questions q JOIN polls p ON p.id = q.poll_id WHERE p.deleted_at IS NULL)`,
+}
+
+// liveCondition returns " AND <condition>" for tables in liveRow, and an
+// empty string for the others.
+func liveCondition(table string) string {
+	if cond, ok := liveRow[table]; ok {
+		return " AND " + cond
+	}
+	return ""
+}
+
+// RestorePoll takes a poll out of the trash. It returns ErrNotFound when no
+// trashed poll has the ID.
+func RestorePoll(db *sql.DB, pollID, author int64) error {
+	return withTx(db, func(tx *sql.Tx) error {
+		result, err := tx.Exec(`
+            UPDATE polls
+            SET deleted_at = NULL, version = version + 1
+            WHERE id = ? AND deleted_at IS NOT NULL
+        `, pollID)
+		if err != nil {
+			return fmt.Errorf("RestorePoll: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return fmt.Errorf("no trashed poll restored; poll %w", ErrNotFound)
+		}
+		return recordRevision(tx, pollID, author, RevisionRestored)
+	})
+}
+
+// PurgeTrash permanently deletes polls trashed before cutoff, together with
+// their questions, choices and votes, and returns how many polls went.
+func PurgeTrash(db *sql.DB, cutoff time.Time) (int64, error) {
+	result, err := db.Exec("DELETE FROM polls WHERE deleted_at < ?", cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("PurgeTrash: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return n, nil
+}
+
+// PurgeTrashEvery calls PurgeTrash every interval, purging polls that have
+// been in the trash longer than retention, until ctx is done.
+// Example usage:
+//
+//	go poll.PurgeTrashEvery(ctx, db, poll.DefaultTrashRetention, time.Hour)
+func PurgeTrashEvery(ctx context.Context, db *sql.DB, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		n, err := PurgeTrash(db, time.Now().Add(-retention))
+		if err != nil {
+			log.Printf("Error purging trash: %v", err)
+		} else if n > 0 {
+			log.Printf("Purged %d poll(s) from the trash", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package poll
+
+import (
+	"fmt"
+	"strings"
+)
+
+// loadQuestions fills in the questions of every poll, in ballot order, with
+// one query for all of them. withChoices loads their choices as well, with
+// one more query. Polls without questions get an empty list.
+func loadQuestions(db dbtx, polls []Poll, withChoices bool) error {
+	if len(polls) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(polls))
+	index := make(map[int64]int, len(polls)) // poll ID => index in polls
+	for i := range polls {
+		ids[i] = polls[i].ID
+		index[polls[i].ID] = i
+		polls[i].Questions = []Question{}
+	}
+
+	in, args := inClause(ids)
+	rows, err := db.Query(`
+		SELECT id, poll_id, question_text, position, version
+		FROM questions
+		WHERE poll_id IN (`+in+`)
+		ORDER BY poll_id, position, id`, args...)
+	if err != nil {
+		return fmt.Errorf("loadQuestions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var q Question
+		if err := rows.Scan(&q.ID, &q.PollID, &q.Text, &q.Position, &q.Version); err != nil {
+			return fmt.Errorf("loadQuestions scan: %w", err)
+		}
+		i := index[q.PollID]
+		polls[i].Questions = append(polls[i].Questions, q)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("loadQuestions: %w", err)
+	}
+	rows.Close()
+
+	if !withChoices {
+		return nil
+	}
+	// Take pointers only now that no append can move the questions.
+	var questions []*Question
+	for i := range polls {
+		for j := range polls[i].Questions {
+			questions = append(questions, &polls[i].Questions[j])
+		}
+	}
+	// Selecting by poll keeps the IN list short however many questions there are.
+	return loadChoices(db, questions, "q.poll_id", ids)
+}
+
+// loadChoices fills in the choices of questions, in ballot order, with one
+// query selecting every choice whose column (q.poll_id or c.question_id) is
+// in ids. Questions without choices get an empty list.
+func loadChoices(db dbtx, questions []*Question, column string, ids []int64) error {
+	if len(questions) == 0 {
+		return nil
+	}
+	index := make(map[int64]*Question, len(questions))
+	for _, q := range questions {
+		index[q.ID] = q
+		q.Choices = []Choice{}
+	}
+
+	in, args := inClause(ids)
+	rows, err := db.Query(`
+		SELECT c.id, c.question_id, c.choice_text, c.position, c.version
+		FROM choices c
+		JOIN questions q ON q.id = c.question_id
+		WHERE `+column+` IN (`+in+`)
+		ORDER BY c.question_id, c.position, c.id`, args...)
+	if err != nil {
+		return fmt.Errorf("loadChoices: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c Choice
+		if err := rows.Scan(&c.ID, &c.QuestionID, &c.Text, &c.Position, &c.Version); err != nil {
+			return fmt.Errorf("loadChoices scan: %w", err)
+		}
+		// A question created after its siblings were read has no entry; it
+		// belongs to the next read.
+		if q, ok := index[c.QuestionID]; ok {
+			q.Choices = append(q.Choices, c)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("loadChoices: %w", err)
+	}
+	return nil
+}
+
+// inClause returns the placeholders and arguments for an IN (...) list.
+func inClause(ids []int64) (string, []interface{}) {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", "), args
+}
@@ -0,0 +1,315 @@
+package poll
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"simple-poll/apierror"
+)
+
+// Length limits, in characters, for user-provided text.
+const (
+	maxTitleLen        = 255 // polls.title is VARCHAR(255)
+	maxDescriptionLen  = 5000
+	maxQuestionTextLen = 1000
+	maxChoiceTextLen   = 500
+	maxRecurrenceLen   = 255 // polls.recurrence is VARCHAR(255)
+	maxTemplateNameLen = 255 // poll_templates.name is VARCHAR(255)
+	maxParamLabelLen   = 255
+	maxDurationDays    = 365
+	maxTagLen          = 50 // tags.name is VARCHAR(50)
+)
+
+// validator collects every violation in a payload so clients can fix them
+// all at once instead of one round trip per mistake.
+type validator struct {
+	details []apierror.FieldError
+	err     error
+}
+
+func (v *validator) fail(field, format string, args ...interface{}) {
+	v.details = append(v.details, apierror.FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// text checks that a string field is present and at most max characters long.
+func (v *validator) text(field, value string, max int) {
+	if strings.TrimSpace(value) == "" {
+		v.fail(field, "is required")
+		return
+	}
+	if n := utf8.RuneCountInString(value); n > max {
+		v.fail(field, "must be at most %d characters, got %d", max, n)
+	}
+}
+
+// optionalText checks the length of a string field that may be empty.
+func (v *validator) optionalText(field, value string, max int) {
+	if n := utf8.RuneCountInString(value); n > max {
+		v.fail(field, "must be at most %d characters, got %d", max, n)
+	}
+}
+
+// parent checks that id refers to an existing row of table. Lookups are only
+// made for positive IDs; zero and negative IDs are reported as missing.
+func (v *validator) parent(db dbtx, field, table string, id int64) {
+	if id <= 0 {
+		v.fail(field, "is required")
+		return
+	}
+	ok, err := rowExists(db, table, id)
+	if err != nil {
+		v.err = err
+		return
+	}
+	if !ok {
+		v.fail(field, "%s %d does not exist", strings.TrimSuffix(table, "s"), id)
+	}
+}
+
+// failed reports whether field already has a violation.
+func (v *validator) failed(field string) bool {
+	for _, d := range v.details {
+		if d.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// result returns nil when the payload is valid and a 422 listing every
+// violation otherwise. A failed parent lookup is reported as a 500.
+func (v *validator) result() *apierror.Error {
+	if v.err != nil {
+		return apierror.Internal(v.err, "Failed to validate request")
+	}
+	if len(v.details) == 0 {
+		return nil
+	}
+	return apierror.Validation("Request validation failed", v.details...)
+}
+
+// rowExists reports whether table has a visible row with the given id; rows
+// of trashed polls do not count. table must be a trusted identifier, never
+// user input.
+func rowExists(db dbtx, table string, id int64) (bool, error) {
+	var one int
+	err := db.QueryRow("SELECT 1 FROM "+table+" WHERE id = ?"+liveCondition(table), id).Scan(&one)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("rowExists %s: %w", table, err)
+	}
+	return true, nil
+}
+
+// pollFields checks the editable fields of a poll.
+func (v *validator) pollFields(p *Poll) {
+	v.text("title", p.Title, maxTitleLen)
+	v.optionalText("description", p.Description, maxDescriptionLen)
+	if p.Slug != "" {
+		// Slugs are case-insensitive in URLs people type.
+		p.Slug = strings.ToLower(strings.TrimSpace(p.Slug))
+		if err := checkVanitySlug(p.Slug); err != nil {
+			v.fail("slug", "%v", err)
+		}
+	}
+	if p.StartDate != nil && p.EndDate != nil && !p.EndDate.After(*p.StartDate) {
+		v.fail("end_date", "must be after start_date")
+	}
+	if p.Recurrence != nil {
+		v.recurrence(p)
+	}
+}
+
+// recurrence checks the rule of a recurring poll. An empty rule means the
+// poll does not recur and is stored as NULL.
+func (v *validator) recurrence(p *Poll) {
+	rule := strings.TrimSpace(*p.Recurrence)
+	if rule == "" {
+		p.Recurrence = nil
+		return
+	}
+	if n := utf8.RuneCountInString(rule); n > maxRecurrenceLen {
+		v.fail("recurrence", "must be at most %d characters, got %d", maxRecurrenceLen, n)
+		return
+	}
+	if _, err := ParseRecurrence(rule); err != nil {
+		v.fail("recurrence", "%v", err)
+		return
+	}
+	p.Recurrence = &rule
+}
+
+// validateNewPoll checks a poll about to be created, including its owner and
+// any nested questions and choices. Fields are named the way api spells them.
+func validateNewPoll(db dbtx, p *Poll, api *APIVersion) *apierror.Error {
+	var v validator
+	v.pollFields(p)
+	v.parent(db, "created_by", "users", p.CreatedBy)
+	for i, q := range p.Questions {
+		prefix := fmt.Sprintf("questions[%d].", i)
+		v.text(prefix+"text", q.Text, maxQuestionTextLen)
+		for j, c := range q.Choices {
+			v.text(fmt.Sprintf("%schoices[%d].%s", prefix, j, api.choiceText()), c.Text, maxChoiceTextLen)
+		}
+	}
+	return v.result()
+}
+
+// validatePollUpdate checks the fields UpdatePoll writes.
+func validatePollUpdate(p *Poll) *apierror.Error {
+	var v validator
+	v.pollFields(p)
+	return v.result()
+}
+
+// validateClone checks the options of a clone and that the caller, who will
+// own the copy, exists.
+func validateClone(db dbtx, owner int64, req *CloneRequest) *apierror.Error {
+	var v validator
+	v.optionalText("title", req.Title, maxTitleLen)
+	if req.StartDate != nil && req.ShiftDays != 0 {
+		v.fail("shift_days", "cannot be combined with start_date")
+	}
+	v.parent(db, UserIDHeader, "users", owner)
+	return v.result()
+}
+
+// validateTemplate checks a template an organization publishes: its text,
+// its parameters, and that every {{name}} it refers to is a parameter.
+// Lengths of the poll fields are checked again once parameters are filled in.
+func validateTemplate(db dbtx, t *Template, api *APIVersion) *apierror.Error {
+	var v validator
+	v.text("name", t.Name, maxTemplateNameLen)
+	v.optionalText("description", t.Description, maxDescriptionLen)
+	if t.OrganizationID == nil {
+		v.fail("organization_id", "is required")
+	} else {
+		v.parent(db, "organization_id", "organizations", *t.OrganizationID)
+	}
+	v.text("title", t.Title, maxTitleLen)
+	v.optionalText("poll_description", t.PollDescription, maxDescriptionLen)
+	if t.DurationDays < 0 || t.DurationDays > maxDurationDays {
+		v.fail("duration_days", "must be between 0 and %d", maxDurationDays)
+	}
+
+	declared := make(map[string]bool)
+	for i, p := range t.Parameters {
+		prefix := fmt.Sprintf("parameters[%d].", i)
+		switch {
+		case !paramName.MatchString(p.Name):
+			v.fail(prefix+"name", "must be lower case letters, digits and underscores")
+		case declared[p.Name]:
+			v.fail(prefix+"name", "%q is declared twice", p.Name)
+		}
+		declared[p.Name] = true
+		v.optionalText(prefix+"label", p.Label, maxParamLabelLen)
+		if p.Type != "" && p.Type != ParamText && p.Type != ParamList {
+			v.fail(prefix+"type", "must be %s or %s", ParamText, ParamList)
+		} else if len(p.Default) > 0 {
+			if _, err := decodeParam(p, p.Default); err != nil {
+				v.fail(prefix+"default", "%v", err)
+			}
+		}
+	}
+
+	if len(t.Questions) == 0 {
+		v.fail("questions", "must contain at least one question")
+	}
+	for i, q := range t.Questions {
+		prefix := fmt.Sprintf("questions[%d].", i)
+		v.text(prefix+"text", q.Text, maxQuestionTextLen)
+		if len(q.Choices) == 0 {
+			v.fail(prefix+"choices", "must contain at least one choice")
+		}
+		for j, c := range q.Choices {
+			v.text(fmt.Sprintf("%schoices[%d].%s", prefix, j, api.choiceText()), c.Text, maxChoiceTextLen)
+		}
+	}
+	for name, field := range t.placeholders(api) {
+		if !declared[name] {
+			v.fail(field, "refers to {{%s}}, which is not a parameter", name)
+		}
+	}
+	return v.result()
+}
+
+// validateTemplateParams checks the parameters sent to create a poll from t
+// and returns them with defaults filled in.
+func validateTemplateParams(t *Template, params map[string]json.RawMessage) (map[string]paramValue, *apierror.Error) {
+	var v validator
+	values := make(map[string]paramValue)
+	for _, p := range t.Parameters {
+		raw, ok := params[p.Name]
+		if !ok || string(raw) == "null" {
+			raw = p.Default
+		}
+		if len(raw) == 0 {
+			if p.Required {
+				v.fail("params."+p.Name, "is required")
+			}
+			values[p.Name] = paramValue{}
+			continue
+		}
+		value, err := decodeParam(p, raw)
+		if err != nil {
+			v.fail("params."+p.Name, "%v", err)
+			continue
+		}
+		if p.Required && strings.TrimSpace(value.text) == "" && len(value.list) == 0 {
+			v.fail("params."+p.Name, "is required")
+		}
+		values[p.Name] = value
+	}
+	for name := range params {
+		if _, ok := values[name]; !ok && !v.failed("params."+name) {
+			v.fail("params."+name, "is not a parameter of this template")
+		}
+	}
+	return values, v.result()
+}
+
+// validateQuestion checks a question; the parent poll is only checked when
+// checkParent is set, since updates cannot move a question.
+func validateQuestion(db dbtx, q *Question, checkParent bool) *apierror.Error {
+	var v validator
+	v.text("text", q.Text, maxQuestionTextLen)
+	if checkParent {
+		v.parent(db, "poll_id", "polls", q.PollID)
+	}
+	return v.result()
+}
+
+// validateChoice checks a choice; the parent question is only checked when
+// checkParent is set, since updates cannot move a choice.
+func validateChoice(db dbtx, c *Choice, checkParent bool, api *APIVersion) *apierror.Error {
+	var v validator
+	v.text(api.choiceText(), c.Text, maxChoiceTextLen)
+	if checkParent {
+		v.parent(db, "question_id", "questions", c.QuestionID)
+	}
+	return v.result()
+}
+
+// validTag reports whether a normalized tag may be stored.
+func validTag(tag string) bool {
+	return utf8.RuneCountInString(tag) <= maxTagLen && tagName.MatchString(tag)
+}
+
+// validateTag normalizes the tag named in a tagging request and checks it.
+func validateTag(tag *string) *apierror.Error {
+	var v validator
+	*tag = normalizeTag(*tag)
+	if *tag == "" {
+		v.fail("tag", "is required")
+	} else if n := utf8.RuneCountInString(*tag); n > maxTagLen {
+		v.fail("tag", "must be at most %d characters, got %d", maxTagLen, n)
+	} else if !tagName.MatchString(*tag) {
+		v.fail("tag", "may only contain letters and digits, with single spaces or hyphens between words")
+	}
+	return v.result()
+}
@@ -0,0 +1,252 @@
+package poll
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// APIVersion is one public representation of the API. Every version is served
+// by the same handlers and data functions; a version only changes how JSON
+// bodies look on the wire.
+//
+// Compatibility guarantees: once a version is published its field names and
+// meanings are frozen. It may gain new optional fields, but nothing is renamed
+// or removed. Representation changes go into a new version instead.
+type APIVersion struct {
+	// Name is the path segment, e.g. "v1" for /api/v1.
+	Name string
+
+	// renames maps, per Go type, a JSON field name used by the type (which is
+	// the v1 name) to the name this version uses instead.
+	renames fieldRenames
+	// reverse is renames inverted, for decoding request bodies.
+	reverse fieldRenames
+
+	specOnce sync.Once
+	spec     map[string]interface{}
+}
+
+// V1 is the original representation. The unversioned /api/... routes serve it
+// too, so existing clients keep working.
+var V1 = newAPIVersion("v1", nil)
+
+// V2 uses a consistent "text" field for both questions and choices.
+var V2 = newAPIVersion("v2", fieldRenames{
+	choiceType: {"choice_text": "text"},
+})
+
+var choiceType = reflect.TypeOf(Choice{})
+
+// Versions lists every served version, oldest first.
+var Versions = []*APIVersion{V1, V2}
+
+// fieldRenames maps a struct type to the JSON fields renamed within it.
+type fieldRenames map[reflect.Type]map[string]string
+
+func newAPIVersion(name string, renames fieldRenames) *APIVersion {
+	v := &APIVersion{Name: name, renames: renames, reverse: make(fieldRenames)}
+	for t, fields := range renames {
+		v.reverse[t] = make(map[string]string)
+		for from, to := range fields {
+			v.reverse[t][to] = from
+		}
+	}
+	return v
+}
+
+// BasePath is the path prefix the version is mounted at.
+func (v *APIVersion) BasePath() string {
+	return "/api/" + v.Name
+}
+
+// fieldName returns the name this version uses for a JSON field of type t.
+func (v *APIVersion) fieldName(t reflect.Type, name string) string {
+	if renamed, ok := v.renames[t][name]; ok {
+		return renamed
+	}
+	return name
+}
+
+// choiceText is the name this version uses for Choice.Text, for validation
+// details that point at it.
+func (v *APIVersion) choiceText() string {
+	return v.fieldName(choiceType, "choice_text")
+}
+
+type versionKey struct{}
+
+// WithVersion makes the handlers in h read and write the representation of v.
+// Example usage:
+//
+//	mux.Handle("/api/v2/polls/", http.StripPrefix("/api/v2/polls", WithVersion(V2, PollRouter(db, voteBase))))
+func WithVersion(v *APIVersion, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-API-Version", v.Name)
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), versionKey{}, v)))
+	})
+}
+
+// versionOf returns the version a request was routed to, V1 by default.
+func versionOf(r *http.Request) *APIVersion {
+	if v, ok := r.Context().Value(versionKey{}).(*APIVersion); ok {
+		return v
+	}
+	return V1
+}
+
+// writeJSON encodes data in the representation of the request's version.
+func writeJSON(w http.ResponseWriter, r *http.Request, data interface{}) {
+	out, err := encodeJSON(r, data)
+	if err != nil {
+		writeError(w, storageError(err, "Failed to encode response"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(out, '\n'))
+}
+
+// encodeJSON marshals data in the representation of the request's version.
+func encodeJSON(r *http.Request, data interface{}) ([]byte, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	v := versionOf(r)
+	if len(v.renames) == 0 {
+		return b, nil
+	}
+	return renameFields(b, reflect.TypeOf(data), v.renames, false)
+}
+
+// decodeJSON decodes the request body, written in the representation of the
+// request's version, into dst.
+func decodeJSON(r *http.Request, dst interface{}) error {
+	v := versionOf(r)
+	if len(v.reverse) == 0 {
+		return json.NewDecoder(r.Body).Decode(dst)
+	}
+
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	in, err := renameFields(b, reflect.TypeOf(dst), v.reverse, true)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(in, dst)
+}
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// renameFields rewrites object keys of a JSON document that encodes a value
+// of type t. Keys are only renamed inside objects of the types listed in
+// renames, so a choice's "text" never collides with a question's. With
+// incoming set, keys are renamed before descending, since the document uses
+// the version's names and t uses the Go names; the old name of a renamed field
+// is not part of the version, so an incoming key spelled that way is dropped
+// like any other unknown field. Values are never touched, and numbers keep
+// their exact text.
+func renameFields(doc []byte, t reflect.Type, renames fieldRenames, incoming bool) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	var tree interface{}
+	if err := dec.Decode(&tree); err != nil {
+		return nil, err
+	}
+	return json.Marshal(renameTree(tree, t, renames, incoming))
+}
+
+func renameTree(node interface{}, t reflect.Type, renames fieldRenames, incoming bool) interface{} {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType) {
+		return node
+	}
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		switch t.Kind() {
+		case reflect.Struct:
+			fields := renames[t]
+			out := make(map[string]interface{}, len(n))
+			for k, child := range n {
+				name := k
+				if renamed, ok := fields[k]; ok {
+					name = renamed
+				} else if incoming && renamedAway(fields, k) {
+					continue
+				}
+				goName := k
+				if incoming {
+					goName = name
+				}
+				if ft, ok := jsonFieldType(t, goName); ok {
+					child = renameTree(child, ft, renames, incoming)
+				}
+				out[name] = child
+			}
+			return out
+		case reflect.Map:
+			for k, child := range n {
+				n[k] = renameTree(child, t.Elem(), renames, incoming)
+			}
+		}
+	case []interface{}:
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			for i, child := range n {
+				n[i] = renameTree(child, t.Elem(), renames, incoming)
+			}
+		}
+	}
+	return node
+}
+
+// renamedAway reports whether name is the old name of a field in the reverse
+// renames of a type, e.g. "choice_text" under V2.
+func renamedAway(reverse map[string]string, name string) bool {
+	for _, old := range reverse {
+		if old == name {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonFieldType finds the type of the struct field encoded under name,
+// looking through embedded structs.
+func jsonFieldType(t reflect.Type, name string) (reflect.Type, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if tag == "-" || !f.IsExported() && !f.Anonymous {
+			continue
+		}
+		if f.Anonymous && tag == "" {
+			inner := f.Type
+			for inner.Kind() == reflect.Ptr {
+				inner = inner.Elem()
+			}
+			if inner.Kind() == reflect.Struct {
+				if ft, ok := jsonFieldType(inner, name); ok {
+					return ft, true
+				}
+			}
+			continue
+		}
+		if tag == "" {
+			tag = f.Name
+		}
+		if tag == name {
+			return f.Type, true
+		}
+	}
+	return nil, false
+}
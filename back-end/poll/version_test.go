@@ -0,0 +1,44 @@
+package poll
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"simple-poll/apierror"
+)
+
+// Validation details name the choice text the way the request's version
+// does, and V2 does not read the V1 name.
+func TestChoiceTextFollowsTheVersion(t *testing.T) {
+	tests := []struct {
+		v     *APIVersion
+		body  string
+		field string
+	}{
+		{V1, `{"question_id": 1, "choice_text": ""}`, "choice_text"},
+		{V2, `{"question_id": 1, "text": ""}`, "text"},
+		{V2, `{"question_id": 1, "choice_text": "Yes"}`, "text"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, tt.v.BasePath()+"/choices/", strings.NewReader(tt.body))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set(UserIDHeader, "1")
+		rec := httptest.NewRecorder()
+		testAPI(openFakeDB(&fakeDB{}), tt.v).ServeHTTP(rec, r)
+		if rec.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%s %s: status %d, want 422; body %s", tt.v.Name, tt.body, rec.Code, rec.Body)
+			continue
+		}
+
+		var got struct{ Error apierror.Error }
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if len(got.Error.Details) != 1 || got.Error.Details[0].Field != tt.field {
+			t.Errorf("%s %s: details %+v, want one for %q", tt.v.Name, tt.body, got.Error.Details, tt.field)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package qr
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// link returns the first n bytes of a long poll link.
+func link(n int) []byte {
+	return []byte(strings.Repeat("https://poll.example/p/k7rm2xq9bd?token=", 10)[:n])
+}
+
+// The matrices in testdata were made by rsc.io/qr/coding from the same data,
+// at the version, level and mask Encode chose, one row per line with # for a
+// dark module. Inputs sit on either side of the version boundaries that
+// change the layout: version 7 adds the version information blocks and
+// version 10 widens the character count to 16 bits.
+var encodeTests = []struct {
+	name    string
+	n       int
+	level   Level
+	version int
+	mask    int
+}{
+	{"v1-L", 7, L, 1, 0},
+	{"v1-M", 7, M, 1, 4},
+	{"v1-Q", 7, Q, 1, 0},
+	{"v1-H", 7, H, 1, 3},
+	{"v1-M-full", 14, M, 1, 2},
+	{"v2-M", 15, M, 2, 6},
+	{"v6-Q-full", 74, Q, 6, 0},
+	{"v7-Q", 75, Q, 7, 4},
+	{"v9-H-full", 98, H, 9, 0},
+	{"v10-H", 99, H, 10, 2},
+}
+
+func TestEncodeMatchesReference(t *testing.T) {
+	for _, tt := range encodeTests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := Encode(link(tt.n), tt.level)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Version != tt.version || c.Mask != tt.mask || c.Level != tt.level {
+				t.Fatalf("version %d, level %s, mask %d; want %d, %s, %d",
+					c.Version, c.Level, c.Mask, tt.version, tt.level, tt.mask)
+			}
+			if want := tt.version*4 + 17; c.Size != want {
+				t.Fatalf("size %d, want %d", c.Size, want)
+			}
+
+			want, err := os.ReadFile(filepath.Join("testdata", tt.name+".txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows := strings.Split(strings.TrimSuffix(string(want), "\n"), "\n")
+			if len(rows) != c.Size {
+				t.Fatalf("reference has %d rows, want %d", len(rows), c.Size)
+			}
+			diff := 0
+			for y, row := range rows {
+				for x := range row {
+					if c.Dark(x, y) != (row[x] == '#') {
+						diff++
+					}
+				}
+			}
+			if diff > 0 {
+				t.Errorf("%d of %d modules differ from the reference", diff, c.Size*c.Size)
+			}
+		})
+	}
+}
+
+func TestEncodeTooLong(t *testing.T) {
+	// 2953 bytes is the capacity of a version 40 code at level L.
+	if _, err := Encode(bytes.Repeat([]byte("a"), 2953), L); err != nil {
+		t.Errorf("2953 bytes at L: %v", err)
+	}
+	if _, err := Encode(bytes.Repeat([]byte("a"), 2954), L); err != ErrTooLong {
+		t.Errorf("2954 bytes at L: err = %v, want ErrTooLong", err)
+	}
+}
+
+func TestPNGSize(t *testing.T) {
+	c, err := Encode(link(7), M) // 21 modules, 29 with the quiet zone
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct{ size, want int }{
+		{512, 512},
+		{29, 29},
+		{10, 29}, // too small for a pixel per module
+	} {
+		if got := decodePNG(t, c, tt.size).Bounds(); got.Dx() != tt.want || got.Dy() != tt.want {
+			t.Errorf("PNG(%d) is %dx%d, want %dx%d", tt.size, got.Dx(), got.Dy(), tt.want, tt.want)
+		}
+	}
+
+	// At 29 pixels a module is a pixel: the quiet zone is light and the top
+	// left finder pattern starts right after it.
+	img := decodePNG(t, c, 29)
+	for _, p := range []struct {
+		x, y int
+		dark bool
+	}{{0, 0, false}, {3, 3, false}, {4, 4, true}, {5, 5, false}, {7, 7, true}} {
+		r, _, _, _ := img.At(p.x, p.y).RGBA()
+		if dark := r == 0; dark != p.dark {
+			t.Errorf("pixel (%d, %d) dark = %v, want %v", p.x, p.y, dark, p.dark)
+		}
+	}
+}
+
+var svgSize = regexp.MustCompile(`<svg [^>]*width="(\d+)" height="(\d+)" viewBox="0 0 (\d+) (\d+)"`)
+
+func TestSVGSize(t *testing.T) {
+	c, err := Encode(link(15), M) // 25 modules, 33 with the quiet zone
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := svgSize.FindSubmatch(c.SVG(300))
+	if m == nil {
+		t.Fatal("no <svg> element with a size and viewBox")
+	}
+	for i, want := range []int{300, 300, 33, 33} {
+		if got, _ := strconv.Atoi(string(m[i+1])); got != want {
+			t.Errorf("svg size and viewBox = %s, want 300 300 0 0 33 33", m[0])
+			break
+		}
+	}
+
+	// One square per dark module.
+	dark := 0
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.Dark(x, y) {
+				dark++
+			}
+		}
+	}
+	if got := bytes.Count(c.SVG(300), []byte("h1v1h-1z")); got != dark {
+		t.Errorf("svg draws %d squares, want %d", got, dark)
+	}
+}
+
+func decodePNG(t *testing.T, c *Code, size int) image.Image {
+	t.Helper()
+	b, err := c.PNG(size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
@@ -0,0 +1,82 @@
+package qr
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// QuietZone is the light border, in modules, that readers need around a
+// code to find it.
+const QuietZone = 4
+
+// modulesWide is the width of the code with its quiet zone, in modules.
+func (c *Code) modulesWide() int {
+	return c.Size + 2*QuietZone
+}
+
+// Image renders the code, with its quiet zone, as a size×size black and
+// white image. Modules are whole pixels so the edges stay sharp; the code
+// is centred and any pixels left over go to the border. An image too small
+// for one pixel per module is enlarged to fit.
+func (c *Code) Image(size int) image.Image {
+	wide := c.modulesWide()
+	scale := max(size/wide, 1)
+	size = max(size, wide*scale)
+	offset := (size-wide*scale)/2 + QuietZone*scale
+
+	img := image.NewPaletted(image.Rect(0, 0, size, size), color.Palette{color.White, color.Black})
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !c.modules[y][x] {
+				continue
+			}
+			for py := 0; py < scale; py++ {
+				row := img.Pix[(offset+y*scale+py)*img.Stride:]
+				for px := 0; px < scale; px++ {
+					row[offset+x*scale+px] = 1
+				}
+			}
+		}
+	}
+	return img
+}
+
+// PNG encodes Image(size) as a PNG file.
+func (c *Code) PNG(size int) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, c.Image(size)); err != nil {
+		return nil, fmt.Errorf("qr: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// SVG renders the code, with its quiet zone, as an SVG document size
+// pixels wide. The drawing is in module units, so it scales to any size
+// without blurring; every dark module is one square of a single path.
+func (c *Code) SVG(size int) []byte {
+	wide := c.modulesWide()
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<?xml version="1.0" encoding="UTF-8"?>`+"\n")
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" version="1.1" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+"\n",
+		size, size, wide, wide)
+	fmt.Fprintf(&buf, `<rect width="100%%" height="100%%" fill="#FFFFFF"/>`+"\n")
+	buf.WriteString(`<path fill="#000000" d="`)
+	first := true
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !c.modules[y][x] {
+				continue
+			}
+			if !first {
+				buf.WriteByte(' ')
+			}
+			first = false
+			fmt.Fprintf(&buf, "M%d,%dh1v1h-1z", x+QuietZone, y+QuietZone)
+		}
+	}
+	buf.WriteString(`"/>` + "\n</svg>\n")
+	return buf.Bytes()
+}
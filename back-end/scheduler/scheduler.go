@@ -0,0 +1,166 @@
+// Package scheduler applies date-driven poll transitions in the background:
+// published polls open at their start date and close at their end date, and
+// registered hooks run once for every transition. It also creates the
+// occurrences of recurring polls as they fall due.
+//
+// Every transition is recorded in the poll_transitions table before its hooks
+// run, and a transition that is already recorded is skipped. That makes the
+// scheduler safe to restart at any moment, and to run on several backends at
+// once: after a restart it catches up on whatever fell due while it was down,
+// and no transition is applied or announced twice. The flip side is that a
+// hook interrupted by a crash is not retried.
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	poll "simple-poll/poll"
+)
+
+// Hook is called once for each transition after it has been applied.
+type Hook func(ctx context.Context, t poll.Transition) error
+
+// batchSize bounds how many transitions one query fetches.
+const batchSize = 100
+
+// DefaultInterval is how often the scheduler looks for due transitions.
+const DefaultInterval = 30 * time.Second
+
+// Scheduler periodically applies due poll transitions.
+type Scheduler struct {
+	store    Store
+	clock    Clock
+	interval time.Duration
+	hooks    []Hook
+}
+
+// New returns a Scheduler that checks store for due transitions every
+// interval, reading the time from clock.
+// Example usage:
+//
+//	s := scheduler.New(scheduler.DBStore{DB: db}, scheduler.SystemClock{}, scheduler.DefaultInterval)
+//	s.OnTransition(scheduler.LogTransitions)
+//	go s.Run(ctx)
+func New(store Store, clock Clock, interval time.Duration) *Scheduler {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Scheduler{store: store, clock: clock, interval: interval}
+}
+
+// OnTransition registers h to run for every transition, after the hooks
+// registered before it. Register hooks before calling Run.
+func (s *Scheduler) OnTransition(h Hook) {
+	s.hooks = append(s.hooks, h)
+}
+
+// Run applies due transitions every interval until ctx is done. It runs a
+// first pass immediately, which catches up after a restart.
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		if _, err := s.Tick(ctx); err != nil {
+			log.Printf("Error running scheduler: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.clock.After(s.interval):
+		}
+	}
+}
+
+// Tick creates the occurrences of recurring polls due now, then applies
+// every transition due now and runs the hooks for each one it applied,
+// returning those transitions. Occurrences come first so that a new
+// occurrence opens in the same tick. Tests call it directly after moving a
+// FakeClock.
+func (s *Scheduler) Tick(ctx context.Context) ([]poll.Transition, error) {
+	if err := s.createOccurrences(ctx); err != nil {
+		return nil, err
+	}
+
+	var applied []poll.Transition
+	for {
+		now := s.clock.Now()
+		due, err := s.store.DueTransitions(now, batchSize)
+		if err != nil {
+			return applied, err
+		}
+
+		progress := false
+		for _, t := range due {
+			if ctx.Err() != nil {
+				return applied, ctx.Err()
+			}
+			ok, err := s.store.RecordTransition(t, now)
+			if err != nil {
+				return applied, err
+			}
+			if !ok {
+				continue
+			}
+			progress = true
+			applied = append(applied, t)
+			s.runHooks(ctx, t)
+		}
+
+		// A short batch means nothing else is due; a batch where every
+		// transition was taken by someone else would repeat forever.
+		if len(due) < batchSize || !progress {
+			return applied, nil
+		}
+	}
+}
+
+// createOccurrences copies every recurring poll that is due into a new poll.
+// A series that fails is logged and retried on the next tick without holding
+// up the others.
+func (s *Scheduler) createOccurrences(ctx context.Context) error {
+	due, err := s.store.DueOccurrences(s.clock.Now())
+	if err != nil {
+		return err
+	}
+	for _, o := range due {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		p, err := s.store.CreateOccurrence(o)
+		if err != nil {
+			log.Printf("Error creating occurrence of poll %d: %v", o.SeriesID, err)
+			continue
+		}
+		if p != nil {
+			log.Printf("Created poll %d as the %s occurrence of poll %d", p.ID, o.At.Format(time.RFC3339), o.SeriesID)
+		}
+	}
+	return nil
+}
+
+func (s *Scheduler) runHooks(ctx context.Context, t poll.Transition) {
+	for _, h := range s.hooks {
+		if err := h(ctx, t); err != nil {
+			log.Printf("Error in scheduler hook for poll %d %s: %v", t.PollID, t.Kind, err)
+		}
+	}
+}
+
+// LogTransitions is a Hook that announces every transition in the server
+// log. Notification channels (email, webhooks, ...) plug in the same way.
+func LogTransitions(ctx context.Context, t poll.Transition) error {
+	log.Printf("Poll %d %s (due %s)", t.PollID, t.Kind, t.Due.Format(time.RFC3339))
+	return nil
+}
+
+// SnapshotResults returns a Hook that stores each poll's vote counts when it
+// closes, stamped with the scheduler clock's time.
+func SnapshotResults(db *sql.DB, clock Clock) Hook {
+	return func(ctx context.Context, t poll.Transition) error {
+		if t.Kind != poll.TransitionClosed {
+			return nil
+		}
+		return poll.SnapshotResults(db, t.PollID, clock.Now())
+	}
+}
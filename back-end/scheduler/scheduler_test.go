@@ -0,0 +1,290 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	poll "simple-poll/poll"
+)
+
+var t0 = time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+
+// memPoll is a published poll in a memStore.
+type memPoll struct {
+	start, end time.Time
+	closed     bool
+}
+
+// memStore is an in-memory Store with the semantics of the MySQL one: a
+// transition is recorded at most once, and a closed poll has no more
+// transitions.
+type memStore struct {
+	mu       sync.Mutex
+	polls    map[int64]*memPoll
+	recorded map[string]bool
+}
+
+func newMemStore() *memStore {
+	return &memStore{polls: make(map[int64]*memPoll), recorded: make(map[string]bool)}
+}
+
+func (s *memStore) add(id int64, start, end time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.polls[id] = &memPoll{start: start, end: end}
+}
+
+func transitionKey(id int64, kind poll.TransitionKind) string {
+	return fmt.Sprintf("%d/%s", id, kind)
+}
+
+func (s *memStore) DueTransitions(now time.Time, limit int) ([]poll.Transition, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []poll.Transition
+	for id, p := range s.polls {
+		if p.closed {
+			continue
+		}
+		if !p.start.After(now) && !s.recorded[transitionKey(id, poll.TransitionOpened)] {
+			due = append(due, poll.Transition{PollID: id, Kind: poll.TransitionOpened, Due: p.start})
+		}
+		if !p.end.After(now) {
+			due = append(due, poll.Transition{PollID: id, Kind: poll.TransitionClosed, Due: p.end})
+		}
+	}
+	// ORDER BY due, kind DESC, with poll IDs making the test deterministic.
+	sort.Slice(due, func(i, j int) bool {
+		a, b := due[i], due[j]
+		if !a.Due.Equal(b.Due) {
+			return a.Due.Before(b.Due)
+		}
+		if a.Kind != b.Kind {
+			return a.Kind > b.Kind
+		}
+		return a.PollID < b.PollID
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+func (s *memStore) RecordTransition(t poll.Transition, now time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := transitionKey(t.PollID, t.Kind)
+	p := s.polls[t.PollID]
+	if s.recorded[key] || p == nil || p.closed {
+		return false, nil
+	}
+	s.recorded[key] = true
+	if t.Kind == poll.TransitionClosed {
+		p.closed = true
+	}
+	return true, nil
+}
+
+func (s *memStore) DueOccurrences(now time.Time) ([]poll.Occurrence, error) {
+	return nil, nil
+}
+
+func (s *memStore) CreateOccurrence(o poll.Occurrence) (*poll.Poll, error) {
+	return nil, nil
+}
+
+// hookLog is a Hook recording every transition it is called with.
+type hookLog struct {
+	mu   sync.Mutex
+	seen []string
+}
+
+func (h *hookLog) hook(ctx context.Context, t poll.Transition) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.seen = append(h.seen, transitionKey(t.PollID, t.Kind))
+	return nil
+}
+
+func (h *hookLog) events() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.seen...)
+}
+
+func keys(ts []poll.Transition) []string {
+	out := make([]string, len(ts))
+	for i, t := range ts {
+		out[i] = transitionKey(t.PollID, t.Kind)
+	}
+	return out
+}
+
+func assertEvents(t *testing.T, what string, got []string, want ...string) {
+	t.Helper()
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("%s = %v, want %v", what, got, want)
+	}
+}
+
+func newScheduler(store Store, clock Clock) (*Scheduler, *hookLog) {
+	var log hookLog
+	s := New(store, clock, time.Minute)
+	s.OnTransition(log.hook)
+	return s, &log
+}
+
+func TestTickOpensBeforeCloses(t *testing.T) {
+	store := newMemStore()
+	store.add(1, t0.Add(time.Hour), t0.Add(2*time.Hour))
+	// Poll 2 opens exactly when poll 1 closes; openings go first.
+	store.add(2, t0.Add(2*time.Hour), t0.Add(3*time.Hour))
+	clock := NewFakeClock(t0)
+	s, log := newScheduler(store, clock)
+
+	applied, err := s.Tick(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEvents(t, "applied before the start date", keys(applied))
+
+	clock.Advance(4 * time.Hour)
+	applied, err = s.Tick(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEvents(t, "applied", keys(applied), "1/opened", "2/opened", "1/closed", "2/closed")
+	assertEvents(t, "hooks", log.events(), "1/opened", "2/opened", "1/closed", "2/closed")
+}
+
+func TestTickCatchesUpAfterRestart(t *testing.T) {
+	store := newMemStore()
+	store.add(1, t0.Add(time.Hour), t0.Add(3*time.Hour))
+	store.add(2, t0.Add(2*time.Hour), t0.Add(4*time.Hour))
+	clock := NewFakeClock(t0.Add(90 * time.Minute))
+
+	before, beforeLog := newScheduler(store, clock)
+	if _, err := before.Tick(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	assertEvents(t, "hooks before the restart", beforeLog.events(), "1/opened")
+
+	// The server is down while everything else falls due.
+	clock.Advance(5 * time.Hour)
+	after, afterLog := newScheduler(store, clock)
+	applied, err := after.Tick(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEvents(t, "applied after the restart", keys(applied), "2/opened", "1/closed", "2/closed")
+	assertEvents(t, "hooks after the restart", afterLog.events(), "2/opened", "1/closed", "2/closed")
+}
+
+func TestTickSkipsRecordedTransitions(t *testing.T) {
+	store := newMemStore()
+	store.add(1, t0.Add(-2*time.Hour), t0.Add(-time.Hour))
+	store.add(2, t0.Add(-2*time.Hour), t0.Add(time.Hour))
+	clock := NewFakeClock(t0)
+
+	s, log := newScheduler(store, clock)
+	if _, err := s.Tick(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	applied, err := s.Tick(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEvents(t, "applied by the second tick", keys(applied))
+	assertEvents(t, "hooks", log.events(), "1/opened", "2/opened", "1/closed")
+}
+
+// racingStore lets another scheduler record every transition between the
+// moment they are found due and the moment they are recorded, as a second
+// backend might.
+type racingStore struct {
+	*memStore
+	rival *Scheduler
+}
+
+func (s racingStore) DueTransitions(now time.Time, limit int) ([]poll.Transition, error) {
+	due, err := s.memStore.DueTransitions(now, limit)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.rival.Tick(context.Background()); err != nil {
+		return nil, err
+	}
+	return due, nil
+}
+
+func TestTickSkipsTransitionsTakenByAnotherScheduler(t *testing.T) {
+	store := newMemStore()
+	store.add(1, t0.Add(-2*time.Hour), t0.Add(-time.Hour))
+	clock := NewFakeClock(t0)
+
+	rival, rivalLog := newScheduler(store, clock)
+	s, log := newScheduler(racingStore{memStore: store, rival: rival}, clock)
+	applied, err := s.Tick(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEvents(t, "applied", keys(applied))
+	assertEvents(t, "hooks", log.events())
+	assertEvents(t, "rival hooks", rivalLog.events(), "1/opened", "1/closed")
+}
+
+func TestRunTicksEveryInterval(t *testing.T) {
+	store := newMemStore()
+	store.add(1, t0.Add(30*time.Second), t0.Add(90*time.Second))
+	clock := NewFakeClock(t0)
+
+	fired := make(chan poll.Transition, 2)
+	s := New(store, clock, time.Minute)
+	s.OnTransition(func(ctx context.Context, t poll.Transition) error {
+		fired <- t
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		clock.Advance(time.Minute) // wake Run so it sees ctx is done
+		<-done
+	}()
+
+	for _, want := range []poll.TransitionKind{poll.TransitionOpened, poll.TransitionClosed} {
+		waitForSleep(t, clock)
+		clock.Advance(time.Minute)
+		select {
+		case tr := <-fired:
+			if tr.Kind != want {
+				t.Fatalf("fired %s, want %s", tr.Kind, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no %s transition after advancing the clock", want)
+		}
+	}
+}
+
+// waitForSleep waits until the scheduler is blocked on the clock.
+func waitForSleep(t *testing.T, clock *FakeClock) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for clock.Waiters() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("scheduler never went back to sleep")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
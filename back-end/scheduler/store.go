@@ -0,0 +1,48 @@
+package scheduler
+
+import (
+	"database/sql"
+	"time"
+
+	poll "simple-poll/poll"
+)
+
+// Store is what the scheduler reads and writes. DBStore backs it with the
+// poll package's MySQL functions; tests use an in-memory Store.
+type Store interface {
+	// DueTransitions returns up to limit unrecorded transitions due at now,
+	// oldest first, with a poll's opening before its closing.
+	DueTransitions(now time.Time, limit int) ([]poll.Transition, error)
+	// RecordTransition applies and records t, reporting false when it was
+	// already recorded or no longer applies.
+	RecordTransition(t poll.Transition, now time.Time) (bool, error)
+	// DueOccurrences returns the occurrences of recurring polls due at now.
+	DueOccurrences(now time.Time) ([]poll.Occurrence, error)
+	// CreateOccurrence creates o, returning nil when it already exists.
+	CreateOccurrence(o poll.Occurrence) (*poll.Poll, error)
+}
+
+// DBStore is the Store of a running server.
+type DBStore struct {
+	DB *sql.DB
+}
+
+// DueTransitions implements Store.
+func (s DBStore) DueTransitions(now time.Time, limit int) ([]poll.Transition, error) {
+	return poll.DueTransitions(s.DB, now, limit)
+}
+
+// RecordTransition implements Store.
+func (s DBStore) RecordTransition(t poll.Transition, now time.Time) (bool, error) {
+	return poll.RecordTransition(s.DB, t, now)
+}
+
+// DueOccurrences implements Store.
+func (s DBStore) DueOccurrences(now time.Time) ([]poll.Occurrence, error) {
+	return poll.DueOccurrences(s.DB, now)
+}
+
+// CreateOccurrence implements Store.
+func (s DBStore) CreateOccurrence(o poll.Occurrence) (*poll.Poll, error) {
+	return poll.CreateOccurrence(s.DB, o)
+}
@@ -1,3 +1,8 @@
+-- Run by MySQL only when it initializes an empty volume. The back end adds
+-- whatever a volume created from an older version of this file is missing
+-- (poll.Migrate in back-end/poll/migrate.go), so every column, index or table
+-- added here needs a step there too.
+
 -- 1. USERS
 CREATE TABLE IF NOT EXISTS users (
     id BIGINT AUTO_INCREMENT PRIMARY KEY,
@@ -10,13 +15,36 @@ CREATE TABLE IF NOT EXISTS users (
 -- 2. POLLS
 CREATE TABLE IF NOT EXISTS polls (
     id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    -- Short name usable in URLs in place of the id: random or chosen
+    slug VARCHAR(64) NOT NULL,
     title VARCHAR(255) NOT NULL,
     description TEXT,
     created_by BIGINT NOT NULL,
     start_date DATETIME NULL,
     end_date DATETIME NULL,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE CASCADE
+    -- Lifecycle: draft -> published -> closed -> archived
+    state ENUM('draft', 'published', 'closed', 'archived') NOT NULL DEFAULT 'draft',
+    -- Bumped on every change to the poll or its questions and choices (ETag)
+    version BIGINT NOT NULL DEFAULT 1,
+    -- Set while the poll is in the trash; purged after the retention period
+    deleted_at DATETIME NULL,
+    -- Recurring polls: the rule, and the last occurrence the scheduler created
+    recurrence VARCHAR(255) NULL,
+    last_occurrence_at DATETIME NULL,
+    -- Set on each occurrence to the recurring poll it was copied from
+    series_id BIGINT NULL,
+    FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (series_id) REFERENCES polls(id) ON DELETE SET NULL,
+    UNIQUE KEY unique_poll_slug (slug),
+
+    -- Keyset pagination walks these in (sort column, id) order
+    INDEX idx_polls_created_at (created_at, id),
+    INDEX idx_polls_start_date (start_date, id),
+    INDEX idx_polls_end_date (end_date, id),
+    INDEX idx_polls_deleted_at (deleted_at, id),
+    INDEX idx_polls_series (series_id, start_date, id),
+    FULLTEXT INDEX ft_polls_title_description (title, description)
 );
 
 -- 3. QUESTIONS
@@ -24,7 +52,12 @@ CREATE TABLE IF NOT EXISTS questions (
     id BIGINT AUTO_INCREMENT PRIMARY KEY,
     poll_id BIGINT NOT NULL,
     question_text TEXT NOT NULL,
-    FOREIGN KEY (poll_id) REFERENCES polls(id) ON DELETE CASCADE
+    -- Ballot order within the poll; ties fall back to id
+    position INT NOT NULL DEFAULT 0,
+    version BIGINT NOT NULL DEFAULT 1,
+    FOREIGN KEY (poll_id) REFERENCES polls(id) ON DELETE CASCADE,
+    INDEX idx_questions_position (poll_id, position, id),
+    FULLTEXT INDEX ft_questions_text (question_text)
 );
 
 -- 4. CHOICES (or OPTIONS)
@@ -32,7 +65,12 @@ CREATE TABLE IF NOT EXISTS choices (
     id BIGINT AUTO_INCREMENT PRIMARY KEY,
     question_id BIGINT NOT NULL,
     choice_text TEXT NOT NULL,
-    FOREIGN KEY (question_id) REFERENCES questions(id) ON DELETE CASCADE
+    -- Ballot order within the question; ties fall back to id
+    position INT NOT NULL DEFAULT 0,
+    version BIGINT NOT NULL DEFAULT 1,
+    FOREIGN KEY (question_id) REFERENCES questions(id) ON DELETE CASCADE,
+    INDEX idx_choices_position (question_id, position, id),
+    FULLTEXT INDEX ft_choices_text (choice_text)
 );
 
 -- 5. VOTES (or RESPONSES)
@@ -57,6 +95,100 @@ CREATE TABLE IF NOT EXISTS voting_tokens (
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
+-- 6. SCHEDULED TRANSITIONS
+-- One row per date-driven event the scheduler has applied; the unique key
+-- keeps a transition from being processed twice across restarts.
+CREATE TABLE IF NOT EXISTS poll_transitions (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    poll_id BIGINT NOT NULL,
+    kind ENUM('opened', 'closed') NOT NULL,
+    due_at DATETIME NOT NULL,
+    recorded_at DATETIME NOT NULL,
+    FOREIGN KEY (poll_id) REFERENCES polls(id) ON DELETE CASCADE,
+    UNIQUE KEY unique_transition (poll_id, kind)
+);
+
+-- 7. RESULT SNAPSHOTS (vote counts taken when a poll closes)
+CREATE TABLE IF NOT EXISTS result_snapshots (
+    poll_id BIGINT NOT NULL,
+    question_id BIGINT NOT NULL,
+    choice_id BIGINT NOT NULL,
+    votes INT NOT NULL,
+    taken_at DATETIME NOT NULL,
+    PRIMARY KEY (poll_id, choice_id),
+    FOREIGN KEY (poll_id) REFERENCES polls(id) ON DELETE CASCADE,
+    FOREIGN KEY (question_id) REFERENCES questions(id) ON DELETE CASCADE,
+    FOREIGN KEY (choice_id) REFERENCES choices(id) ON DELETE CASCADE
+);
+
+-- 8. ORGANIZATIONS (managed in the database, like users)
+CREATE TABLE IF NOT EXISTS organizations (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS organization_members (
+    organization_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    PRIMARY KEY (organization_id, user_id),
+    FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_organization_members_user (user_id)
+);
+
+-- 9. POLL TEMPLATES published by organizations; built-in ones live in code
+CREATE TABLE IF NOT EXISTS poll_templates (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    organization_id BIGINT NOT NULL,
+    created_by BIGINT NULL,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    -- Parameters, poll title and description, duration and questions
+    definition JSON NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE,
+    FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE SET NULL,
+    INDEX idx_poll_templates_organization (organization_id, created_at, id)
+);
+
+-- 10. REVISIONS: the poll tree as it was after every change
+CREATE TABLE IF NOT EXISTS poll_revisions (
+    poll_id BIGINT NOT NULL,
+    -- polls.version reached by the change
+    version BIGINT NOT NULL,
+    action VARCHAR(32) NOT NULL,
+    -- X-User-ID of the change; no foreign key, so the history outlives users
+    author_id BIGINT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    -- The poll with its questions and choices, as JSON
+    snapshot JSON NOT NULL,
+    PRIMARY KEY (poll_id, version),
+    FOREIGN KEY (poll_id) REFERENCES polls(id) ON DELETE CASCADE
+);
+
+-- 11. TAGS: normalized (lower case) names shared by every poll using them
+CREATE TABLE IF NOT EXISTS tags (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY unique_tag_name (name)
+);
+
+CREATE TABLE IF NOT EXISTS poll_tags (
+    poll_id BIGINT NOT NULL,
+    tag_id BIGINT NOT NULL,
+    PRIMARY KEY (poll_id, tag_id),
+    FOREIGN KEY (poll_id) REFERENCES polls(id) ON DELETE CASCADE,
+    FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE,
+    -- Filtering polls by tag and counting uses for the tag cloud
+    INDEX idx_poll_tags_tag (tag_id, poll_id)
+);
+
 -- Insert a test user with ID = 100
 -- INSERT INTO users (id, username, email, password_hash)
--- VALUES (100, 'test_user', 'testuser@example.com', 'hash_for_test_user');
\ No newline at end of file
+-- VALUES (100, 'test_user', 'testuser@example.com', 'hash_for_test_user');
+
+-- Put the test user in an organization, for publishing templates
+-- INSERT INTO organizations (id, name) VALUES (1, 'Test organization');
+-- INSERT INTO organization_members (organization_id, user_id) VALUES (1, 100);
\ No newline at end of file